@@ -7,13 +7,18 @@ import (
 	"errors"
 
 	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/core/modules/audit"
 	"Tella-Desktop/backend/core/modules/auth"
 	"Tella-Desktop/backend/core/modules/filestore"
 	"Tella-Desktop/backend/core/modules/registration"
+	"Tella-Desktop/backend/core/modules/reports"
 	"Tella-Desktop/backend/core/modules/server"
+	"Tella-Desktop/backend/core/modules/signing"
 	"Tella-Desktop/backend/core/modules/transfer"
+	"Tella-Desktop/backend/utils/auditutils"
 	"Tella-Desktop/backend/utils/authutils"
 	"Tella-Desktop/backend/utils/config"
+	"Tella-Desktop/backend/utils/filestoreutils"
 	"Tella-Desktop/backend/utils/network"
 	"Tella-Desktop/backend/utils/nonces"
 	"Tella-Desktop/backend/utils/devlog"
@@ -32,6 +37,10 @@ type App struct {
 	transferService     transfer.Service
 	serverService       server.Service
 	fileService         filestore.Service
+	reportService       reports.Service
+	auditService        audit.Service
+	signingService      signing.Service
+	fileServer          *fileServer
 	defaultFolderID     int64
 }
 
@@ -88,7 +97,7 @@ func (a *App) VerifyPassword(password string) error {
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	return &App{fileServer: &fileServer{}}
 }
 
 func (a *App) Startup(ctx context.Context) {
@@ -101,6 +110,13 @@ func (a *App) Startup(ctx context.Context) {
 		return
 	}
 	a.nonceManager = nonces.NewNonceManager()
+
+	// anything left in the temp directory was decrypted by a session that didn't shut down cleanly. The database is
+	// still locked, so the whole directory is wiped rather than only the files tracked in temp_files, provided it is
+	// marked as the app's own.
+	if err := filestoreutils.WipeTempDir(authutils.GetTempDir()); err != nil {
+		log("Failed to wipe temporary files left by a previous session: %s", err)
+	}
 }
 
 var errRegistrationNotInit = errors.New("registration handler not initialized")
@@ -161,6 +177,22 @@ func (a *App) initializeServices() error {
 	a.fileService = filestore.NewService(a.ctx, db.DB, dbKey)
 	log("File storage service initialized")
 
+	// the files themselves were wiped at startup, this forgets them
+	if err := a.fileService.WipeTempFiles(); err != nil {
+		log("Failed to wipe temporary files left by a previous session: %s", err)
+	}
+
+	a.reportService = reports.NewService(a.ctx, db.DB, a.fileService)
+	a.auditService = audit.NewService(a.ctx, db.DB)
+
+	// new vaults get their signing key as the password is created, vaults from before signing the next time they are
+	// unlocked. Exports that get signed, archives or loose files with a manifest, are refused without it; everything
+	// else works.
+	a.signingService = signing.NewService(a.ctx, db.DB)
+	if err := a.signingService.EnsureKey(); err != nil {
+		log("Failed to create signing key: %s", err)
+	}
+
 	// we pass the transfer service two functions from registration in:
 	// 1. registration.SessionIsValid, in order to check if an incoming session ID matches what was saved during the register step
 	// 2. registration.ForgetSession, which mitigates memory leaks by being called as part of the transfer service's
@@ -178,6 +210,18 @@ func (a *App) initializeServices() error {
 		a.defaultFolderID,
 		a.nonceManager,
 	)
+
+	if err := a.fileServer.open(a.fileService); err != nil {
+		log("Failed to start file server: %s", err)
+		return err
+	}
+
+	// purge files that outlived the trash retention, now and periodically while unlocked
+	a.fileService.StartTrashPurge()
+
+	if err := a.auditService.Record(auditutils.ActionVaultUnlocked, map[string]any{}); err != nil {
+		log("Failed to record unlock in the audit log: %s", err)
+	}
 	return nil
 }
 
@@ -204,6 +248,14 @@ func (a *App) ensureDefaultFolder(db *sql.DB) (int64, error) {
 }
 
 func (a *App) Shutdown(ctx context.Context) {
+	a.fileServer.close()
+	if a.fileService != nil {
+		a.fileService.Lock()
+		if err := a.fileService.WipeTempFiles(); err != nil {
+			log("Failed to wipe temporary files during shutdown: %s", err)
+		}
+	}
+	a.recordLock("shutdown")
 	if a.db != nil {
 		a.db.Close()
 	}
@@ -236,6 +288,40 @@ func (a *App) GetLocalIPs() ([]string, error) {
 // Filestore functions
 
 var errFileServiceNotInit = errors.New("file service not initialized")
+// SelectFilesToImport lets the user pick local files to pass to ImportPaths
+func (a *App) SelectFilesToImport() ([]string, error) {
+	return runtime.OpenMultipleFilesDialog(a.ctx, runtime.OpenDialogOptions{Title: "Import files"})
+}
+
+// SelectFolderToImport lets the user pick a local directory to pass to ImportPaths
+func (a *App) SelectFolderToImport() (string, error) {
+	return runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{Title: "Import folder"})
+}
+
+func (a *App) ImportPaths(paths []string, folderID int64, shred bool) (*filestore.ImportResult, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.ImportPaths(paths, folderID, shred)
+}
+
+// SelectContainerToImport lets the user pick an encrypted export container to pass to ImportContainer
+func (a *App) SelectContainerToImport() (string, error) {
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Import encrypted export",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Encrypted exports (*" + filestoreutils.ContainerExtension + ")", Pattern: "*" + filestoreutils.ContainerExtension},
+		},
+	})
+}
+
+func (a *App) ImportContainer(path, passphrase string, parentID int64) (*filestore.ImportResult, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.ImportContainer(path, passphrase, parentID)
+}
+
 func (a *App) GetStoredFolders() ([]filestore.FolderInfo, error) {
 	if a.fileService == nil {
 		return nil, errFileServiceNotInit
@@ -250,20 +336,135 @@ func (a *App) GetFilesInFolder(folderID int64) (*filestore.FilesInFolderResponse
 	return a.fileService.GetFilesInFolder(folderID)
 }
 
-func (a *App) ExportFiles(ids []int64) ([]string, error) {
+func (a *App) GetThumbnail(fileID int64) (string, error) {
 	if a.fileService == nil {
-		return nil, errFileServiceNotInit
+		return "", errFileServiceNotInit
 	}
-	return a.fileService.ExportFiles(ids)
+	return a.fileService.GetThumbnail(fileID)
 }
 
-func (a *App) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) ([]string, error) {
+func (a *App) SearchFiles(query filestore.FileSearchQuery) (*filestore.FileSearchResult, error) {
 	if a.fileService == nil {
 		return nil, errFileServiceNotInit
 	}
-	return a.fileService.ExportZipFolders(folderIDs, selectedFileIDs)
+	return a.fileService.SearchFiles(query)
+}
+
+// StartExportFiles exports files in the background, optionally writing a JSON and CSV manifest of the exported files
+// next to them. It returns the ID of the job to follow through "export-progress" events; the paths written are in
+// the last one.
+func (a *App) StartExportFiles(ids []int64, options filestore.ExportOptions) (string, error) {
+	if a.fileService == nil {
+		return "", errFileServiceNotInit
+	}
+	return a.fileService.StartExportFiles(ids, options)
+}
+
+// StartExportZipFolders exports folders as ZIP archives in the background, optionally adding a JSON and CSV manifest
+// to every archive. It returns the ID of the job to follow through "export-progress" events.
+func (a *App) StartExportZipFolders(folderIDs []int64, selectedFileIDs []int64, options filestore.ExportOptions) (string, error) {
+	if a.fileService == nil {
+		return "", errFileServiceNotInit
+	}
+	return a.fileService.StartExportZipFolders(folderIDs, selectedFileIDs, options)
+}
+
+// CancelExport stops an export job started by StartExportFiles or StartExportZipFolders, removing what it wrote
+func (a *App) CancelExport(jobID string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.CancelExport(jobID)
+}
+
+// GetExportDirectory returns the folder exports go to unless they are given a destination
+func (a *App) GetExportDirectory() string {
+	return config.ReadConfig().GetExportDir()
+}
+
+var errSaveSettings = errors.New("failed to save settings")
+// ChooseExportDirectory lets the user pick the folder exports go to, and remembers it. It returns the folder, or an
+// empty string if the user cancelled.
+func (a *App) ChooseExportDirectory() (string, error) {
+	conf := config.ReadConfig()
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                "Export folder",
+		DefaultDirectory:     conf.GetExportDir(),
+		CanCreateDirectories: true,
+	})
+	if err != nil || dir == "" {
+		return "", err
+	}
+	if err := filestoreutils.CheckExportDir(dir, 0, conf.RefuseCloudSyncExports); err != nil {
+		return "", err
+	}
+	if err := config.UpdateConfig(func(conf *config.Config) { conf.ExportDir = dir }); err != nil {
+		log("Failed to save export folder: %s", err)
+		return "", errSaveSettings
+	}
+	return dir, nil
+}
+
+// ResetExportDirectory makes exports go to the default export folder again
+func (a *App) ResetExportDirectory() error {
+	if err := config.UpdateConfig(func(conf *config.Config) { conf.ExportDir = "" }); err != nil {
+		log("Failed to reset export folder: %s", err)
+		return errSaveSettings
+	}
+	return nil
+}
+
+func (a *App) GetRefuseCloudSyncExports() bool {
+	return config.ReadConfig().RefuseCloudSyncExports
+}
+
+// SetRefuseCloudSyncExports decides whether exports into folders synced to cloud storage are refused
+func (a *App) SetRefuseCloudSyncExports(refuse bool) error {
+	if err := config.UpdateConfig(func(conf *config.Config) { conf.RefuseCloudSyncExports = refuse }); err != nil {
+		log("Failed to save cloud sync setting: %s", err)
+		return errSaveSettings
+	}
+	return nil
+}
+
+// GetDuplicatePolicy returns what happens to files whose content is already stored, one of "store", "link" or "skip"
+func (a *App) GetDuplicatePolicy() string {
+	return config.ReadConfig().GetDuplicatePolicy()
+}
+
+var errDuplicatePolicy = errors.New("unknown duplicate policy")
+// SetDuplicatePolicy decides what happens to files whose content is already stored, from the next file stored on
+func (a *App) SetDuplicatePolicy(policy string) error {
+	if !config.ValidDuplicatePolicy(policy) {
+		return errDuplicatePolicy
+	}
+	if err := config.UpdateConfig(func(conf *config.Config) { conf.DuplicatePolicy = policy }); err != nil {
+		log("Failed to save duplicate policy: %s", err)
+		return errSaveSettings
+	}
+	if a.fileService != nil {
+		a.fileService.SetDuplicatePolicy(policy)
+	}
+	return nil
+}
+
+// GetFileURL returns the URL the webview can load a file from, decrypted in memory, for as long as the app stays
+// unlocked
+func (a *App) GetFileURL(fileID int64) (string, error) {
+	if a.fileService == nil {
+		return "", errFileServiceNotInit
+	}
+	return a.fileServer.fileURL(fileID)
+}
+
+func (a *App) OpenFileForPreview(fileID int64) (string, error) {
+	if a.fileService == nil {
+		return "", errFileServiceNotInit
+	}
+	return a.fileService.OpenFileForPreview(fileID)
 }
 
+// DeleteFiles securely deletes files right away, without going through the trash, for files too sensitive to keep
 func (a *App) DeleteFiles(ids []int64) error {
 	if a.fileService == nil {
 		log("file service not initialized")
@@ -280,6 +481,110 @@ func (a *App) DeleteFiles(ids []int64) error {
 	return nil
 }
 
+// TrashFiles moves files to the trash, from where RestoreFiles brings them back until they are purged after the
+// configured retention period. DeleteFiles deletes files right away instead.
+func (a *App) TrashFiles(ids []int64) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.TrashFiles(ids)
+}
+
+// RestoreFiles brings files back from the trash into folderID, or into the folders they were deleted from if it is 0
+func (a *App) RestoreFiles(ids []int64, folderID int64) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.RestoreFiles(ids, folderID)
+}
+
+func (a *App) GetTrash() ([]filestore.TrashedFileInfo, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.GetTrash()
+}
+
+func (a *App) PurgeTrash(ids []int64) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.PurgeTrash(ids)
+}
+
+func (a *App) EmptyTrash() error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.EmptyTrash()
+}
+
+func (a *App) GetTags() ([]filestore.TagInfo, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.GetTags()
+}
+
+func (a *App) GetFileAnnotations(fileID int64) (*filestore.Annotations, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.GetFileAnnotations(fileID)
+}
+
+func (a *App) GetFolderAnnotations(folderID int64) (*filestore.Annotations, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.GetFolderAnnotations(folderID)
+}
+
+func (a *App) TagFiles(fileIDs []int64, tags []string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.TagFiles(fileIDs, tags)
+}
+
+func (a *App) UntagFiles(fileIDs []int64, tags []string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.UntagFiles(fileIDs, tags)
+}
+
+func (a *App) TagFolders(folderIDs []int64, tags []string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.TagFolders(folderIDs, tags)
+}
+
+func (a *App) UntagFolders(folderIDs []int64, tags []string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.UntagFolders(folderIDs, tags)
+}
+
+// SetFileCustomMetadata sets a value such as a case number or location on a file; an empty value removes the key
+func (a *App) SetFileCustomMetadata(fileID int64, key, value string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.SetFileCustomMetadata(fileID, key, value)
+}
+
+// SetFolderCustomMetadata sets a value such as a case number or location on a folder; an empty value removes the key
+func (a *App) SetFolderCustomMetadata(folderID int64, key, value string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.SetFolderCustomMetadata(folderID, key, value)
+}
+
+// DeleteFolders deletes folders and their subfolders, securely deleting their files right away
 func (a *App) DeleteFolders(folderIDs []int64) error {
 	if a.fileService == nil {
 		return errFileServiceNotInit
@@ -287,6 +592,188 @@ func (a *App) DeleteFolders(folderIDs []int64) error {
 	return a.fileService.DeleteFolders(folderIDs)
 }
 
+// TrashFolders deletes folders and their subfolders, moving their files to the trash
+func (a *App) TrashFolders(folderIDs []int64) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.TrashFolders(folderIDs)
+}
+
+func (a *App) CreateFolder(name string, parentID int64) (int64, error) {
+	if a.fileService == nil {
+		return 0, errFileServiceNotInit
+	}
+	return a.fileService.CreateFolder(name, parentID)
+}
+
+func (a *App) RenameFolder(folderID int64, name string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.RenameFolder(folderID, name)
+}
+
+func (a *App) MoveFolder(folderID, newParentID int64) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.MoveFolder(folderID, newParentID)
+}
+
+func (a *App) GetFolderTree() ([]*filestore.FolderNode, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.GetFolderTree()
+}
+
+func (a *App) RenameFile(fileID int64, name string) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.RenameFile(fileID, name)
+}
+
+func (a *App) MoveFiles(fileIDs []int64, folderID int64) error {
+	if a.fileService == nil {
+		return errFileServiceNotInit
+	}
+	return a.fileService.MoveFiles(fileIDs, folderID)
+}
+
+func (a *App) CopyFiles(fileIDs []int64, folderID int64) ([]int64, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.CopyFiles(fileIDs, folderID)
+}
+
+func (a *App) CompactVault() (*filestore.CompactionResult, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.CompactVault()
+}
+
+func (a *App) GetFreeSpaceReport() (*filestore.FreeSpaceReport, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.GetFreeSpaceReport()
+}
+
+func (a *App) GetVaultStats() (*filestore.VaultStats, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.GetVaultStats()
+}
+
+func (a *App) CheckVault(repair bool) (*filestore.VaultCheckReport, error) {
+	if a.fileService == nil {
+		return nil, errFileServiceNotInit
+	}
+	return a.fileService.CheckVault(repair)
+}
+
+// report functions
+var errReportServiceNotInit = errors.New("report service not initialized")
+func (a *App) CreateReport(name string) (int64, error) {
+	if a.reportService == nil {
+		return 0, errReportServiceNotInit
+	}
+	return a.reportService.CreateReport(name)
+}
+
+func (a *App) RenameReport(reportID int64, name string) error {
+	if a.reportService == nil {
+		return errReportServiceNotInit
+	}
+	return a.reportService.RenameReport(reportID, name)
+}
+
+func (a *App) DeleteReport(reportID int64) error {
+	if a.reportService == nil {
+		return errReportServiceNotInit
+	}
+	return a.reportService.DeleteReport(reportID)
+}
+
+func (a *App) AddFilesToReport(reportID int64, fileIDs []int64) error {
+	if a.reportService == nil {
+		return errReportServiceNotInit
+	}
+	return a.reportService.AddFilesToReport(reportID, fileIDs)
+}
+
+func (a *App) RemoveFilesFromReport(reportID int64, fileIDs []int64) error {
+	if a.reportService == nil {
+		return errReportServiceNotInit
+	}
+	return a.reportService.RemoveFilesFromReport(reportID, fileIDs)
+}
+
+func (a *App) GetReports() ([]reports.ReportInfo, error) {
+	if a.reportService == nil {
+		return nil, errReportServiceNotInit
+	}
+	return a.reportService.GetReports()
+}
+
+func (a *App) GetReportFiles(reportID int64) (*reports.ReportFilesResponse, error) {
+	if a.reportService == nil {
+		return nil, errReportServiceNotInit
+	}
+	return a.reportService.GetReportFiles(reportID)
+}
+
+func (a *App) ExportReportZip(reportID int64) (string, error) {
+	if a.reportService == nil {
+		return "", errReportServiceNotInit
+	}
+	return a.reportService.ExportReportZip(reportID)
+}
+
+// audit log functions
+var errAuditServiceNotInit = errors.New("audit service not initialized")
+func (a *App) VerifyAuditLog() (*audit.Verification, error) {
+	if a.auditService == nil {
+		return nil, errAuditServiceNotInit
+	}
+	return a.auditService.VerifyLog()
+}
+
+func (a *App) ExportAuditLog() (string, error) {
+	if a.auditService == nil {
+		return "", errAuditServiceNotInit
+	}
+	return a.auditService.ExportLog()
+}
+
+// signing functions
+var errSigningServiceNotInit = errors.New("signing service not initialized")
+func (a *App) GetSigningKeyFingerprint() (string, error) {
+	if a.signingService == nil {
+		return "", errSigningServiceNotInit
+	}
+	return a.signingService.GetFingerprint()
+}
+
+func (a *App) ExportSigningKey() (string, error) {
+	if a.signingService == nil {
+		return "", errSigningServiceNotInit
+	}
+	return a.signingService.ExportPublicKey()
+}
+
+func (a *App) VerifySignedExport(path string) (*signing.ExportVerification, error) {
+	if a.signingService == nil {
+		return nil, errSigningServiceNotInit
+	}
+	return a.signingService.VerifyExport(path)
+}
+
 // upload functions
 func (a *App) AcceptTransfer(sessionID string) error {
 	if a.transferService == nil {
@@ -321,6 +808,18 @@ func (a *App) LockApp() error {
 		}
 	}
 
+	// Stop serving files to the webview
+	a.fileServer.close()
+
+	// Stop purging the trash, and wipe decrypted previews while the database still knows where they are
+	if a.fileService != nil {
+		a.fileService.Lock()
+		if err := a.fileService.WipeTempFiles(); err != nil {
+			log("Failed to wipe temporary files during lock: %s", err)
+		}
+	}
+	a.recordLock("lock")
+
 	// Close database connection
 	if a.db != nil {
 		a.db.Close()
@@ -334,6 +833,9 @@ func (a *App) LockApp() error {
 	a.registrationService.Lock()
 	// Clear services that depend on database
 	a.fileService = nil
+	a.reportService = nil
+	a.auditService = nil
+	a.signingService = nil
 	a.transferService = nil
 	a.serverService = nil
 	a.defaultFolderID = 0
@@ -346,3 +848,14 @@ func (a *App) LockApp() error {
 	log("Application locked successfully")
 	return nil
 }
+
+// recordLock records in the audit log that the vault is about to be locked, by the user or by quitting the app. It
+// must run while the database is still open.
+func (a *App) recordLock(reason string) {
+	if a.auditService == nil {
+		return
+	}
+	if err := a.auditService.Record(auditutils.ActionVaultLocked, map[string]any{"reason": reason}); err != nil {
+		log("Failed to record lock in the audit log: %s", err)
+	}
+}
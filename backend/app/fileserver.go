@@ -0,0 +1,165 @@
+package app
+
+import (
+	"Tella-Desktop/backend/core/modules/filestore"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileServerPath is where the webview requests vault files, as fileServerPath/<file ID>?token=<session token>
+const fileServerPath = "/vault/files/"
+
+// mimetypes the webview may render from a vault file. Anything else is served as a download, so that a received HTML
+// file, say, never runs inside the app.
+var inlineMimePrefixes = []string{"image/", "audio/", "video/", "text/plain"}
+
+// fileServer serves decrypted vault files to the webview through the Wails asset server, so that files can be viewed
+// without writing their plaintext to disk. Files are only served while the vault is unlocked, and only to requests
+// carrying the token of the current unlock session; the token is handed to the frontend by GetFileURL.
+type fileServer struct {
+	mu    sync.RWMutex
+	files filestore.Service
+	token string
+	// session changes on every unlock and lock, so that streams opened in one session stop in the next
+	session uint64
+}
+
+// NewFileHandler returns the handler to pass to the Wails asset server for serving vault files
+func NewFileHandler(a *App) http.Handler {
+	return a.fileServer
+}
+
+// open starts serving files from files under a new session token
+func (f *fileServer) open(files filestore.Service) error {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files = files
+	f.token = hex.EncodeToString(token)
+	f.session++
+	return nil
+}
+
+// close stops serving files; streams still being served fail on their next read
+func (f *fileServer) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files = nil
+	f.token = ""
+	f.session++
+}
+
+var errFileServerClosed = errors.New("vault is locked")
+
+func (f *fileServer) fileURL(fileID int64) (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.files == nil {
+		return "", errFileServerClosed
+	}
+	return fmt.Sprintf("%s%d?token=%s", fileServerPath, fileID, f.token), nil
+}
+
+// authorize returns the file service and session to serve a request with, if it carries the current token
+func (f *fileServer) authorize(token string) (filestore.Service, uint64, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.files == nil || subtle.ConstantTimeCompare([]byte(token), []byte(f.token)) != 1 {
+		return nil, 0, false
+	}
+	return f.files, f.session, true
+}
+
+func (f *fileServer) sessionActive(session uint64) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.files != nil && f.session == session
+}
+
+func (f *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	idString, ok := strings.CutPrefix(r.URL.Path, fileServerPath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	files, session, ok := f.authorize(r.URL.Query().Get("token"))
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	fileID, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// every request opens the file anew: chunked files only decrypt the chunks the range covers, but files in the older
+	// blob format are decrypted in full for each range the webview asks for
+	stream, err := files.OpenFileStream(fileID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer stream.Close()
+
+	header := w.Header()
+	// the plaintext must not end up in the webview's cache on disk
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	if servedInline(stream.MimeType) {
+		header.Set("Content-Type", stream.MimeType)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": stream.Name})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+		header.Set("Content-Disposition", disposition)
+	}
+
+	// ServeContent takes care of Range requests, so media can seek
+	http.ServeContent(w, r, "", time.Time{}, &sessionReader{FileStream: stream, server: f, session: session})
+}
+
+func servedInline(mimeType string) bool {
+	for _, prefix := range inlineMimePrefixes {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionReader stops reading a file once the session it was opened in has ended
+type sessionReader struct {
+	*filestore.FileStream
+	server  *fileServer
+	session uint64
+}
+
+func (s *sessionReader) Read(p []byte) (int, error) {
+	if !s.server.sessionActive(s.session) {
+		return 0, errFileServerClosed
+	}
+	return s.FileStream.Read(p)
+}
@@ -87,11 +87,33 @@ func runMigrations(db *sql.DB) error {
 		return errFailedMigration
 	}
 	defer tx.Rollback()
+
+	// keep track of applied migrations, so that migrations which are not idempotent (e.g. ALTER TABLE) only run once
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		log("failed to create schema_migrations table: %v", err)
+		return errFailedMigration
+	}
+
 	for _, migration := range getMigrations() {
+		var applied int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE name = ?", migration.Name).Scan(&applied); err != nil {
+			log("failed to check migration %s: %v", migration.Name, err)
+			return errFailedMigration
+		}
+		if applied > 0 {
+			continue
+		}
 		if _, err := tx.Exec(string(migration.Content)); err != nil {
 			log("failed to execute migration %s: %v", migration.Name, err)
 			return errFailedMigration
 		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (name, applied_at) VALUES (?, datetime('now'))", migration.Name); err != nil {
+			log("failed to record migration %s: %v", migration.Name, err)
+			return errFailedMigration
+		}
 	}
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
@@ -6,7 +6,8 @@ type migrationEntry struct {
 }
 
 func getMigrations() []migrationEntry {
-	return []migrationEntry{migrationEntry{"001_initial_schema", `-- backend/core/database/migrations/001_initial_schema.sql
+	return []migrationEntry{
+		migrationEntry{"001_initial_schema", `-- backend/core/database/migrations/001_initial_schema.sql
 	-- Enable foreign key support
 	PRAGMA foreign_keys = ON;
 
@@ -100,5 +101,123 @@ func getMigrations() []migrationEntry {
 	BEGIN
 	UPDATE files SET updated_at = CURRENT_TIMESTAMP 
 	WHERE id = NEW.id;
-	END;`}}
+	END;`},
+		migrationEntry{"002_chunked_file_format", `-- encryption format of the file's ciphertext in the TVault:
+	-- 0 = single AES-GCM blob (files stored before chunked encryption was introduced), 1 = chunked (see authutils.ChunkedWriter)
+	ALTER TABLE files ADD COLUMN encryption_format INTEGER NOT NULL DEFAULT 0;`},
+		migrationEntry{"003_free_spaces_offset_index", `-- the allocator looks up free blocks adjacent to a region by their offset
+	CREATE INDEX IF NOT EXISTS idx_free_spaces_offset ON free_spaces(offset);`},
+		migrationEntry{"004_quarantined_files", `-- files that failed the vault integrity check. They are marked as deleted so that they are hidden from the user,
+	-- but unless the region is damaged, it is kept (see filestoreutils.referencedFilesCondition) in case the data can be
+	-- recovered
+	CREATE TABLE IF NOT EXISTS quarantined_files (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_id INTEGER NOT NULL UNIQUE,
+		reason TEXT NOT NULL,
+		keep_region INTEGER NOT NULL DEFAULT 1,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+	);`},
+		migrationEntry{"005_file_sha256", `-- hex encoded SHA-256 of the file's plaintext, verified on export. NULL for files stored before it was recorded
+	ALTER TABLE files ADD COLUMN sha256 TEXT;`},
+		migrationEntry{"006_file_search_indexes", `-- indexes backing filestore.SearchFiles: one per sort key, ending in id for keyset pagination
+	CREATE INDEX IF NOT EXISTS idx_files_live_created ON files(is_deleted, created_at, id);
+	CREATE INDEX IF NOT EXISTS idx_files_live_name ON files(is_deleted, name COLLATE NOCASE, id);
+	CREATE INDEX IF NOT EXISTS idx_files_live_size ON files(is_deleted, size, id);
+	CREATE INDEX IF NOT EXISTS idx_files_live_mime ON files(is_deleted, mime_type, id);
+	CREATE INDEX IF NOT EXISTS idx_files_folder_live_created ON files(folder_id, is_deleted, created_at);`},
+		migrationEntry{"007_report_files_unique", `-- a file is part of a report at most once
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_report_files_unique ON report_files(report_id, file_id);`},
+		migrationEntry{"008_file_thumbnails", `-- TVault region of the encrypted JPEG thumbnail of an image, NULL if there is none (see filestoreutils.ThumbnailID)
+	ALTER TABLE files ADD COLUMN thumbnail_offset INTEGER;
+	ALTER TABLE files ADD COLUMN thumbnail_length INTEGER;`},
+		migrationEntry{"009_content_dedup", `-- UUID the ciphertext of a file is encrypted under when the file shares the ciphertext of an identical file stored
+	-- before it, NULL when the file has a region of its own. A region is referenced by every row pointing at it and is
+	-- only freed once none is left, see filestoreutils.RegionReferenced.
+	ALTER TABLE files ADD COLUMN content_uuid TEXT;
+	CREATE INDEX IF NOT EXISTS idx_files_live_sha256 ON files(sha256, size) WHERE is_deleted = 0;
+	CREATE INDEX IF NOT EXISTS idx_files_offset ON files(offset);
+	CREATE INDEX IF NOT EXISTS idx_files_thumbnail_offset ON files(thumbnail_offset) WHERE thumbnail_offset IS NOT NULL;`},
+		migrationEntry{"010_trashed_files", `-- files in the trash. They are marked as deleted so that they are hidden from the user, but their ciphertext is kept
+	-- until they are restored or purged.
+	CREATE TABLE IF NOT EXISTS trashed_files (
+		file_id INTEGER PRIMARY KEY,
+		trashed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_trashed_files_trashed_at ON trashed_files(trashed_at);`},
+		migrationEntry{"011_tags_custom_metadata", `-- user defined tags, and key/value metadata such as case numbers, on files and folders
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS file_tags (
+		file_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (file_id, tag_id),
+		FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_file_tags_tag ON file_tags(tag_id, file_id);
+	CREATE TABLE IF NOT EXISTS folder_tags (
+		folder_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (folder_id, tag_id),
+		FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_folder_tags_tag ON folder_tags(tag_id, folder_id);
+	CREATE TABLE IF NOT EXISTS file_custom_metadata (
+		file_id INTEGER NOT NULL,
+		key TEXT NOT NULL COLLATE NOCASE,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (file_id, key),
+		FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS folder_custom_metadata (
+		folder_id INTEGER NOT NULL,
+		key TEXT NOT NULL COLLATE NOCASE,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (folder_id, key),
+		FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE
+	);`},
+		migrationEntry{"012_file_transfer_title", `-- title of the transfer a file was received in, NULL for files that didn't arrive by transfer. Listed in export
+	-- manifests.
+	ALTER TABLE files ADD COLUMN transfer_title TEXT;`},
+		migrationEntry{"013_audit_log", `-- append-only log of vault operations, see auditutils. created_at is TEXT rather than TIMESTAMP so that the
+	-- driver hands back the exact string that was hashed. AUTOINCREMENT keeps the highest ID handed out in sqlite_sequence,
+	-- which reveals entries removed from the end of the log.
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at TEXT NOT NULL,
+		action TEXT NOT NULL,
+		details TEXT NOT NULL,
+		prev_hash TEXT NOT NULL,
+		hash TEXT NOT NULL
+	);
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'the audit log is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'the audit log is append-only');
+	END;`},
+		migrationEntry{"014_signing_key", `-- the Ed25519 key exports are signed with, see signutils. A vault has a single key, so id is always 1.
+	CREATE TABLE IF NOT EXISTS signing_key (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		seed BLOB NOT NULL,
+		public_key BLOB NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`},
+		migrationEntry{"015_lowercase_mime_types", `-- mimetypes are stored lowercased, see filestoreutils.NormalizeMimeType, so that the search filter can compare them
+	-- exactly and keep using the index on mime_type
+	UPDATE files SET mime_type = LOWER(mime_type) WHERE mime_type <> LOWER(mime_type);`},
+		migrationEntry{"016_trashed_folder_path", `-- the names of the folder a file was trashed from and its parents, top-level folder first, as a JSON array. Files of
+	-- a deleted folder are restored into a folder recreated from it. NULL for files trashed before it was recorded.
+	ALTER TABLE trashed_files ADD COLUMN folder_path TEXT;`},
+	}
 }
@@ -0,0 +1,21 @@
+package audit
+
+import "Tella-Desktop/backend/utils/auditutils"
+
+// Verification is the result of checking the hash chain of the audit log
+type Verification struct {
+	Entries int  `json:"entries"`
+	Valid   bool `json:"valid"`
+	// HeadHash is the hash of the last entry
+	HeadHash string `json:"headHash"`
+	// BrokenAt is the ID of the first entry that fails verification, 0 if the log is valid
+	BrokenAt int64  `json:"brokenAt"`
+	Problem  string `json:"problem"`
+}
+
+// LogExport is the content of an exported audit log
+type LogExport struct {
+	ExportedAt   string             `json:"exportedAt"`
+	Verification Verification       `json:"verification"`
+	Entries      []auditutils.Entry `json:"entries"`
+}
@@ -0,0 +1,13 @@
+package audit
+
+type Service interface {
+	// Record appends an operation to the audit log; details is stored as a JSON object
+	Record(action string, details any) error
+
+	// VerifyLog checks that no entry of the audit log was altered, removed or reordered
+	VerifyLog() (*Verification, error)
+
+	// ExportLog writes the audit log with its verification result to a JSON file in the export directory, returning
+	// the file's path
+	ExportLog() (string, error)
+}
@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/devlog"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	util "Tella-Desktop/backend/utils/genericutil"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+var log = devlog.Logger("audit")
+
+// The audit log lives in the encrypted database next to the records it describes, so it is only readable while the
+// vault is unlocked. Other services append to it through auditutils.Append; this service reads and checks it.
+type service struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewService(ctx context.Context, db *sql.DB) Service {
+	return &service{
+		ctx: ctx,
+		db:  db,
+	}
+}
+
+var errRecord = errors.New("failed to record operation in the audit log")
+
+func (s *service) Record(action string, details any) error {
+	if err := auditutils.Append(s.db, action, details); err != nil {
+		log("failed to append %s to the audit log: %v", action, err)
+		return errRecord
+	}
+	return nil
+}
+
+var errVerifyLog = errors.New("failed to verify the audit log")
+
+func (s *service) VerifyLog() (*Verification, error) {
+	_, verification, err := s.readLog()
+	if err != nil {
+		return nil, errVerifyLog
+	}
+	if !verification.Valid {
+		log("audit log verification failed at entry %d: %s", verification.BrokenAt, verification.Problem)
+	}
+	return verification, nil
+}
+
+// readLog returns every entry of the audit log with the result of verifying them
+func (s *service) readLog() ([]auditutils.Entry, *Verification, error) {
+	entries, lastID, err := auditutils.ReadLog(s.db)
+	if err != nil {
+		log("failed to query the audit log: %v", err)
+		return nil, nil, err
+	}
+	result := auditutils.VerifyChain(entries, lastID)
+	return entries, &Verification{
+		Entries:  result.Entries,
+		Valid:    result.Valid,
+		HeadHash: result.HeadHash,
+		BrokenAt: result.BrokenAt,
+		Problem:  result.Problem,
+	}, nil
+}
+
+var errExportLog = errors.New("failed to export the audit log")
+
+func (s *service) ExportLog() (string, error) {
+	entries, verification, err := s.readLog()
+	if err != nil {
+		return "", errExportLog
+	}
+
+	exportDir, err := filestoreutils.ExportDir("", 0)
+	if err != nil {
+		return "", err
+	}
+	exportPath := filestoreutils.CreateUniqueFilename(exportDir, "audit-log.json")
+	file, err := util.NarrowCreate(exportPath)
+	if err != nil {
+		log("failed to create audit log export: %v", err)
+		return "", errExportLog
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(LogExport{
+		ExportedAt:   time.Now().UTC().Format(time.RFC3339),
+		Verification: *verification,
+		Entries:      entries,
+	})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log("failed to write audit log export: %v", err)
+		os.Remove(exportPath)
+		return "", errExportLog
+	}
+
+	log("Exported %d audit log entries to: %s", len(entries), exportPath)
+	return exportPath, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"Tella-Desktop/backend/utils/authutils"
 	"Tella-Desktop/backend/utils/constants"
+	"Tella-Desktop/backend/utils/filestoreutils"
 	util "Tella-Desktop/backend/utils/genericutil"
 	"Tella-Desktop/backend/utils/devlog"
 
@@ -47,7 +48,7 @@ func (s *service) Initialize(ctx context.Context) error {
 
 	// create tmp directory for decrypted files
 	tempDir := authutils.GetTempDir()
-	if err := os.MkdirAll(tempDir, util.USER_ONLY_DIR_PERMS); err != nil {
+	if err := filestoreutils.PrepareTempDir(tempDir); err != nil {
 		log("failed to create temp directory: %w", err)
 		return initFailed
 	}
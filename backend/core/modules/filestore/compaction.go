@@ -0,0 +1,167 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/constants"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	util "Tella-Desktop/backend/utils/genericutil"
+	"errors"
+	"os"
+)
+
+var errCompactVault = errors.New("failed to compact vault")
+
+// CompactVault moves the ciphertext of all stored files toward the start of the TVault, closing the gaps left behind by
+// deleted files, and then truncates the unused tail of the TVault.
+//
+// Every step is ordered so that a crash at any point never loses a file: a file's ciphertext is first copied to its new
+// location and synced to disk, and only then is its offset updated in the database. Until that update commits, the
+// database keeps pointing at the untouched original. When the destination overlaps the original, the ciphertext is
+// first staged at the end of the TVault. The worst a crash can do is leave unreferenced bytes behind, which the next
+// compaction reclaims.
+func (s *service) CompactVault() (*CompactionResult, error) {
+	s.vaultMu.Lock()
+	defer s.vaultMu.Unlock()
+
+	vaultInfo, err := os.Stat(s.tvaultPath)
+	if err != nil {
+		log("failed to stat TVault: %v", err)
+		return nil, errCompactVault
+	}
+	result := &CompactionResult{SizeBefore: vaultInfo.Size()}
+
+	// Forget about all free space up front: from here on, anything that isn't referenced by a file is either going to be
+	// overwritten by a relocated file or truncated away. Should we crash part-way, the space is merely unaccounted for
+	// until the next compaction, rather than being handed out while a relocated file lives in it.
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return nil, errCompactVault
+	}
+	defer tx.Rollback()
+
+	regions, err := filestoreutils.GetReferencedRegions(tx)
+	if err != nil {
+		log("failed to get referenced TVault regions: %v", err)
+		return nil, errCompactVault
+	}
+
+	// refuse to touch a vault whose layout is inconsistent; moving bytes around would only make matters worse
+	cursor := int64(constants.TVaultHeaderSize)
+	for _, region := range regions {
+		if region.Offset < cursor || region.End() > vaultInfo.Size() {
+			log("TVault region at offset %d (length %d) overlaps another region or is out of bounds", region.Offset, region.Length)
+			return nil, errCompactVault
+		}
+		cursor = region.End()
+	}
+
+	if _, err := tx.Exec("DELETE FROM free_spaces"); err != nil {
+		log("failed to clear free spaces: %v", err)
+		return nil, errCompactVault
+	}
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return nil, errCompactVault
+	}
+
+	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR, util.USER_ONLY_FILE_PERMS)
+	if err != nil {
+		log("failed to open TVault: %v", err)
+		return nil, errCompactVault
+	}
+	defer tvault.Close()
+
+	vaultEnd := vaultInfo.Size()
+	cursor = int64(constants.TVaultHeaderSize)
+	for _, region := range regions {
+		if region.Offset == cursor {
+			cursor = region.End()
+			continue
+		}
+
+		if cursor+region.Length > region.Offset {
+			// the destination overlaps the current location: stage the ciphertext at the end of the TVault first
+			staged := filestoreutils.VaultRegion{Offset: vaultEnd, Length: region.Length}
+			if err := s.relocateRegion(tvault, region, staged.Offset); err != nil {
+				return nil, errCompactVault
+			}
+			vaultEnd = staged.End()
+			region = staged
+		}
+
+		if err := s.relocateRegion(tvault, region, cursor); err != nil {
+			return nil, errCompactVault
+		}
+		result.RegionsMoved++
+		cursor += region.Length
+	}
+
+	// everything past the cursor is now unreferenced: overwrite it before giving it back to the filesystem
+	if vaultEnd > cursor {
+		if err := filestoreutils.SecurelyOverwriteFileData(s.tvaultPath, cursor, vaultEnd-cursor); err != nil {
+			log("failed to overwrite TVault tail: %v", err)
+			return nil, errCompactVault
+		}
+	}
+	if err := tvault.Truncate(cursor); err != nil {
+		log("failed to truncate TVault: %v", err)
+		return nil, errCompactVault
+	}
+	if err := tvault.Sync(); err != nil {
+		log("failed to sync TVault: %v", err)
+		return nil, errCompactVault
+	}
+
+	result.SizeAfter = cursor
+	log("Compacted TVault from %d to %d bytes, relocated %d regions", result.SizeBefore, result.SizeAfter, result.RegionsMoved)
+	return result, nil
+}
+
+// relocateRegion copies a region's ciphertext to newOffset and then commits the new location to the database
+func (s *service) relocateRegion(tvault *os.File, region filestoreutils.VaultRegion, newOffset int64) error {
+	if err := filestoreutils.CopyVaultRegion(tvault, region.Offset, newOffset, region.Length); err != nil {
+		log("failed to copy region at offset %d: %v", region.Offset, err)
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := filestoreutils.RelocateRegion(tx, region, newOffset); err != nil {
+		log("failed to update offset of region at %d: %v", region.Offset, err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return err
+	}
+	return nil
+}
+
+var errFreeSpaceReport = errors.New("failed to get free space report")
+
+func (s *service) GetFreeSpaceReport() (*FreeSpaceReport, error) {
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
+
+	vaultInfo, err := os.Stat(s.tvaultPath)
+	if err != nil {
+		log("failed to stat TVault: %v", err)
+		return nil, errFreeSpaceReport
+	}
+	report := &FreeSpaceReport{VaultSize: vaultInfo.Size()}
+
+	report.FreeBytes, report.BlockCount, report.LargestBlock, err = filestoreutils.GetFreeSpaceStats(s.db)
+	if err != nil {
+		log("failed to query free spaces: %v", err)
+		return nil, errFreeSpaceReport
+	}
+	if report.FreeBytes > 0 {
+		report.Fragmentation = 1 - float64(report.LargestBlock)/float64(report.FreeBytes)
+	}
+	return report, nil
+}
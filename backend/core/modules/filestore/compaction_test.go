@@ -0,0 +1,133 @@
+package filestore
+
+import (
+	"os"
+	"testing"
+
+	"Tella-Desktop/backend/utils/constants"
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+// checkCompacted fails the test unless the TVault holds nothing but the live files, back to back
+func checkCompacted(t *testing.T, s *service) {
+	t.Helper()
+	var stored int64
+	if err := s.db.QueryRow("SELECT COALESCE(SUM(length), 0) FROM files WHERE is_deleted = 0").Scan(&stored); err != nil {
+		t.Fatalf("Failed to sum file lengths: %v", err)
+	}
+	info, err := os.Stat(s.tvaultPath)
+	if err != nil {
+		t.Fatalf("Failed to stat TVault: %v", err)
+	}
+	if want := constants.TVaultHeaderSize + stored; info.Size() != want {
+		t.Errorf("TVault has %d bytes, want %d", info.Size(), want)
+	}
+	var freeBlocks int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM free_spaces").Scan(&freeBlocks); err != nil {
+		t.Fatalf("Failed to count free spaces: %v", err)
+	}
+	if freeBlocks != 0 {
+		t.Errorf("%d free blocks are left after compaction", freeBlocks)
+	}
+	report, err := s.CheckVault(false)
+	if err != nil || len(report.Issues) != 0 {
+		t.Errorf("CheckVault() = %+v, %v, want no issues", report, err)
+	}
+}
+
+func TestCompactVaultClosesGaps(t *testing.T) {
+	s := setupServiceTest(t)
+	first, _ := storeTestFile(t, s, 1, "first.bin", 1000)
+	// the gap left by the first file is smaller than the second, which is therefore staged at the end of the TVault
+	second, secondData := storeTestFile(t, s, 1, "second.bin", 200*1024)
+	third, _ := storeTestFile(t, s, 1, "third.bin", 50*1024)
+	fourth, fourthData := storeTestFile(t, s, 1, "fourth.bin", 2000)
+	if err := s.DeleteFiles([]int64{first, third}); err != nil {
+		t.Fatalf("DeleteFiles() failed: %v", err)
+	}
+
+	result, err := s.CompactVault()
+	if err != nil {
+		t.Fatalf("CompactVault() failed: %v", err)
+	}
+	if result.RegionsMoved != 2 || result.SizeAfter >= result.SizeBefore {
+		t.Errorf("CompactVault() = %+v, want 2 regions moved and a smaller TVault", result)
+	}
+	checkTestFile(t, s, second, secondData)
+	checkTestFile(t, s, fourth, fourthData)
+	checkCompacted(t, s)
+
+	result, err = s.CompactVault()
+	if err != nil || result.RegionsMoved != 0 || result.SizeAfter != result.SizeBefore {
+		t.Errorf("CompactVault() on a compact TVault = %+v, %v, want nothing moved", result, err)
+	}
+}
+
+func TestCompactVaultAfterInterruptedRelocation(t *testing.T) {
+	s := setupServiceTest(t)
+	first, _ := storeTestFile(t, s, 1, "first.bin", 1000)
+	second, secondData := storeTestFile(t, s, 1, "second.bin", 200*1024)
+	third, thirdData := storeTestFile(t, s, 1, "third.bin", 3000)
+	if err := s.DeleteFiles([]int64{first}); err != nil {
+		t.Fatalf("DeleteFiles() failed: %v", err)
+	}
+
+	// a compaction that crashed after staging the second file at the end of the TVault, before recording its new offset
+	if _, err := s.db.Exec("DELETE FROM free_spaces"); err != nil {
+		t.Fatalf("Failed to clear free spaces: %v", err)
+	}
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, second)
+	if err != nil {
+		t.Fatalf("Failed to get file metadata: %v", err)
+	}
+	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open TVault: %v", err)
+	}
+	info, err := tvault.Stat()
+	if err == nil {
+		err = filestoreutils.CopyVaultRegion(tvault, metadata.Offset, info.Size(), metadata.Length)
+	}
+	tvault.Close()
+	if err != nil {
+		t.Fatalf("Failed to stage region: %v", err)
+	}
+
+	// the database still points at the original, which is intact
+	checkTestFile(t, s, second, secondData)
+	report, err := s.CheckVault(false)
+	if err != nil || len(report.Issues) != 2 {
+		t.Errorf("CheckVault() = %+v, %v, want the gap and the staged copy unreferenced", report, err)
+	}
+
+	if _, err := s.CompactVault(); err != nil {
+		t.Fatalf("CompactVault() failed: %v", err)
+	}
+	checkTestFile(t, s, second, secondData)
+	checkTestFile(t, s, third, thirdData)
+	checkCompacted(t, s)
+}
+
+func TestCompactVaultRefusesOverlappingFiles(t *testing.T) {
+	s := setupServiceTest(t)
+	first, _ := storeTestFile(t, s, 1, "first.bin", 1000)
+	second, _ := storeTestFile(t, s, 1, "second.bin", 1000)
+	if _, err := s.db.Exec("UPDATE files SET offset = offset - 10 WHERE id = ?", second); err != nil {
+		t.Fatalf("Failed to corrupt file offset: %v", err)
+	}
+	before, err := os.ReadFile(s.tvaultPath)
+	if err != nil {
+		t.Fatalf("Failed to read TVault: %v", err)
+	}
+
+	if _, err := s.CompactVault(); err == nil {
+		t.Fatalf("CompactVault() compacted a TVault with overlapping files %d and %d", first, second)
+	}
+	after, err := os.ReadFile(s.tvaultPath)
+	if err != nil {
+		t.Fatalf("Failed to read TVault: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("CompactVault() changed a TVault it refused to compact")
+	}
+}
@@ -0,0 +1,209 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/authutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"Tella-Desktop/backend/utils/transferutils"
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxContainerManifestSize bounds the manifest read from a container, which is held in memory
+const maxContainerManifestSize = 64 << 20
+
+// archiveFormat returns the archive format of an export, checking the passphrase of encrypted containers up front so
+// that a weak one is refused before anything is written
+func archiveFormat(options ExportOptions) (string, error) {
+	format, err := filestoreutils.NormalizeArchiveFormat(options.Format)
+	if err != nil {
+		return "", err
+	}
+	if format == filestoreutils.ArchiveEncrypted {
+		if err := authutils.CheckContainerPassphrase(options.Passphrase); err != nil {
+			return "", err
+		}
+	}
+	return format, nil
+}
+
+var errLooseExportFormat = errors.New("files exported one by one can't be archived or encrypted, export them as an archive instead")
+
+// checkLooseExport refuses an archive format or passphrase for an export of loose files, which would otherwise be
+// written unencrypted although the user asked for a container
+func checkLooseExport(options ExportOptions) error {
+	if options.Format != "" || options.Passphrase != "" {
+		return errLooseExportFormat
+	}
+	return nil
+}
+
+var errImportContainer = errors.New("failed to import the encrypted container")
+var errManifestMismatch = errors.New("file does not match the manifest of the container")
+
+// ImportContainer stores the files of a password-encrypted container written by another vault, see
+// filestoreutils.ArchiveEncrypted, into a new folder named after the container inside parentID, or at the top level if
+// parentID is 0. Every file is checked against the hash the manifest records for it. Progress is reported to the
+// frontend through "import-progress" events.
+func (s *service) ImportContainer(path, passphrase string, parentID int64) (*ImportResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		log("failed to open container: %v", err)
+		return nil, errImportContainer
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		log("failed to stat container: %v", err)
+		return nil, errImportContainer
+	}
+
+	reader, err := authutils.OpenContainer(file, info.Size(), passphrase)
+	if err != nil {
+		log("failed to open container: %v", err)
+		if errors.Is(err, authutils.ErrContainerHeader) || errors.Is(err, authutils.ErrContainerPassphrase) {
+			return nil, err
+		}
+		return nil, errImportContainer
+	}
+	defer reader.Close()
+
+	// the manifest is written after the files, so the archive is read twice: once for the manifest, skipping over the
+	// files, and once to store them
+	manifest, err := readContainerManifest(tar.NewReader(reader))
+	if err != nil {
+		log("failed to read container manifest: %v", err)
+		return nil, errImportContainer
+	}
+	entries := make(map[string]filestoreutils.ManifestEntry, len(manifest.Files))
+	progress := ImportProgress{}
+	for _, entry := range manifest.Files {
+		entries[entry.ExportedName] = entry
+		progress.FilesTotal++
+		progress.BytesTotal += entry.Size
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		log("failed to rewind container: %v", err)
+		return nil, errImportContainer
+	}
+
+	folderName := strings.TrimSuffix(filepath.Base(path), filestoreutils.ContainerExtension)
+	folderID, err := s.CreateFolder(folderName, parentID)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{FileIDs: []int64{}, FoldersCreated: 1, Failed: []ImportFailure{}}
+	s.emit("import-progress", progress)
+
+	archive := tar.NewReader(reader)
+	found := make(map[string]bool, len(entries))
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log("failed to read container: %v", err)
+			return result, errImportContainer
+		}
+		if header.Typeflag != tar.TypeReg || header.Name == filestoreutils.ManifestJSONName || header.Name == filestoreutils.ManifestCSVName {
+			continue
+		}
+
+		entry, listed := entries[header.Name]
+		if !listed {
+			result.Failed = append(result.Failed, ImportFailure{Path: header.Name, Error: "not listed in the manifest"})
+			continue
+		}
+		if found[header.Name] {
+			result.Failed = append(result.Failed, ImportFailure{Path: header.Name, Error: "in the container more than once"})
+			continue
+		}
+		found[header.Name] = true
+		progress.CurrentFile = header.Name
+		metadata, err := s.importContainerEntry(archive, header, entry, folderID)
+		if err != nil {
+			log("failed to import %s from container: %v", header.Name, err)
+			result.Failed = append(result.Failed, ImportFailure{Path: header.Name, Error: err.Error()})
+		} else {
+			result.FileIDs = append(result.FileIDs, metadata.ID)
+			if metadata.DuplicateOf != 0 {
+				result.Duplicates++
+			}
+		}
+		progress.FilesDone++
+		progress.BytesDone += entry.Size
+		s.emit("import-progress", progress)
+	}
+
+	// files the manifest lists that aren't in the archive were lost or removed since the export
+	for _, entry := range manifest.Files {
+		if !found[entry.ExportedName] {
+			found[entry.ExportedName] = true
+			result.Failed = append(result.Failed, ImportFailure{Path: entry.ExportedName, Error: "listed in the manifest but missing from the container"})
+		}
+	}
+
+	if len(result.FileIDs) == 0 && progress.FilesTotal > 0 {
+		log("all %d files failed to import", progress.FilesTotal)
+		return result, errImportContainer
+	}
+	log("Imported %d of %d files from container into folder %d", len(result.FileIDs), progress.FilesTotal, folderID)
+	return result, nil
+}
+
+// readContainerManifest finds the JSON manifest in the archive of a container
+func readContainerManifest(archive *tar.Reader) (*filestoreutils.Manifest, error) {
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("container has no manifest")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name != filestoreutils.ManifestJSONName {
+			continue
+		}
+		var manifest filestoreutils.Manifest
+		if err := json.NewDecoder(io.LimitReader(archive, maxContainerManifestSize)).Decode(&manifest); err != nil {
+			return nil, err
+		}
+		return &manifest, nil
+	}
+}
+
+// importContainerEntry stores a file of a container under the name, mimetype and transfer title the manifest records
+// for it
+func (s *service) importContainerEntry(archive io.Reader, header *tar.Header, entry filestoreutils.ManifestEntry, folderID int64) (*FileMetadata, error) {
+	if header.Size != entry.Size {
+		return nil, errManifestMismatch
+	}
+	// the manifest comes from whoever made the container, so a name that looks like a path is not kept
+	name := strings.TrimSpace(entry.OriginalName)
+	if !filestoreutils.ValidName(name) {
+		name = filestoreutils.SafeName(header.Name)
+	}
+	mimeType := entry.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	metadata, err := s.StoreFile(folderID, header.Size, entry.SHA256, name, mimeType, archive)
+	if err != nil {
+		if errors.Is(err, transferutils.ErrTransferHashMismatch) {
+			return nil, errManifestMismatch
+		}
+		return nil, err
+	}
+	if entry.TransferTitle != "" {
+		if err := s.SetTransferTitle(metadata.ID, entry.TransferTitle); err != nil {
+			log("failed to keep transfer title of %s: %v", header.Name, err)
+		}
+	}
+	return metadata, nil
+}
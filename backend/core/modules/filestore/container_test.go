@@ -0,0 +1,165 @@
+package filestore
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Tella-Desktop/backend/utils/authutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"Tella-Desktop/backend/utils/signutils"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+// writeTestContainer writes a container holding files by entry name, listed in the manifest under the given entries
+func writeTestContainer(t *testing.T, path string, files map[string][]byte, entries []filestoreutils.ManifestEntry) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer out.Close()
+	container, err := authutils.NewContainerWriter(out, testPassphrase)
+	if err != nil {
+		t.Fatalf("NewContainerWriter() failed: %v", err)
+	}
+	archive := tar.NewWriter(container)
+	write := func(name string, data []byte) {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := archive.Write(data); err != nil {
+			t.Fatalf("Failed to write tar entry: %v", err)
+		}
+	}
+	for name, data := range files {
+		write(name, data)
+	}
+	var manifest strings.Builder
+	if err := (&filestoreutils.Manifest{ExportedAt: "2026-03-02T08:30:00Z", Files: entries}).WriteJSON(&manifest); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	write(filestoreutils.ManifestJSONName, []byte(manifest.String()))
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed to close tar archive: %v", err)
+	}
+	if err := container.Close(); err != nil {
+		t.Fatalf("Failed to seal container: %v", err)
+	}
+}
+
+// manifestEntryFor describes data as the manifest of a container would
+func manifestEntryFor(originalName, exportedName string, data []byte) filestoreutils.ManifestEntry {
+	sum := sha256.Sum256(data)
+	return filestoreutils.ManifestEntry{
+		OriginalName: originalName,
+		ExportedName: exportedName,
+		Size:         int64(len(data)),
+		SHA256:       hex.EncodeToString(sum[:]),
+		MimeType:     "text/plain",
+	}
+}
+
+func TestImportContainerRoundTrip(t *testing.T) {
+	s := setupServiceTest(t)
+	if err := signutils.EnsureKey(s.db); err != nil {
+		t.Fatalf("EnsureKey() failed: %v", err)
+	}
+	cases := createTestFolder(t, s, "Cases", 0)
+	first, firstData := storeTestFile(t, s, cases, "first.bin", 1000)
+	second, secondData := storeTestFile(t, s, cases, "second.bin", 70*1024)
+	if err := s.SetTransferTitle(first, "Interview"); err != nil {
+		t.Fatalf("SetTransferTitle() failed: %v", err)
+	}
+
+	exportDir := t.TempDir()
+	paths, err := s.ExportZipFoldersWithOptions([]int64{cases}, nil, ExportOptions{
+		Destination: exportDir, Format: filestoreutils.ArchiveEncrypted, Passphrase: testPassphrase,
+	})
+	if err != nil {
+		t.Fatalf("ExportZipFoldersWithOptions() failed: %v", err)
+	}
+	containerPath := paths[0]
+	if !strings.HasSuffix(containerPath, filestoreutils.ContainerExtension) {
+		t.Fatalf("Exported %v, want a container first", paths)
+	}
+
+	if _, err := s.ImportContainer(containerPath, "wrong passphrase!", 0); !errors.Is(err, authutils.ErrContainerPassphrase) {
+		t.Errorf("ImportContainer() with a wrong passphrase = %v, want %v", err, authutils.ErrContainerPassphrase)
+	}
+
+	result, err := s.ImportContainer(containerPath, testPassphrase, 0)
+	if err != nil {
+		t.Fatalf("ImportContainer() failed: %v", err)
+	}
+	if len(result.FileIDs) != 2 || len(result.Failed) != 0 || result.FoldersCreated != 1 {
+		t.Fatalf("ImportContainer() = %+v, want two files imported into a new folder", result)
+	}
+	for _, fileID := range result.FileIDs {
+		metadata, err := filestoreutils.GetFileMetadataByID(s.db, fileID)
+		if err != nil {
+			t.Fatalf("Imported file %d is not live: %v", fileID, err)
+		}
+		switch metadata.Name {
+		case "first.bin":
+			checkTestFile(t, s, fileID, firstData)
+			var title string
+			if err := s.db.QueryRow("SELECT transfer_title FROM files WHERE id = ?", fileID).Scan(&title); err != nil || title != "Interview" {
+				t.Errorf("Transfer title of imported file = %q, %v, want %q", title, err, "Interview")
+			}
+		case "second.bin":
+			checkTestFile(t, s, fileID, secondData)
+		default:
+			t.Errorf("Imported a file named %q, want the names of files %d and %d", metadata.Name, first, second)
+		}
+		if metadata.FolderID == cases {
+			t.Errorf("Imported file %d into the exported folder, want a new one", fileID)
+		}
+	}
+}
+
+func TestImportContainerDistrustsManifest(t *testing.T) {
+	s := setupServiceTest(t)
+	evidence := []byte("evidence")
+	notes := []byte("notes")
+	other := []byte("another file")
+	containerPath := filepath.Join(t.TempDir(), "Received"+filestoreutils.ContainerExtension)
+	writeTestContainer(t, containerPath, map[string][]byte{
+		"evidence.txt": evidence, "notes.txt": notes, "unlisted.txt": other,
+	}, []filestoreutils.ManifestEntry{
+		manifestEntryFor("../../escape.txt", "evidence.txt", evidence),
+		// the hash the manifest records for notes is that of another file
+		manifestEntryFor("notes.txt", "notes.txt", other[:len(notes)]),
+		// a file removed from the archive since the export
+		manifestEntryFor("missing.txt", "missing.txt", other),
+	})
+
+	result, err := s.ImportContainer(containerPath, testPassphrase, 1)
+	if err != nil {
+		t.Fatalf("ImportContainer() failed: %v", err)
+	}
+	if len(result.FileIDs) != 1 || len(result.Failed) != 3 {
+		t.Fatalf("ImportContainer() = %+v, want one file imported and three failed", result)
+	}
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, result.FileIDs[0])
+	if err != nil {
+		t.Fatalf("Imported file is not live: %v", err)
+	}
+	if metadata.Name != "evidence.txt" {
+		t.Errorf("Imported file is named %q, want the entry name instead of the path in the manifest", metadata.Name)
+	}
+	checkTestFile(t, s, metadata.ID, evidence)
+	failed := map[string]bool{}
+	for _, failure := range result.Failed {
+		failed[failure.Path] = true
+	}
+	if !failed["notes.txt"] || !failed["unlisted.txt"] || !failed["missing.txt"] {
+		t.Errorf("ImportContainer() failed %+v, want the mismatching, the unlisted and the missing file", result.Failed)
+	}
+}
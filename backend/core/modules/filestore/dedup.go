@@ -0,0 +1,72 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/config"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"time"
+)
+
+// SetDuplicatePolicy changes what StoreFile does with content that is already stored. It waits for a store in
+// progress to finish, so that a store sees a single policy throughout.
+func (s *service) SetDuplicatePolicy(policy string) {
+	s.vaultMu.Lock()
+	defer s.vaultMu.Unlock()
+	s.duplicatePolicy = policy
+}
+
+// storeDuplicate adds a file whose content is already stored as existingID according to the duplicate policy: under
+// the link policy the file gets a row of its own sharing the stored ciphertext, under the skip policy the existing
+// file is returned in its place. The caller must hold the vault write lock, and must not have a transaction open.
+func (s *service) storeDuplicate(existingID int64, fileUUID, fileName, mimeType string, folderID int64) (*FileMetadata, error) {
+	existing, err := filestoreutils.GetFileMetadataByID(s.db, existingID)
+	if err != nil {
+		return nil, errStoreFile
+	}
+
+	if s.duplicatePolicy == config.DuplicatePolicySkip {
+		log("Skipped storing %s, its content is already stored as file %d", fileName, existingID)
+		return &FileMetadata{
+			ID:          existingID,
+			Name:        existing.Name,
+			Size:        existing.Size,
+			MimeType:    existing.MimeType,
+			FolderID:    existing.FolderID,
+			Offset:      existing.Offset,
+			Length:      existing.Length,
+			SHA256:      existing.SHA256,
+			DuplicateOf: existingID,
+		}, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return nil, errStoreFile
+	}
+	defer tx.Rollback()
+
+	fileID, err := filestoreutils.InsertLinkedFile(tx, fileUUID, fileName, mimeType, folderID, existingID)
+	if err != nil {
+		log("failed to link file to file %d: %v", existingID, err)
+		return nil, errStoreFile
+	}
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return nil, errStoreFile
+	}
+
+	log("Stored file %s (%s) sharing the content of file %d", fileName, fileUUID, existingID)
+	return &FileMetadata{
+		ID:          fileID,
+		UUID:        fileUUID,
+		Name:        fileName,
+		Size:        existing.Size,
+		MimeType:    mimeType,
+		FolderID:    folderID,
+		Offset:      existing.Offset,
+		Length:      existing.Length,
+		SHA256:      existing.SHA256,
+		CreatedAt:   time.Now(),
+		DuplicateOf: existingID,
+	}, nil
+}
@@ -0,0 +1,35 @@
+package filestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"Tella-Desktop/backend/utils/config"
+)
+
+func TestSetDuplicatePolicyAppliesToLaterStores(t *testing.T) {
+	s := setupServiceTest(t)
+	data := []byte("the same statement, sent twice")
+	sum := sha256.Sum256(data)
+	store := func() *FileMetadata {
+		t.Helper()
+		metadata, err := s.StoreFile(1, int64(len(data)), hex.EncodeToString(sum[:]), "statement.txt", "text/plain", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("StoreFile() failed: %v", err)
+		}
+		return metadata
+	}
+
+	original := store()
+	if second := store(); second.DuplicateOf != 0 || second.ID == original.ID {
+		t.Errorf("StoreFile() under the store policy = %+v, want a file of its own", second)
+	}
+
+	s.SetDuplicatePolicy(config.DuplicatePolicySkip)
+	if skipped := store(); skipped.DuplicateOf != original.ID || skipped.ID != original.ID {
+		t.Errorf("StoreFile() under the skip policy = %+v, want file %d returned", skipped, original.ID)
+	}
+	checkTestFile(t, s, original.ID, data)
+}
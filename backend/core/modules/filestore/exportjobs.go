@@ -0,0 +1,166 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// exportProgressInterval is how often a job reports the bytes it writes; starting and finishing a file is always
+// reported
+const exportProgressInterval = 250 * time.Millisecond
+
+// exportJob is an export running in the background. It is the filestoreutils.ExportTracker of its export, and stops
+// it once cancelled or once the vault is locked. A nil *exportJob is an export that isn't a job.
+type exportJob struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     <-chan struct{}
+	progress ExportProgress
+	lastEmit time.Time
+	emit     func(ExportProgress)
+}
+
+func (j *exportJob) Err() error {
+	if j == nil {
+		return nil
+	}
+	select {
+	case <-j.ctx.Done():
+		return filestoreutils.ErrExportCancelled
+	case <-j.done:
+		return filestoreutils.ErrExportCancelled
+	default:
+		return nil
+	}
+}
+
+func (j *exportJob) FileStarted(name string) {
+	j.progress.CurrentFile = name
+	j.report()
+}
+
+func (j *exportJob) Written(n int64) {
+	j.progress.BytesDone += n
+	if time.Since(j.lastEmit) >= exportProgressInterval {
+		j.report()
+	}
+}
+
+func (j *exportJob) FileDone() {
+	j.progress.FilesDone++
+	j.report()
+}
+
+// tracker returns the job as the tracker of its export, or nil if the export isn't a job
+func (j *exportJob) tracker() filestoreutils.ExportTracker {
+	if j == nil {
+		return nil
+	}
+	return j
+}
+
+// planned sets the size of the export, once known
+func (j *exportJob) planned(files int, bytes int64) {
+	if j == nil {
+		return
+	}
+	j.progress.FilesTotal = files
+	j.progress.BytesTotal = bytes
+	j.report()
+}
+
+func (j *exportJob) report() {
+	j.lastEmit = time.Now()
+	j.emit(j.progress)
+}
+
+// finish reports the outcome of the job
+func (j *exportJob) finish(paths []string, err error) {
+	switch {
+	case errors.Is(err, filestoreutils.ErrExportCancelled):
+		j.progress.Status = ExportCancelled
+	case err != nil:
+		j.progress.Status = ExportFailed
+		j.progress.Error = err.Error()
+	default:
+		j.progress.Status = ExportCompleted
+		j.progress.Paths = paths
+	}
+	j.progress.CurrentFile = ""
+	j.report()
+}
+
+// removeExports removes the files written by an export that was cancelled
+func removeExports(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log("failed to remove %s: %v", path, err)
+		}
+	}
+}
+
+var errVaultLocked = errors.New("the vault is locked")
+
+// startExportJob runs an export in the background until it finishes, is cancelled or the vault is locked
+func (s *service) startExportJob(export func(job *exportJob) ([]string, error)) (string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	jobID := uuid.New().String()
+	job := &exportJob{
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     s.done,
+		progress: ExportProgress{JobID: jobID, Status: ExportRunning},
+		emit: func(progress ExportProgress) {
+			s.emit("export-progress", progress)
+		},
+	}
+	s.exportJobs.Store(jobID, job)
+
+	started := s.goBackground(func() {
+		defer s.exportJobs.Delete(jobID)
+		defer cancel()
+		paths, err := export(job)
+		job.finish(paths, err)
+		log("Export job %s %s", jobID, job.progress.Status)
+	})
+	if !started {
+		s.exportJobs.Delete(jobID)
+		cancel()
+		return "", errVaultLocked
+	}
+	return jobID, nil
+}
+
+func (s *service) StartExportFiles(ids []int64, options ExportOptions) (string, error) {
+	if err := checkLooseExport(options); err != nil {
+		return "", err
+	}
+	return s.startExportJob(func(job *exportJob) ([]string, error) {
+		return s.exportFiles(ids, options, job)
+	})
+}
+
+func (s *service) StartExportZipFolders(folderIDs []int64, selectedFileIDs []int64, options ExportOptions) (string, error) {
+	return s.startExportJob(func(job *exportJob) ([]string, error) {
+		return s.exportZipFolders(folderIDs, selectedFileIDs, options, job)
+	})
+}
+
+var errExportNotFound = errors.New("export not found")
+
+// CancelExport stops an export job. The job reports its cancellation once it has removed the files it wrote.
+func (s *service) CancelExport(jobID string) error {
+	value, ok := s.exportJobs.Load(jobID)
+	if !ok {
+		log("no export job with ID: %s", jobID)
+		return errExportNotFound
+	}
+	value.(*exportJob).cancel()
+	log("Cancelling export job %s", jobID)
+	return nil
+}
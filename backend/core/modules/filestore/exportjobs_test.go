@@ -0,0 +1,199 @@
+package filestore
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"Tella-Desktop/backend/utils/signutils"
+)
+
+// recordExportProgress replaces the frontend with a recorder of the "export-progress" events of one job. onProgress,
+// if set, is called with every event on the goroutine of the job, before the event is recorded. The returned function
+// waits for the job to end and returns its events.
+func recordExportProgress(t *testing.T, onProgress func(ExportProgress)) func() []ExportProgress {
+	t.Helper()
+	var mu sync.Mutex
+	var events []ExportProgress
+	ended := make(chan struct{})
+	emitEvent = func(_ context.Context, eventName string, data ...interface{}) {
+		if eventName != "export-progress" {
+			return
+		}
+		progress := data[0].(ExportProgress)
+		if onProgress != nil {
+			onProgress(progress)
+		}
+		mu.Lock()
+		events = append(events, progress)
+		mu.Unlock()
+		if progress.Status != ExportRunning {
+			close(ended)
+		}
+	}
+	return func() []ExportProgress {
+		t.Helper()
+		select {
+		case <-ended:
+		case <-time.After(10 * time.Second):
+			t.Fatal("Export job didn't end")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return events
+	}
+}
+
+// checkExportDirEmpty fails the test unless an export left nothing behind
+func checkExportDirEmpty(t *testing.T, exportDir string) {
+	t.Helper()
+	if entries, err := os.ReadDir(exportDir); err != nil || len(entries) != 0 {
+		t.Errorf("Export directory holds %v, %v, want nothing left behind", entries, err)
+	}
+}
+
+func TestExportJobReportsProgress(t *testing.T) {
+	s := setupServiceTest(t)
+	first, _ := storeTestFile(t, s, 1, "first.bin", 1000)
+	second, _ := storeTestFile(t, s, 1, "second.bin", 2000)
+	third, _ := storeTestFile(t, s, 1, "third.bin", 3000)
+	exportDir := t.TempDir()
+	wait := recordExportProgress(t, nil)
+
+	jobID, err := s.StartExportFiles([]int64{first, second, third}, ExportOptions{Destination: exportDir})
+	if err != nil {
+		t.Fatalf("StartExportFiles() failed: %v", err)
+	}
+	events := wait()
+
+	filesDone, bytesDone := 0, int64(0)
+	for _, progress := range events {
+		if progress.JobID != jobID {
+			t.Fatalf("Event %+v is not of job %s", progress, jobID)
+		}
+		if progress.FilesDone < filesDone || progress.BytesDone < bytesDone {
+			t.Errorf("Event %+v went back from %d files and %d bytes", progress, filesDone, bytesDone)
+		}
+		filesDone, bytesDone = progress.FilesDone, progress.BytesDone
+	}
+	last := events[len(events)-1]
+	if last.Status != ExportCompleted || last.FilesDone != 3 || last.FilesTotal != 3 || last.BytesDone != 6000 ||
+		last.BytesTotal != 6000 || last.CurrentFile != "" || len(last.Paths) != 3 {
+		t.Fatalf("Last event = %+v, want all 3 files and 6000 bytes completed", last)
+	}
+	for _, path := range last.Paths {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Exported file %s: %v", path, err)
+		}
+	}
+
+	// a job is forgotten once it ended
+	s.background.Wait()
+	if err := s.CancelExport(jobID); err != errExportNotFound {
+		t.Errorf("CancelExport() of an ended job = %v, want %v", err, errExportNotFound)
+	}
+}
+
+func TestCancelExportMidFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		start func(s *service, folderID int64, ids []int64, exportDir string) (string, error)
+	}{
+		{"files", func(s *service, _ int64, ids []int64, exportDir string) (string, error) {
+			return s.StartExportFiles(ids, ExportOptions{Destination: exportDir})
+		}},
+		{"zip", func(s *service, folderID int64, _ []int64, exportDir string) (string, error) {
+			return s.StartExportZipFolders([]int64{folderID}, nil, ExportOptions{Destination: exportDir})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := setupServiceTest(t)
+			if err := signutils.EnsureKey(s.db); err != nil {
+				t.Fatalf("EnsureKey() failed: %v", err)
+			}
+			folderID := createTestFolder(t, s, "Cases", 0)
+			first, _ := storeTestFile(t, s, folderID, "first.bin", 1000)
+			second, _ := storeTestFile(t, s, folderID, "second.bin", 3*1024*1024)
+			exportDir := t.TempDir()
+
+			// cancel once the second file has been started on disk
+			var cancelErr error
+			cancelled := false
+			wait := recordExportProgress(t, func(progress ExportProgress) {
+				if cancelled || progress.CurrentFile != "second.bin" {
+					return
+				}
+				cancelled = true
+				if entries, err := os.ReadDir(exportDir); err != nil || len(entries) == 0 {
+					t.Errorf("Export directory holds %v, %v while exporting, want the export under way", entries, err)
+				}
+				cancelErr = s.CancelExport(progress.JobID)
+			})
+
+			if _, err := tt.start(s, folderID, []int64{first, second}, exportDir); err != nil {
+				t.Fatalf("Starting the export failed: %v", err)
+			}
+			events := wait()
+
+			if !cancelled || cancelErr != nil {
+				t.Fatalf("CancelExport() = %v after the second file started (%v), want it cancelled", cancelErr, cancelled)
+			}
+			last := events[len(events)-1]
+			if last.Status != ExportCancelled || last.FilesDone >= last.FilesTotal || len(last.Paths) != 0 {
+				t.Errorf("Last event = %+v, want cancelled before the second file was done", last)
+			}
+			checkExportDirEmpty(t, exportDir)
+		})
+	}
+}
+
+func TestLockStopsExportJob(t *testing.T) {
+	s := setupServiceTest(t)
+	first, _ := storeTestFile(t, s, 1, "first.bin", 1000)
+	second, _ := storeTestFile(t, s, 1, "second.bin", 1000)
+	exportDir := t.TempDir()
+
+	// hold the job inside the second file until the vault is being locked
+	started := make(chan struct{})
+	resume := make(chan struct{})
+	wait := recordExportProgress(t, func(progress ExportProgress) {
+		if progress.CurrentFile == "second.bin" && progress.FilesDone == 1 {
+			close(started)
+			<-resume
+		}
+	})
+	if _, err := s.StartExportFiles([]int64{first, second}, ExportOptions{Destination: exportDir}); err != nil {
+		t.Fatalf("StartExportFiles() failed: %v", err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Export job didn't reach the second file")
+	}
+	locked := make(chan struct{})
+	go func() {
+		s.Lock()
+		close(locked)
+	}()
+	<-s.done
+	close(resume)
+
+	select {
+	case <-locked:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Lock() didn't return")
+	}
+	events := wait()
+	if last := events[len(events)-1]; last.Status != ExportCancelled {
+		t.Errorf("Last event = %+v, want the job cancelled by Lock()", last)
+	}
+	checkExportDirEmpty(t, exportDir)
+
+	if _, err := s.StartExportFiles([]int64{first}, ExportOptions{Destination: exportDir}); err != errVaultLocked {
+		t.Errorf("StartExportFiles() after Lock() = %v, want %v", err, errVaultLocked)
+	}
+}
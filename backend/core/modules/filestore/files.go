@@ -0,0 +1,227 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errRenameFile     = errors.New("failed to rename file")
+	errMoveFiles      = errors.New("failed to move files")
+	errCopyFiles      = errors.New("failed to copy files")
+	errFileName       = errors.New("file names must not be empty, \".\" or \"..\", or contain slashes")
+	errFolderNotFound = errors.New("folder not found")
+)
+
+func (s *service) RenameFile(fileID int64, name string) error {
+	name = strings.TrimSpace(name)
+	if !filestoreutils.ValidName(name) {
+		return errFileName
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE files SET name = ?, updated_at = datetime('now')
+		WHERE id = ? AND is_deleted = 0
+	`, name, fileID)
+	if err != nil {
+		log("failed to rename file %d: %v", fileID, err)
+		return errRenameFile
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("file not found with ID: %d", fileID)
+		return errRenameFile
+	}
+	s.audit(auditutils.ActionFileRenamed, map[string]any{"fileId": fileID, "name": name})
+	return nil
+}
+
+var errTransferTitle = errors.New("failed to record transfer title")
+
+// SetTransferTitle records the title of the transfer a file was received in, for export manifests
+func (s *service) SetTransferTitle(fileID int64, title string) error {
+	result, err := s.db.Exec("UPDATE files SET transfer_title = ? WHERE id = ? AND is_deleted = 0", title, fileID)
+	if err != nil {
+		log("failed to set transfer title of file %d: %v", fileID, err)
+		return errTransferTitle
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("file not found with ID: %d", fileID)
+		return errTransferTitle
+	}
+	return nil
+}
+
+func (s *service) MoveFiles(fileIDs []int64, folderID int64) error {
+	if len(fileIDs) == 0 {
+		log("no file IDs provided")
+		return errMoveFiles
+	}
+	exists, err := s.folderExists(folderID)
+	if err != nil {
+		log("failed to look up folder %d: %v", folderID, err)
+		return errMoveFiles
+	}
+	if !exists {
+		log("folder not found with ID: %d", folderID)
+		return errFolderNotFound
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errMoveFiles
+	}
+	defer tx.Rollback()
+
+	for _, fileID := range fileIDs {
+		result, err := tx.Exec(`
+			UPDATE files SET folder_id = ?, updated_at = datetime('now')
+			WHERE id = ? AND is_deleted = 0
+		`, folderID, fileID)
+		if err != nil {
+			log("failed to move file %d: %v", fileID, err)
+			return errMoveFiles
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			log("file not found with ID: %d", fileID)
+			return errMoveFiles
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return errMoveFiles
+	}
+	s.audit(auditutils.ActionFilesMoved, map[string]any{"fileIds": fileIDs, "folderId": folderID})
+	return nil
+}
+
+func (s *service) CopyFiles(fileIDs []int64, folderID int64) ([]int64, error) {
+	if len(fileIDs) == 0 {
+		log("no file IDs provided")
+		return nil, errCopyFiles
+	}
+	exists, err := s.folderExists(folderID)
+	if err != nil {
+		log("failed to look up folder %d: %v", folderID, err)
+		return nil, errCopyFiles
+	}
+	if !exists {
+		log("folder not found with ID: %d", folderID)
+		return nil, errFolderNotFound
+	}
+
+	s.vaultMu.Lock()
+	defer s.vaultMu.Unlock()
+
+	var copiedIDs []int64
+	for _, fileID := range fileIDs {
+		copiedID, err := s.copyFile(fileID, folderID)
+		if err != nil {
+			// files copied so far are kept, they are complete copies
+			log("failed to copy file %d after copying %d files", fileID, len(copiedIDs))
+			s.auditCopies(fileIDs[:len(copiedIDs)], copiedIDs, folderID)
+			return copiedIDs, errCopyFiles
+		}
+		copiedIDs = append(copiedIDs, copiedID)
+	}
+
+	log("Copied %d files to folder %d", len(copiedIDs), folderID)
+	s.auditCopies(fileIDs, copiedIDs, folderID)
+	return copiedIDs, nil
+}
+
+// auditCopies records the files copied into folderID, each original with the ID of its copy at the same index
+func (s *service) auditCopies(fileIDs, copiedIDs []int64, folderID int64) {
+	if len(copiedIDs) == 0 {
+		return
+	}
+	s.audit(auditutils.ActionFilesCopied, map[string]any{"fileIds": fileIDs, "copyIds": copiedIDs, "folderId": folderID})
+}
+
+// copyFile decrypts a file and stores its plaintext again under a new UUID, and thereby a new key. The caller must hold
+// the vault write lock.
+func (s *service) copyFile(fileID, folderID int64) (int64, error) {
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, fileID)
+	if err != nil {
+		return 0, err
+	}
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		log("failed to open TVault: %v", err)
+		return 0, err
+	}
+	defer tvault.Close()
+
+	reader, err := filestoreutils.OpenFileReader(metadata, s.dbKey, tvault)
+	if err != nil {
+		log("failed to decrypt file %d: %v", fileID, err)
+		return 0, err
+	}
+	defer reader.Close()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	copyUUID := uuid.New().String()
+	region, err := s.writeEncryptedFile(tx, copyUUID, reader.Size(), reader)
+	if err != nil {
+		log("failed to write copy of file %d: %v", fileID, err)
+		return 0, err
+	}
+
+	// the copy must be an exact copy of what was originally received
+	sum := fmt.Sprintf("%x", region.sum)
+	if metadata.SHA256 != "" && sum != strings.ToLower(metadata.SHA256) {
+		log("file %d does not match its recorded hash", fileID)
+		s.discardRegion(region)
+		return 0, filestoreutils.ErrHashMismatch
+	}
+
+	// the thumbnail is encrypted under the copy's UUID as well, so it is generated anew
+	thumbnail := s.writeThumbnail(tx, copyUUID, metadata.MimeType, region)
+
+	copyID, err := filestoreutils.InsertFileMetadata(tx, copyUUID, metadata.Name, reader.Size(), metadata.MimeType, folderID, region.offset, region.length, filestoreutils.FormatChunked, sum)
+	if err == nil {
+		err = recordThumbnail(tx, copyID, thumbnail)
+	}
+	if err == nil {
+		err = filestoreutils.CopyFileAnnotations(tx, fileID, copyID)
+	}
+	if err == nil {
+		// a copy keeps the provenance of the original: the transfer it arrived in and when it was received
+		_, err = tx.Exec(`
+			UPDATE files SET
+				transfer_title = (SELECT transfer_title FROM files WHERE id = ?),
+				created_at = (SELECT created_at FROM files WHERE id = ?)
+			WHERE id = ?
+		`, fileID, fileID, copyID)
+	}
+	if err != nil {
+		log("failed to insert file metadata: %v", err)
+		s.discardThumbnail(thumbnail)
+		s.discardRegion(region)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		s.discardThumbnail(thumbnail)
+		s.discardRegion(region)
+		return 0, err
+	}
+
+	log("Copied file %d to file %d (%s)", fileID, copyID, copyUUID)
+	return copyID, nil
+}
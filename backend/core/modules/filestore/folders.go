@@ -0,0 +1,228 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Folders form a tree through folders.parent_id, where a NULL parent_id marks a top-level folder. In the API, parent ID
+// 0 stands for "no parent".
+
+var (
+	errCreateFolder  = errors.New("failed to create folder")
+	errRenameFolder  = errors.New("failed to rename folder")
+	errMoveFolder    = errors.New("failed to move folder")
+	errGetFolderTree = errors.New("failed to get folder tree")
+	errFolderName    = errors.New("folder names must not be empty, \".\" or \"..\", or contain slashes")
+	errFolderCycle   = errors.New("a folder can't be moved into itself or one of its subfolders")
+)
+
+// nullableParent maps the API's parent ID 0 to a NULL parent_id
+func nullableParent(parentID int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: parentID, Valid: parentID != 0}
+}
+
+func (s *service) folderExists(folderID int64) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM folders WHERE id = ?)", folderID).Scan(&exists)
+	return exists, err
+}
+
+func (s *service) CreateFolder(name string, parentID int64) (int64, error) {
+	name = strings.TrimSpace(name)
+	if !filestoreutils.ValidName(name) {
+		return 0, errFolderName
+	}
+	if parentID != 0 {
+		exists, err := s.folderExists(parentID)
+		if err != nil {
+			log("failed to look up parent folder %d: %v", parentID, err)
+			return 0, errCreateFolder
+		}
+		if !exists {
+			log("parent folder %d does not exist", parentID)
+			return 0, errCreateFolder
+		}
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO folders (name, parent_id, created_at, updated_at)
+		VALUES (?, ?, datetime('now'), datetime('now'))
+	`, name, nullableParent(parentID))
+	if err != nil {
+		log("failed to create folder: %v", err)
+		return 0, errCreateFolder
+	}
+
+	folderID, err := result.LastInsertId()
+	if err != nil {
+		log("failed to get folder ID: %v", err)
+		return 0, errCreateFolder
+	}
+
+	log("Created folder '%s' with ID: %d", name, folderID)
+	return folderID, nil
+}
+
+func (s *service) RenameFolder(folderID int64, name string) error {
+	name = strings.TrimSpace(name)
+	if !filestoreutils.ValidName(name) {
+		return errFolderName
+	}
+
+	result, err := s.db.Exec("UPDATE folders SET name = ? WHERE id = ?", name, folderID)
+	if err != nil {
+		log("failed to rename folder %d: %v", folderID, err)
+		return errRenameFolder
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("folder not found with ID: %d", folderID)
+		return errRenameFolder
+	}
+	s.audit(auditutils.ActionFolderRenamed, map[string]any{"folderId": folderID, "name": name})
+	return nil
+}
+
+func (s *service) MoveFolder(folderID, newParentID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errMoveFolder
+	}
+	defer tx.Rollback()
+
+	if newParentID != 0 {
+		// walk up from the new parent: if we pass the folder being moved, the move would create a cycle
+		var wouldCycle bool
+		err := tx.QueryRow(`
+			WITH RECURSIVE ancestors(id) AS (
+				SELECT id FROM folders WHERE id = ?
+				UNION
+				SELECT folders.parent_id FROM folders
+				JOIN ancestors ON folders.id = ancestors.id
+				WHERE folders.parent_id IS NOT NULL
+			)
+			SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = ?)
+		`, newParentID, folderID).Scan(&wouldCycle)
+		if err != nil {
+			log("failed to look up ancestors of folder %d: %v", newParentID, err)
+			return errMoveFolder
+		}
+		if wouldCycle {
+			return errFolderCycle
+		}
+
+		var parentExists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM folders WHERE id = ?)", newParentID).Scan(&parentExists); err != nil || !parentExists {
+			log("parent folder %d does not exist", newParentID)
+			return errMoveFolder
+		}
+	}
+
+	result, err := tx.Exec("UPDATE folders SET parent_id = ? WHERE id = ?", nullableParent(newParentID), folderID)
+	if err != nil {
+		log("failed to move folder %d: %v", folderID, err)
+		return errMoveFolder
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("folder not found with ID: %d", folderID)
+		return errMoveFolder
+	}
+
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return errMoveFolder
+	}
+	s.audit(auditutils.ActionFolderMoved, map[string]any{"folderId": folderID, "parentId": newParentID})
+	return nil
+}
+
+func (s *service) GetFolderTree() ([]*FolderNode, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			f.id,
+			f.name,
+			COALESCE(f.parent_id, 0),
+			f.created_at,
+			COUNT(files.id) as file_count
+		FROM folders f
+		LEFT JOIN files ON f.id = files.folder_id AND files.is_deleted = 0
+		GROUP BY f.id, f.name, f.parent_id, f.created_at
+		ORDER BY f.name COLLATE NOCASE ASC, f.id ASC
+	`)
+	if err != nil {
+		log("failed to query folders: %v", err)
+		return nil, errGetFolderTree
+	}
+	defer rows.Close()
+
+	var nodes []*FolderNode
+	byID := map[int64]*FolderNode{}
+	for rows.Next() {
+		node := &FolderNode{Children: []*FolderNode{}}
+		if err := rows.Scan(&node.ID, &node.Name, &node.ParentID, &node.Timestamp, &node.FileCount); err != nil {
+			log("failed to scan folder: %v", err)
+			return nil, errGetFolderTree
+		}
+		nodes = append(nodes, node)
+		byID[node.ID] = node
+	}
+	if err := rows.Err(); err != nil {
+		log("error iterating folders: %v", err)
+		return nil, errGetFolderTree
+	}
+
+	roots := []*FolderNode{}
+	for _, node := range nodes {
+		parent, ok := byID[node.ParentID]
+		if !ok {
+			// top-level folder, or an orphan whose parent no longer exists
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	return roots, nil
+}
+
+// getFolderSubtrees returns the given folders and all of their subfolders, ordered so that every folder comes after
+// all of its subfolders
+func (s *service) getFolderSubtrees(folderIDs []int64) ([]int64, error) {
+	seen := map[int64]bool{}
+	var ordered []int64
+	for _, folderID := range folderIDs {
+		rows, err := s.db.Query(`
+			WITH RECURSIVE subtree(id, depth) AS (
+				SELECT id, 0 FROM folders WHERE id = ?
+				UNION
+				SELECT folders.id, subtree.depth + 1 FROM folders
+				JOIN subtree ON folders.parent_id = subtree.id
+				WHERE subtree.depth < 1000
+			)
+			SELECT id FROM subtree ORDER BY depth DESC
+		`, folderID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !seen[id] {
+				seen[id] = true
+				ordered = append(ordered, id)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
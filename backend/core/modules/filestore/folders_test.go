@@ -0,0 +1,101 @@
+package filestore
+
+import (
+	"testing"
+)
+
+// createTestFolder creates a folder inside parentID, failing the test if it can't
+func createTestFolder(t *testing.T, s *service, name string, parentID int64) int64 {
+	t.Helper()
+	folderID, err := s.CreateFolder(name, parentID)
+	if err != nil {
+		t.Fatalf("CreateFolder(%q, %d) failed: %v", name, parentID, err)
+	}
+	return folderID
+}
+
+func TestMoveFolderRefusesCycles(t *testing.T) {
+	s := setupServiceTest(t)
+	cases := createTestFolder(t, s, "Cases", 0)
+	open := createTestFolder(t, s, "Open", cases)
+	witnesses := createTestFolder(t, s, "Witnesses", open)
+
+	for name, move := range map[string]struct{ folderID, parentID int64 }{
+		"into itself":              {cases, cases},
+		"into its child":           {cases, open},
+		"into its grandchild":      {cases, witnesses},
+		"child into its own child": {open, witnesses},
+	} {
+		if err := s.MoveFolder(move.folderID, move.parentID); err != errFolderCycle {
+			t.Errorf("MoveFolder() %s = %v, want %v", name, err, errFolderCycle)
+		}
+	}
+
+	if err := s.MoveFolder(witnesses, 0); err != nil {
+		t.Fatalf("MoveFolder() to the top level failed: %v", err)
+	}
+	if err := s.MoveFolder(cases, witnesses); err != nil {
+		t.Fatalf("MoveFolder() into a former grandchild failed: %v", err)
+	}
+	if err := s.MoveFolder(witnesses, open); err != errFolderCycle {
+		t.Errorf("MoveFolder() into a folder moved beneath it = %v, want %v", err, errFolderCycle)
+	}
+	if err := s.MoveFolder(open, 999); err == nil {
+		t.Errorf("MoveFolder() into a missing folder succeeded")
+	}
+
+	tree, err := s.GetFolderTree()
+	if err != nil {
+		t.Fatalf("GetFolderTree() failed: %v", err)
+	}
+	// Received Files, and Witnesses > Cases > Open
+	if len(tree) != 2 || tree[1].ID != witnesses || len(tree[1].Children) != 1 || tree[1].Children[0].ID != cases ||
+		len(tree[1].Children[0].Children) != 1 || tree[1].Children[0].Children[0].ID != open {
+		t.Errorf("GetFolderTree() = %+v, want Witnesses > Cases > Open", tree)
+	}
+}
+
+func TestFolderNamesMustNotLookLikePaths(t *testing.T) {
+	s := setupServiceTest(t)
+	folderID := createTestFolder(t, s, "  Interviews  ", 0)
+
+	for _, name := range []string{"", "   ", ".", "..", "a/b", `a\b`, "../escape", "nul\x00"} {
+		if _, err := s.CreateFolder(name, 0); err != errFolderName {
+			t.Errorf("CreateFolder(%q) = %v, want %v", name, err, errFolderName)
+		}
+		if err := s.RenameFolder(folderID, name); err != errFolderName {
+			t.Errorf("RenameFolder(%q) = %v, want %v", name, err, errFolderName)
+		}
+	}
+	if _, err := s.CreateFolder("Notes", 999); err == nil {
+		t.Errorf("CreateFolder() inside a missing folder succeeded")
+	}
+
+	tree, err := s.GetFolderTree()
+	if err != nil {
+		t.Fatalf("GetFolderTree() failed: %v", err)
+	}
+	if len(tree) != 2 || tree[0].Name != "Interviews" {
+		t.Errorf("GetFolderTree() = %+v, want the name trimmed and left unchanged", tree)
+	}
+}
+
+func TestDeleteFoldersDeletesSubfolders(t *testing.T) {
+	s := setupServiceTest(t)
+	cases := createTestFolder(t, s, "Cases", 0)
+	open := createTestFolder(t, s, "Open", cases)
+	storeTestFile(t, s, open, "statement.bin", 100)
+	kept, keptData := storeTestFile(t, s, 1, "kept.bin", 100)
+
+	if err := s.DeleteFolders([]int64{cases}); err != nil {
+		t.Fatalf("DeleteFolders() failed: %v", err)
+	}
+	tree, err := s.GetFolderTree()
+	if err != nil {
+		t.Fatalf("GetFolderTree() failed: %v", err)
+	}
+	if len(tree) != 1 || tree[0].ID != 1 || tree[0].FileCount != 1 {
+		t.Errorf("GetFolderTree() = %+v, want only the folder of the kept file", tree)
+	}
+	checkTestFile(t, s, kept, keptData)
+}
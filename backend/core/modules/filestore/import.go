@@ -0,0 +1,207 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"Tella-Desktop/backend/utils/transferutils"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// emitEvent sends events to the frontend, such as the progress of an import. Tests replace it, as
+// runtime.EventsEmit needs a running app.
+var emitEvent = runtime.EventsEmit
+
+// emit sends an event with data to the frontend
+func (s *service) emit(eventName string, data any) {
+	emitEvent(s.ctx, eventName, data)
+}
+
+// importItem is a file or directory found while planning an import. Directories become folders; parent is the index
+// of the item's directory, or -1 for the paths the import was started with.
+type importItem struct {
+	path   string
+	isDir  bool
+	size   int64
+	parent int
+}
+
+// planImport walks the paths to import, without following symlinks, listing every directory before its contents
+func planImport(paths []string) ([]importItem, []ImportFailure) {
+	var items []importItem
+	var skipped []ImportFailure
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		// indexes of the directories of this walk by path, to look up the parent of every item
+		dirs := map[string]int{}
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				skipped = append(skipped, ImportFailure{Path: path, Error: err.Error()})
+				if entry != nil && entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			parent := -1
+			if path != root {
+				parent = dirs[filepath.Dir(path)]
+			}
+			switch {
+			case entry.IsDir():
+				dirs[path] = len(items)
+				items = append(items, importItem{path: path, isDir: true, parent: parent})
+			case entry.Type().IsRegular():
+				info, err := entry.Info()
+				if err != nil {
+					skipped = append(skipped, ImportFailure{Path: path, Error: err.Error()})
+					return nil
+				}
+				items = append(items, importItem{path: path, size: info.Size(), parent: parent})
+			default:
+				skipped = append(skipped, ImportFailure{Path: path, Error: "not a regular file"})
+			}
+			return nil
+		})
+		if err != nil {
+			skipped = append(skipped, ImportFailure{Path: root, Error: err.Error()})
+		}
+	}
+	return items, skipped
+}
+
+var errImportPaths = errors.New("failed to import files")
+
+// ImportPaths encrypts local files into a folder. Directories are imported recursively as nested folders of the same
+// name. Progress is reported to the frontend through "import-progress" events. With shred, every original is
+// overwritten and removed once it is safely stored, along with the directories left empty; on flash storage such as
+// USB sticks, overwriting can't guarantee the old data is gone from the device.
+func (s *service) ImportPaths(paths []string, folderID int64, shred bool) (*ImportResult, error) {
+	if len(paths) == 0 {
+		log("no paths provided")
+		return nil, errImportPaths
+	}
+	exists, err := s.folderExists(folderID)
+	if err != nil {
+		log("failed to look up folder %d: %v", folderID, err)
+		return nil, errImportPaths
+	}
+	if !exists {
+		log("folder not found with ID: %d", folderID)
+		return nil, errFolderNotFound
+	}
+
+	items, failed := planImport(paths)
+	result := &ImportResult{FileIDs: []int64{}, Failed: failed}
+	progress := ImportProgress{}
+	for _, item := range items {
+		if !item.isDir {
+			progress.FilesTotal++
+			progress.BytesTotal += item.size
+		}
+	}
+	s.emit("import-progress", progress)
+
+	folderIDs := make([]int64, len(items))
+	for i, item := range items {
+		parentFolderID := folderID
+		if item.parent >= 0 {
+			parentFolderID = folderIDs[item.parent]
+		}
+		// the contents of a directory that couldn't be created are skipped along with it
+		if parentFolderID == 0 {
+			continue
+		}
+
+		if item.isDir {
+			folderIDs[i], err = s.CreateFolder(filepath.Base(item.path), parentFolderID)
+			if err != nil {
+				result.Failed = append(result.Failed, ImportFailure{Path: item.path, Error: err.Error()})
+				continue
+			}
+			result.FoldersCreated++
+			continue
+		}
+
+		progress.CurrentFile = filepath.Base(item.path)
+		metadata, err := s.importFile(item, parentFolderID)
+		if err != nil {
+			log("failed to import %s: %v", item.path, err)
+			result.Failed = append(result.Failed, ImportFailure{Path: item.path, Error: err.Error()})
+		} else {
+			result.FileIDs = append(result.FileIDs, metadata.ID)
+			if metadata.DuplicateOf != 0 {
+				result.Duplicates++
+			}
+			if shred {
+				if err := filestoreutils.SecurelyDeleteFile(item.path); err != nil {
+					result.Failed = append(result.Failed, ImportFailure{Path: item.path, Error: "imported, but the original could not be shredded"})
+				} else {
+					result.Shredded++
+				}
+			}
+		}
+		progress.FilesDone++
+		progress.BytesDone += item.size
+		s.emit("import-progress", progress)
+	}
+
+	if shred {
+		// deepest directories first; only directories emptied by the import can be removed
+		for i := len(items) - 1; i >= 0; i-- {
+			if items[i].isDir {
+				os.Remove(items[i].path)
+			}
+		}
+	}
+
+	if len(result.FileIDs) == 0 && progress.FilesTotal > 0 {
+		log("all %d files failed to import", progress.FilesTotal)
+		return result, errImportPaths
+	}
+	log("Imported %d of %d files into folder %d", len(result.FileIDs), progress.FilesTotal, folderID)
+	return result, nil
+}
+
+var errFileChanged = errors.New("file changed while it was being imported")
+
+// importFile stores a single local file. The file is hashed before it is stored, so that StoreFile verifies that what
+// ends up in the TVault is exactly what was hashed.
+func (s *service) importFile(item importItem, folderID int64) (*FileMetadata, error) {
+	file, err := os.Open(item.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mimeType, err := mimetype.DetectReader(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	metadata, err := s.StoreFile(folderID, item.size, fmt.Sprintf("%x", hasher.Sum(nil)), filepath.Base(item.path), mimeType.String(), file)
+	if err != nil {
+		if errors.Is(err, transferutils.ErrTransferHashMismatch) {
+			return nil, errFileChanged
+		}
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -0,0 +1,287 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/constants"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+var errCheckVault = errors.New("failed to check vault")
+
+// CheckVault verifies that the files table, the free_spaces table and the TVault agree with each other:
+//   - every stored file lies within the TVault, decrypts under its key and matches its recorded hash,
+//   - every thumbnail lies within the TVault and decrypts under its key,
+//   - no two files or thumbnails overlap,
+//   - no free_spaces block overlaps a file, another block, or lies outside the TVault,
+//   - every byte past the TVault header is either used by a file or free.
+//
+// With repair, unreadable files are quarantined, broken thumbnails are forgotten and free_spaces blocks overlapping
+// stored data are dropped, so that they are never handed out. Unreferenced regions are left for CompactVault to reclaim.
+func (s *service) CheckVault(repair bool) (*VaultCheckReport, error) {
+	if repair {
+		s.vaultMu.Lock()
+		defer s.vaultMu.Unlock()
+	} else {
+		s.vaultMu.RLock()
+		defer s.vaultMu.RUnlock()
+	}
+
+	vaultInfo, err := os.Stat(s.tvaultPath)
+	if err != nil {
+		log("failed to stat TVault: %v", err)
+		return nil, errCheckVault
+	}
+	vaultSize := vaultInfo.Size()
+	report := &VaultCheckReport{VaultSize: vaultSize, Issues: []VaultIssue{}, Quarantined: []int64{}, ThumbnailsCleared: []int64{}}
+
+	files, err := filestoreutils.GetReferencedFiles(s.db)
+	if err != nil {
+		log("failed to get stored files: %v", err)
+		return nil, errCheckVault
+	}
+	freeRegions, err := filestoreutils.GetFreeRegions(s.db)
+	if err != nil {
+		log("failed to get free spaces: %v", err)
+		return nil, errCheckVault
+	}
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		log("failed to open TVault: %v", err)
+		return nil, errCheckVault
+	}
+	defer tvault.Close()
+
+	// files to quarantine on repair, and whether their region can be kept
+	type quarantine struct {
+		reason     string
+		keepRegion bool
+	}
+	toQuarantine := map[int64]*quarantine{}
+	addIssue := func(kind string, fileID int64, region filestoreutils.VaultRegion, detail string) {
+		report.Issues = append(report.Issues, VaultIssue{Kind: kind, FileID: fileID, Offset: region.Offset, Length: region.Length, Detail: detail})
+	}
+
+	// thumbnails are checked along with the files, but a broken thumbnail only costs the thumbnail
+	badThumbnails := map[int64]bool{}
+	addThumbnailIssue := func(fileID int64, region filestoreutils.VaultRegion, detail string) {
+		if !badThumbnails[fileID] {
+			addIssue(IssueThumbnail, fileID, region, detail)
+			badThumbnails[fileID] = true
+		}
+	}
+	var stored []storedRegion
+	for _, file := range files {
+		stored = append(stored, storedRegion{fileID: file.ID, region: filestoreutils.VaultRegion{Offset: file.Offset, Length: file.Length}})
+		if file.ThumbnailLength > 0 {
+			stored = append(stored, storedRegion{fileID: file.ID, region: filestoreutils.VaultRegion{Offset: file.ThumbnailOffset, Length: file.ThumbnailLength}, thumbnail: true})
+		}
+	}
+	sort.SliceStable(stored, func(i, j int) bool { return stored[i].region.Offset < stored[j].region.Offset })
+
+	// layout of the files: stored is ordered by offset, so comparing against the furthest reaching region seen so far
+	// finds every overlap
+	var used []filestoreutils.VaultRegion
+	var furthest storedRegion
+	for _, entry := range stored {
+		region := entry.region
+		if region.Offset < constants.TVaultHeaderSize || region.Length <= 0 || region.End() > vaultSize {
+			if entry.thumbnail {
+				addThumbnailIssue(entry.fileID, region, fmt.Sprintf("thumbnail lies outside of the TVault (%d bytes)", vaultSize))
+				continue
+			}
+			addIssue(IssueOutOfBounds, entry.fileID, region, fmt.Sprintf("region lies outside of the TVault (%d bytes)", vaultSize))
+			toQuarantine[entry.fileID] = &quarantine{reason: IssueOutOfBounds}
+			continue
+		}
+		used = append(used, region)
+
+		// identical files share their regions by design, only partial overlaps, or a file sharing a thumbnail's region,
+		// are corrupt
+		if region.Offset < furthest.region.End() && (region != furthest.region || entry.thumbnail != furthest.thumbnail) {
+			switch {
+			case entry.thumbnail:
+				addThumbnailIssue(entry.fileID, region, fmt.Sprintf("thumbnail overlaps data of file %d", furthest.fileID))
+			case furthest.thumbnail:
+				addThumbnailIssue(furthest.fileID, furthest.region, fmt.Sprintf("thumbnail overlaps file %d", entry.fileID))
+			default:
+				addIssue(IssueOverlap, entry.fileID, region, fmt.Sprintf("region overlaps file %d", furthest.fileID))
+			}
+		}
+		if region.End() > furthest.region.End() {
+			furthest = entry
+		}
+	}
+
+	// contents of the files
+	for _, file := range files {
+		if file.Quarantined {
+			continue
+		}
+		if _, ok := toQuarantine[file.ID]; ok {
+			continue
+		}
+		report.FilesChecked++
+		if err := s.verifyFile(&file.FileMetadata, tvault); err != nil {
+			log("file %d failed to decrypt: %v", file.ID, err)
+			addIssue(IssueUnreadable, file.ID, filestoreutils.VaultRegion{Offset: file.Offset, Length: file.Length}, "file does not decrypt under its key or does not match its recorded hash")
+			toQuarantine[file.ID] = &quarantine{reason: IssueUnreadable, keepRegion: !overlapsOtherFile(file, files)}
+			continue
+		}
+		if file.ThumbnailLength > 0 && !badThumbnails[file.ID] {
+			thumbnail := &filestoreutils.FileMetadata{
+				UUID:   filestoreutils.ThumbnailID(file.UUID),
+				Offset: file.ThumbnailOffset,
+				Length: file.ThumbnailLength,
+				Format: filestoreutils.FormatChunked,
+			}
+			if err := s.verifyFile(thumbnail, tvault); err != nil {
+				log("thumbnail of file %d failed to decrypt: %v", file.ID, err)
+				addThumbnailIssue(file.ID, filestoreutils.VaultRegion{Offset: file.ThumbnailOffset, Length: file.ThumbnailLength}, "thumbnail does not decrypt under its key")
+			}
+		}
+	}
+
+	usedMerged := mergeRegions(used)
+
+	// free space
+	var badFree []filestoreutils.VaultRegion
+	var prevFree filestoreutils.VaultRegion
+	for _, region := range freeRegions {
+		var detail string
+		switch {
+		case region.Offset < constants.TVaultHeaderSize || region.Length <= 0 || region.End() > vaultSize:
+			detail = "free block lies outside of the TVault"
+		case region.Offset < prevFree.End():
+			detail = "free block overlaps another free block"
+		case overlapsAny(usedMerged, region):
+			detail = "free block overlaps stored file data"
+		}
+		if detail != "" {
+			addIssue(IssueFreeSpaceOverlap, 0, region, detail)
+			badFree = append(badFree, region)
+		}
+		if region.End() > prevFree.End() {
+			prevFree = region
+		}
+	}
+
+	// regions that are neither used nor free
+	accounted := append(append([]filestoreutils.VaultRegion{}, used...), freeRegions...)
+	cursor := int64(constants.TVaultHeaderSize)
+	for _, region := range mergeRegions(accounted) {
+		if region.Offset > cursor {
+			addIssue(IssueUnreferenced, 0, filestoreutils.VaultRegion{Offset: cursor, Length: min(region.Offset, vaultSize) - cursor}, "region is neither used by a file nor free")
+		}
+		cursor = max(cursor, region.End())
+		if cursor >= vaultSize {
+			break
+		}
+	}
+	if cursor < vaultSize {
+		addIssue(IssueUnreferenced, 0, filestoreutils.VaultRegion{Offset: cursor, Length: vaultSize - cursor}, "region is neither used by a file nor free")
+	}
+
+	if repair && (len(toQuarantine) > 0 || len(badFree) > 0 || len(badThumbnails) > 0) {
+		tx, err := s.db.Begin()
+		if err != nil {
+			log("failed to begin transaction: %v", err)
+			return nil, errCheckVault
+		}
+		defer tx.Rollback()
+
+		for _, file := range files {
+			q, ok := toQuarantine[file.ID]
+			if !ok || file.Quarantined {
+				continue
+			}
+			if err := filestoreutils.QuarantineFile(tx, file.ID, q.reason, q.keepRegion); err != nil {
+				log("failed to quarantine file %d: %v", file.ID, err)
+				return nil, errCheckVault
+			}
+			report.Quarantined = append(report.Quarantined, file.ID)
+		}
+		for _, file := range files {
+			if !badThumbnails[file.ID] || file.Quarantined {
+				continue
+			}
+			if err := filestoreutils.ClearThumbnail(tx, file.ID); err != nil {
+				log("failed to clear thumbnail of file %d: %v", file.ID, err)
+				return nil, errCheckVault
+			}
+			report.ThumbnailsCleared = append(report.ThumbnailsCleared, file.ID)
+		}
+		for _, region := range badFree {
+			if err := filestoreutils.RemoveFreeRegion(tx, region); err != nil {
+				log("failed to remove free space at offset %d: %v", region.Offset, err)
+				return nil, errCheckVault
+			}
+			report.FreeSpaceRemoved++
+		}
+
+		if err := tx.Commit(); err != nil {
+			log("failed to commit transaction: %v", err)
+			return nil, errCheckVault
+		}
+	}
+
+	log("Checked %d files, found %d issues, quarantined %d files", report.FilesChecked, len(report.Issues), len(report.Quarantined))
+	return report, nil
+}
+
+// storedRegion is a TVault region holding either a file or a file's thumbnail
+type storedRegion struct {
+	fileID    int64
+	region    filestoreutils.VaultRegion
+	thumbnail bool
+}
+
+// verifyFile decrypts a whole file, discarding the plaintext, and checks it against its recorded hash
+func (s *service) verifyFile(metadata *filestoreutils.FileMetadata, tvault *os.File) error {
+	reader, err := filestoreutils.OpenFileReader(metadata, s.dbKey, tvault)
+	if err != nil {
+		return err
+	}
+	verified := filestoreutils.NewVerifyingReader(reader, metadata.SHA256)
+	defer verified.Close()
+	_, err = filestoreutils.CopyDecrypted(io.Discard, verified)
+	return err
+}
+
+// overlapsOtherFile reports whether file's region partially overlaps the region of any other file
+func overlapsOtherFile(file filestoreutils.ReferencedFile, files []filestoreutils.ReferencedFile) bool {
+	region := filestoreutils.VaultRegion{Offset: file.Offset, Length: file.Length}
+	for _, other := range files {
+		otherRegion := filestoreutils.VaultRegion{Offset: other.Offset, Length: other.Length}
+		if otherRegion != region && otherRegion.Offset < region.End() && region.Offset < otherRegion.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeRegions returns the union of regions as a sorted list of disjoint regions
+func mergeRegions(regions []filestoreutils.VaultRegion) []filestoreutils.VaultRegion {
+	sorted := append([]filestoreutils.VaultRegion{}, regions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Offset < sorted[j].Offset })
+
+	var merged []filestoreutils.VaultRegion
+	for _, region := range sorted {
+		if n := len(merged); n > 0 && region.Offset <= merged[n-1].End() {
+			merged[n-1].Length = max(merged[n-1].End(), region.End()) - merged[n-1].Offset
+			continue
+		}
+		merged = append(merged, region)
+	}
+	return merged
+}
+
+// overlapsAny reports whether region overlaps any of the sorted, disjoint regions
+func overlapsAny(regions []filestoreutils.VaultRegion, region filestoreutils.VaultRegion) bool {
+	i := sort.Search(len(regions), func(i int) bool { return regions[i].End() > region.Offset })
+	return i < len(regions) && regions[i].Offset < region.End()
+}
@@ -0,0 +1,124 @@
+package filestore
+
+import (
+	"os"
+	"testing"
+
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+// issueKinds counts the issues of a report by kind
+func issueKinds(report *VaultCheckReport) map[string]int {
+	kinds := map[string]int{}
+	for _, issue := range report.Issues {
+		kinds[issue.Kind]++
+	}
+	return kinds
+}
+
+func TestCheckVaultFindsOrphanedRegions(t *testing.T) {
+	s := setupServiceTest(t)
+	first, _ := storeTestFile(t, s, 1, "first.bin", 1000)
+	second, secondData := storeTestFile(t, s, 1, "second.bin", 5000)
+	firstMetadata, err := filestoreutils.GetFileMetadataByID(s.db, first)
+	if err != nil {
+		t.Fatalf("Failed to get file metadata: %v", err)
+	}
+
+	// the region of the first file is neither referenced nor free, and garbage follows the last file
+	if err := s.DeleteFiles([]int64{first}); err != nil {
+		t.Fatalf("DeleteFiles() failed: %v", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM free_spaces"); err != nil {
+		t.Fatalf("Failed to clear free spaces: %v", err)
+	}
+	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open TVault: %v", err)
+	}
+	_, err = tvault.Write(make([]byte, 50))
+	tvault.Close()
+	if err != nil {
+		t.Fatalf("Failed to append to TVault: %v", err)
+	}
+
+	report, err := s.CheckVault(true)
+	if err != nil {
+		t.Fatalf("CheckVault() failed: %v", err)
+	}
+	if kinds := issueKinds(report); len(report.Issues) != 2 || kinds[IssueUnreferenced] != 2 {
+		t.Fatalf("CheckVault() found %+v, want two unreferenced regions", report.Issues)
+	}
+	if orphan := report.Issues[0]; orphan.Offset != firstMetadata.Offset || orphan.Length != firstMetadata.Length {
+		t.Errorf("Unreferenced region = %+v, want the region of the deleted file", orphan)
+	}
+	if report.FilesChecked != 1 || len(report.Quarantined) != 0 || report.FreeSpaceRemoved != 0 {
+		t.Errorf("CheckVault() = %+v, want one file checked and nothing repaired", report)
+	}
+
+	// unreferenced regions are left for compaction to reclaim
+	if _, err := s.CompactVault(); err != nil {
+		t.Fatalf("CompactVault() failed: %v", err)
+	}
+	checkTestFile(t, s, second, secondData)
+	checkCompacted(t, s)
+}
+
+func TestCheckVaultRepairsOverlappingRegions(t *testing.T) {
+	s := setupServiceTest(t)
+	first, firstData := storeTestFile(t, s, 1, "first.bin", 1000)
+	second, _ := storeTestFile(t, s, 1, "second.bin", 1000)
+	third, thirdData := storeTestFile(t, s, 1, "third.bin", 1000)
+	thirdMetadata, err := filestoreutils.GetFileMetadataByID(s.db, third)
+	if err != nil {
+		t.Fatalf("Failed to get file metadata: %v", err)
+	}
+
+	// the second file claims the last bytes of the first, and a free block claims the start of the third
+	if _, err := s.db.Exec("UPDATE files SET offset = offset - 10 WHERE id = ?", second); err != nil {
+		t.Fatalf("Failed to corrupt file offset: %v", err)
+	}
+	if _, err := s.db.Exec("INSERT INTO free_spaces (offset, length) VALUES (?, ?)", thirdMetadata.Offset, 10); err != nil {
+		t.Fatalf("Failed to corrupt free spaces: %v", err)
+	}
+
+	report, err := s.CheckVault(false)
+	if err != nil {
+		t.Fatalf("CheckVault() failed: %v", err)
+	}
+	kinds := issueKinds(report)
+	if kinds[IssueOverlap] != 1 || kinds[IssueUnreadable] != 1 || kinds[IssueFreeSpaceOverlap] != 1 {
+		t.Fatalf("CheckVault() found %+v, want an overlap, an unreadable file and an overlapping free block", report.Issues)
+	}
+	if len(report.Quarantined) != 0 || report.FreeSpaceRemoved != 0 {
+		t.Errorf("CheckVault() without repair changed the vault: %+v", report)
+	}
+
+	report, err = s.CheckVault(true)
+	if err != nil {
+		t.Fatalf("CheckVault() failed: %v", err)
+	}
+	if len(report.Quarantined) != 1 || report.Quarantined[0] != second || report.FreeSpaceRemoved != 1 {
+		t.Fatalf("CheckVault() repaired %+v, want file %d quarantined and one free block removed", report, second)
+	}
+	if _, err := filestoreutils.GetFileMetadataByID(s.db, second); err == nil {
+		t.Errorf("Quarantined file %d is still live", second)
+	}
+	checkTestFile(t, s, first, firstData)
+	checkTestFile(t, s, third, thirdData)
+
+	// the region of the quarantined file overlapped the first file, so it isn't kept and compaction reclaims it
+	report, err = s.CheckVault(false)
+	if err != nil {
+		t.Fatalf("CheckVault() failed: %v", err)
+	}
+	if kinds := issueKinds(report); kinds[IssueOverlap] != 0 || kinds[IssueUnreadable] != 0 || kinds[IssueFreeSpaceOverlap] != 0 {
+		t.Errorf("CheckVault() after repair found %+v", report.Issues)
+	}
+	if _, err := s.CompactVault(); err != nil {
+		t.Fatalf("CompactVault() failed: %v", err)
+	}
+	checkTestFile(t, s, first, firstData)
+	checkTestFile(t, s, third, thirdData)
+	checkCompacted(t, s)
+}
@@ -13,6 +13,63 @@ type FileInfo struct {
 	MimeType  string `json:"mimeType"`
 	Timestamp string `json:"timestamp"`
 	Size      int64  `json:"size"`
+	// SHA256 is the hex encoded hash of the plaintext, empty for files stored before hashes were recorded
+	SHA256   string `json:"sha256"`
+	FolderID int64  `json:"folderId"`
+	// Blurhash is a compact placeholder for images that have a thumbnail, see GetThumbnail
+	Blurhash string `json:"blurhash"`
+}
+
+// TrashedFileInfo is a file in the trash. The file's FolderID is the folder it was deleted from.
+type TrashedFileInfo struct {
+	File      FileInfo `json:"file"`
+	TrashedAt string   `json:"trashedAt"`
+	// PurgeAt is when the file is due to be securely purged, as an RFC 3339 timestamp
+	PurgeAt string `json:"purgeAt"`
+}
+
+// TagInfo is a tag with the number of files and folders carrying it
+type TagInfo struct {
+	Name        string `json:"name"`
+	FileCount   int    `json:"fileCount"`
+	FolderCount int    `json:"folderCount"`
+}
+
+// Annotations are the tags and custom metadata, such as a case number or location, of a file or folder
+type Annotations struct {
+	Tags           []string          `json:"tags"`
+	CustomMetadata map[string]string `json:"customMetadata"`
+}
+
+// FileSearchQuery filters, sorts and paginates SearchFiles. Zero values don't filter.
+type FileSearchQuery struct {
+	// Name matches files whose name contains it, ignoring case
+	Name string `json:"name"`
+	// MimeType matches a MIME type exactly, or all subtypes of a type when given as e.g. "image/"
+	MimeType string `json:"mimeType"`
+	MinSize  int64  `json:"minSize"`
+	MaxSize  int64  `json:"maxSize"`
+	// ReceivedAfter and ReceivedBefore are RFC 3339 timestamps or dates (YYYY-MM-DD), after is inclusive, before
+	// exclusive
+	ReceivedAfter     string `json:"receivedAfter"`
+	ReceivedBefore    string `json:"receivedBefore"`
+	FolderID          int64  `json:"folderId"`
+	IncludeSubfolders bool   `json:"includeSubfolders"`
+	// Tags matches the files carrying all of the given tags, ignoring case
+	Tags []string `json:"tags"`
+	// SortBy is one of "date" (default), "name", "size" or "mimeType"
+	SortBy   string `json:"sortBy"`
+	SortDesc bool   `json:"sortDesc"`
+	// Limit is the page size, at most 500
+	Limit int `json:"limit"`
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string `json:"cursor"`
+}
+
+type FileSearchResult struct {
+	Files []FileInfo `json:"files"`
+	// NextCursor fetches the next page, and is empty on the last page
+	NextCursor string `json:"nextCursor"`
 }
 
 type FileMetadata struct {
@@ -24,7 +81,11 @@ type FileMetadata struct {
 	FolderID  int64
 	Offset    int64
 	Length    int64
+	SHA256    string
 	CreatedAt time.Time
+	// DuplicateOf is the ID of the stored file with the same content, when the file was linked to it or skipped in
+	// its favour, and 0 otherwise
+	DuplicateOf int64
 }
 
 type FolderInfo struct {
@@ -33,3 +94,150 @@ type FolderInfo struct {
 	Timestamp string `json:"timestamp"`
 	FileCount int    `json:"fileCount"`
 }
+
+// FolderNode is a folder in the folder tree. ParentID is 0 for top-level folders.
+type FolderNode struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	ParentID  int64         `json:"parentId"`
+	Timestamp string        `json:"timestamp"`
+	FileCount int           `json:"fileCount"`
+	Children  []*FolderNode `json:"children"`
+}
+
+// ImportResult describes the outcome of ImportPaths
+type ImportResult struct {
+	FileIDs        []int64 `json:"fileIds"`
+	FoldersCreated int     `json:"foldersCreated"`
+	// Duplicates counts the files whose content was already stored, see config.DuplicatePolicy
+	Duplicates int `json:"duplicates"`
+	// Shredded counts the originals that were overwritten and removed after being imported
+	Shredded int             `json:"shredded"`
+	Failed   []ImportFailure `json:"failed"`
+}
+
+// ImportFailure is a local file or directory that was skipped or failed to import
+type ImportFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ImportProgress is the payload of the "import-progress" events emitted during ImportPaths and ImportContainer
+type ImportProgress struct {
+	FilesDone   int    `json:"filesDone"`
+	FilesTotal  int    `json:"filesTotal"`
+	BytesDone   int64  `json:"bytesDone"`
+	BytesTotal  int64  `json:"bytesTotal"`
+	CurrentFile string `json:"currentFile"`
+}
+
+// Statuses of an export job, see ExportProgress
+const (
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportCancelled = "cancelled"
+	ExportFailed    = "failed"
+)
+
+// ExportProgress is the payload of the "export-progress" events emitted by export jobs. The last event of a job has a
+// status other than ExportRunning; Paths are set once it completed.
+type ExportProgress struct {
+	JobID       string   `json:"jobId"`
+	Status      string   `json:"status"`
+	FilesDone   int      `json:"filesDone"`
+	FilesTotal  int      `json:"filesTotal"`
+	BytesDone   int64    `json:"bytesDone"`
+	BytesTotal  int64    `json:"bytesTotal"`
+	CurrentFile string   `json:"currentFile"`
+	Paths       []string `json:"paths"`
+	Error       string   `json:"error"`
+}
+
+// ExportOptions configures an export. The zero value exports the files alone, to the configured export folder.
+type ExportOptions struct {
+	// IncludeManifest adds a JSON and a CSV manifest listing every exported file with its hash and provenance: as entries
+	// of archives, or as sidecar files next to loose files
+	IncludeManifest bool `json:"includeManifest"`
+	// Destination is the folder to export to. Empty means the configured export folder.
+	Destination string `json:"destination"`
+	// Format is the archive format of archive exports: "zip", "tar", "tar.gz" or "encrypted" for a password-encrypted
+	// container. Empty means ZIP. Loose exports refuse any format.
+	Format string `json:"format"`
+	// Passphrase encrypts containers, see authutils.CheckContainerPassphrase. Other archive formats ignore it, loose
+	// exports refuse it.
+	Passphrase string `json:"passphrase"`
+}
+
+type CompactionResult struct {
+	SizeBefore   int64 `json:"sizeBefore"`
+	SizeAfter    int64 `json:"sizeAfter"`
+	RegionsMoved int   `json:"regionsMoved"`
+}
+
+// FreeSpaceReport describes the free space inside the TVault
+type FreeSpaceReport struct {
+	VaultSize    int64 `json:"vaultSize"`
+	FreeBytes    int64 `json:"freeBytes"`
+	BlockCount   int   `json:"blockCount"`
+	LargestBlock int64 `json:"largestBlock"`
+	// Fragmentation is the share of free bytes outside of the largest free block: 0 when all free space is in one
+	// block, approaching 1 when it is scattered across many small blocks
+	Fragmentation float64 `json:"fragmentation"`
+}
+
+// VaultStats describes how the TVault uses its space, as returned by GetVaultStats
+type VaultStats struct {
+	VaultSize int64 `json:"vaultSize"`
+	// LiveBytes is the ciphertext, thumbnails included, of every file whose data is kept: files in the trash count,
+	// and content shared by linked files counts once
+	LiveBytes        int64 `json:"liveBytes"`
+	FreeBytes        int64 `json:"freeBytes"`
+	FreeBlocks       int   `json:"freeBlocks"`
+	LargestFreeBlock int64 `json:"largestFreeBlock"`
+	// Folders lists every folder, largest first
+	Folders []FolderUsage `json:"folders"`
+	// DiskFreeBytes is the space left on the filesystem holding the TVault, or -1 if it couldn't be determined
+	DiskFreeBytes int64 `json:"diskFreeBytes"`
+}
+
+// FolderUsage is the space used by the files directly inside a folder. Files sharing their content with files in
+// other folders count toward each folder.
+type FolderUsage struct {
+	FolderID  int64  `json:"folderId"`
+	Name      string `json:"name"`
+	ParentID  int64  `json:"parentId"`
+	FileCount int    `json:"fileCount"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Kinds of problems found by CheckVault
+const (
+	IssueUnreadable       = "unreadable"         // the file fails to decrypt under its key or to match its recorded hash
+	IssueOutOfBounds      = "out_of_bounds"      // the file's region extends past the TVault header or end
+	IssueOverlap          = "overlap"            // the file's region overlaps another file's region
+	IssueFreeSpaceOverlap = "free_space_overlap" // a free_spaces block overlaps a file, another block or the TVault bounds
+	IssueUnreferenced     = "unreferenced"       // a TVault region is neither referenced by a file nor free
+	IssueThumbnail        = "thumbnail"          // the file's thumbnail lies out of bounds, overlaps other data or fails to decrypt
+)
+
+// VaultIssue is a single problem found by CheckVault. FileID is 0 for issues that don't concern a specific file.
+type VaultIssue struct {
+	Kind   string `json:"kind"`
+	FileID int64  `json:"fileId"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Detail string `json:"detail"`
+}
+
+// VaultCheckReport is the result of CheckVault
+type VaultCheckReport struct {
+	VaultSize    int64        `json:"vaultSize"`
+	FilesChecked int          `json:"filesChecked"`
+	Issues       []VaultIssue `json:"issues"`
+	// Quarantined lists the files quarantined by the repair
+	Quarantined []int64 `json:"quarantined"`
+	// FreeSpaceRemoved counts the free_spaces blocks dropped by the repair because they overlapped stored data
+	FreeSpaceRemoved int `json:"freeSpaceRemoved"`
+	// ThumbnailsCleared lists the files whose broken thumbnail was dropped by the repair
+	ThumbnailsCleared []int64 `json:"thumbnailsCleared"`
+}
@@ -6,21 +6,168 @@ type Service interface {
 	// StoreFile encrypts and stores a file in TVault, returning its metadata
 	StoreFile(folderID, claimedSize int64, claimedHash string, fileName string, mimeType string, reader io.Reader) (*FileMetadata, error)
 
+	// SetDuplicatePolicy changes what StoreFile does with content that is already stored, one of the
+	// config.DuplicatePolicy constants
+	SetDuplicatePolicy(policy string)
+
+	// ImportPaths encrypts local files and directories into a folder, optionally shredding the originals
+	ImportPaths(paths []string, folderID int64, shred bool) (*ImportResult, error)
+
+	// ImportContainer stores the files of a password-encrypted export container into a new folder inside parentID
+	ImportContainer(path, passphrase string, parentID int64) (*ImportResult, error)
+
 	// GetStoredFolders returns a list of folders with file counts
 	GetStoredFolders() ([]FolderInfo, error)
 
 	// GetFilesInFolder returns files in a specific folder
 	GetFilesInFolder(folderID int64) (*FilesInFolderResponse, error)
 
+	// GetThumbnail returns the thumbnail of an image as a data URL, without decrypting the image itself
+	GetThumbnail(fileID int64) (string, error)
+
+	// SearchFiles returns a page of the files matching a query
+	SearchFiles(query FileSearchQuery) (*FileSearchResult, error)
+
+	// GetTags lists every tag with the number of files and folders carrying it
+	GetTags() ([]TagInfo, error)
+
+	// GetFileAnnotations returns the tags and custom metadata of a file
+	GetFileAnnotations(fileID int64) (*Annotations, error)
+
+	// GetFolderAnnotations returns the tags and custom metadata of a folder
+	GetFolderAnnotations(folderID int64) (*Annotations, error)
+
+	// TagFiles adds tags to files
+	TagFiles(fileIDs []int64, tags []string) error
+
+	// UntagFiles removes tags from files
+	UntagFiles(fileIDs []int64, tags []string) error
+
+	// TagFolders adds tags to folders
+	TagFolders(folderIDs []int64, tags []string) error
+
+	// UntagFolders removes tags from folders
+	UntagFolders(folderIDs []int64, tags []string) error
+
+	// SetFileCustomMetadata sets a key/value pair on a file, removing the key if value is empty
+	SetFileCustomMetadata(fileID int64, key, value string) error
+
+	// SetFolderCustomMetadata sets a key/value pair on a folder, removing the key if value is empty
+	SetFolderCustomMetadata(folderID int64, key, value string) error
+
 	// ExportFile exports a file by its ID to the user's downloads directory
 	ExportFiles(ids []int64) ([]string, error)
 
-	// ExportZipFolders exports files as ZIP archives
+	// ExportFilesWithOptions is ExportFiles, optionally writing a manifest of the exported files. Manifests are signed
+	// with the vault's signing key; the signatures are written next to them, named with signutils.SignatureExtension
+	// appended, and aren't among the returned paths. Loose files can't be archived or encrypted, so a Format or
+	// Passphrase is refused.
+	ExportFilesWithOptions(ids []int64, options ExportOptions) ([]string, error)
+
+	// ExportZipFolders exports files as ZIP archives, returning the path of one archive for every folder exported. Each
+	// archive is signed with the vault's signing key; the signature is written next to it, named with
+	// signutils.SignatureExtension appended.
 	ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) ([]string, error)
 
-	// DeleteFiles securely deletes files by their IDs
+	// ExportZipFoldersWithOptions is ExportZipFolders, optionally adding a manifest to every archive or writing tar
+	// archives instead
+	ExportZipFoldersWithOptions(folderIDs []int64, selectedFileIDs []int64, options ExportOptions) ([]string, error)
+
+	// StartExportFiles runs ExportFilesWithOptions in the background, returning the ID of the job. Its progress and
+	// outcome are reported through "export-progress" events.
+	StartExportFiles(ids []int64, options ExportOptions) (string, error)
+
+	// StartExportZipFolders runs ExportZipFoldersWithOptions in the background, returning the ID of the job. Its
+	// progress and outcome are reported through "export-progress" events.
+	StartExportZipFolders(folderIDs []int64, selectedFileIDs []int64, options ExportOptions) (string, error)
+
+	// CancelExport stops an export job, removing the files it has written
+	CancelExport(jobID string) error
+
+	// ExportZipFiles exports files from any folders into a single ZIP archive named after zipName, signed like the
+	// archives of ExportZipFolders
+	ExportZipFiles(zipName string, ids []int64) (string, error)
+
+	// ExportZipFilesWithOptions is ExportZipFiles, optionally adding a manifest to the archive or writing a tar archive
+	// instead
+	ExportZipFilesWithOptions(zipName string, ids []int64, options ExportOptions) (string, error)
+
+	// SetTransferTitle records the title of the transfer a file was received in
+	SetTransferTitle(fileID int64, title string) error
+
+	// OpenFileStream opens the decrypted contents of a file for reading without writing them to disk. Files in the older
+	// blob format are held in memory in full while the stream is open.
+	OpenFileStream(fileID int64) (*FileStream, error)
+
+	// OpenFileForPreview decrypts a file into the temp directory for viewing, returning the path of the copy
+	OpenFileForPreview(fileID int64) (string, error)
+
+	// WipeTempFiles securely deletes every decrypted copy made by OpenFileForPreview
+	WipeTempFiles() error
+
+	// TrashFiles moves files to the trash, from where they can be restored until they are purged
+	TrashFiles(ids []int64) error
+
+	// RestoreFiles brings files back from the trash into folderID, or into their original folders if folderID is 0
+	RestoreFiles(ids []int64, folderID int64) error
+
+	// GetTrash lists the files in the trash
+	GetTrash() ([]TrashedFileInfo, error)
+
+	// PurgeTrash securely deletes files in the trash without waiting for the retention period
+	PurgeTrash(ids []int64) error
+
+	// EmptyTrash securely deletes all files in the trash
+	EmptyTrash() error
+
+	// PurgeExpiredTrash securely deletes the files kept in the trash longer than the configured retention
+	PurgeExpiredTrash() (int, error)
+
+	// StartTrashPurge periodically runs PurgeExpiredTrash in the background until Lock is called
+	StartTrashPurge()
+
+	// Lock stops background work; call it before closing the database
+	Lock()
+
+	// DeleteFiles securely deletes files by their IDs right away, whether they are in the trash or not
 	DeleteFiles(ids []int64) error
 
-	// DeleteFolders deletes folders and all their files by reusing DeleteFiles
+	// DeleteFolders deletes folders and their subfolders, securely deleting all their files by reusing DeleteFiles
 	DeleteFolders(folderIDs []int64) error
+
+	// TrashFolders deletes folders and their subfolders, moving all their files to the trash
+	TrashFolders(folderIDs []int64) error
+
+	// CreateFolder creates a folder inside parentID, or a top-level folder if parentID is 0, returning its ID
+	CreateFolder(name string, parentID int64) (int64, error)
+
+	// RenameFolder changes the name of a folder
+	RenameFolder(folderID int64, name string) error
+
+	// MoveFolder moves a folder into newParentID, or to the top level if newParentID is 0
+	MoveFolder(folderID, newParentID int64) error
+
+	// GetFolderTree returns all folders as a tree of top-level folders and their subfolders
+	GetFolderTree() ([]*FolderNode, error)
+
+	// RenameFile changes the name of a file
+	RenameFile(fileID int64, name string) error
+
+	// MoveFiles moves files into another folder
+	MoveFiles(fileIDs []int64, folderID int64) error
+
+	// CopyFiles re-encrypts copies of files under new UUIDs into a folder, returning the IDs of the copies
+	CopyFiles(fileIDs []int64, folderID int64) ([]int64, error)
+
+	// CompactVault relocates stored ciphertext toward the start of the TVault and truncates the unused tail
+	CompactVault() (*CompactionResult, error)
+
+	// GetFreeSpaceReport reports how much free space the TVault holds and how fragmented it is
+	GetFreeSpaceReport() (*FreeSpaceReport, error)
+
+	// GetVaultStats reports the size of the TVault, how much of it is in use, and the space used by each folder
+	GetVaultStats() (*VaultStats, error)
+
+	// CheckVault verifies that the database and the TVault agree, optionally quarantining files that can't be read
+	CheckVault(repair bool) (*VaultCheckReport, error)
 }
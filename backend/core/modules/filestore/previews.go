@@ -0,0 +1,76 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/authutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	util "Tella-Desktop/backend/utils/genericutil"
+	"errors"
+	"os"
+)
+
+var errOpenPreview = errors.New("failed to open file for preview")
+
+// OpenFileForPreview decrypts a file into the temp directory so it can be viewed, returning the path of the decrypted
+// copy. The copy is tracked in temp_files and removed by WipeTempFiles.
+func (s *service) OpenFileForPreview(fileID int64) (string, error) {
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
+
+	tempDir := authutils.GetTempDir()
+	if err := os.MkdirAll(tempDir, util.USER_ONLY_DIR_PERMS); err != nil {
+		log("failed to create temp dir: %v", err)
+		return "", errOpenPreview
+	}
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		log("failed to open TVault: %v", err)
+		return "", errOpenPreview
+	}
+	defer tvault.Close()
+
+	tempPath, err := filestoreutils.DecryptToTempFile(s.db, s.dbKey, fileID, tvault, tempDir)
+	if err != nil {
+		log("failed to decrypt file %d for preview: %v", fileID, err)
+		if errors.Is(err, filestoreutils.ErrHashMismatch) {
+			return "", err
+		}
+		return "", errOpenPreview
+	}
+
+	log("Decrypted file %d for preview", fileID)
+	return tempPath, nil
+}
+
+var errWipeTempFiles = errors.New("failed to wipe temporary files")
+
+// WipeTempFiles securely deletes every tracked decrypted copy. Copies that can't be deleted, e.g. because another
+// application holds them open, stay tracked so the next wipe tries again.
+func (s *service) WipeTempFiles() error {
+	tempFiles, err := filestoreutils.GetTempFiles(s.db)
+	if err != nil {
+		log("failed to get temporary files: %v", err)
+		return errWipeTempFiles
+	}
+
+	var failed int
+	for _, tempFile := range tempFiles {
+		if err := filestoreutils.WipeTempFile(tempFile.Path); err != nil {
+			failed++
+			continue
+		}
+		if err := filestoreutils.ForgetTempFile(s.db, tempFile.ID); err != nil {
+			log("failed to forget temporary file %d: %v", tempFile.ID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		log("failed to wipe %d of %d temporary files", failed, len(tempFiles))
+		return errWipeTempFiles
+	}
+	if len(tempFiles) > 0 {
+		log("Wiped %d temporary files", len(tempFiles))
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 500
+	// files.created_at is written with datetime('now'), i.e. in UTC and in this layout
+	sqliteTimeLayout = "2006-01-02 15:04:05"
+)
+
+// Sort keys accepted by SearchFiles. Only these column expressions ever end up in the ORDER BY clause, user input is
+// never interpolated into the query.
+var searchSortColumns = map[string]string{
+	"date":     "created_at",
+	"name":     "name COLLATE NOCASE",
+	"size":     "size",
+	"mimeType": "mime_type",
+}
+
+var (
+	errSearchFiles   = errors.New("failed to search files")
+	errSearchQuery   = errors.New("invalid search query")
+	errSearchCursor  = errors.New("invalid search cursor")
+	errSearchSortKey = errors.New("invalid sort key")
+)
+
+// searchCursor is the position after the last file of a page: the sort value and the ID of that file. The sort key and
+// direction are included so that a cursor can't be used with a different ordering.
+type searchCursor struct {
+	SortBy string `json:"s"`
+	Desc   bool   `json:"d"`
+	Value  any    `json:"v"`
+	ID     int64  `json:"i"`
+}
+
+func encodeSearchCursor(c searchCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSearchCursor(encoded string) (*searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var c searchCursor
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil {
+		return nil, err
+	}
+	// sizes are compared as integers, everything else as text
+	if number, ok := c.Value.(json.Number); ok {
+		c.Value, err = number.Int64()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// parseSearchTime accepts RFC 3339 timestamps and plain dates, and converts them to the layout of files.created_at
+func parseSearchTime(value string) (string, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return "", err
+		}
+	}
+	return t.UTC().Format(sqliteTimeLayout), nil
+}
+
+// escapeLike escapes the LIKE wildcards in s, for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (s *service) SearchFiles(query FileSearchQuery) (*FileSearchResult, error) {
+	sortBy := query.SortBy
+	if sortBy == "" {
+		sortBy = "date"
+	}
+	sortColumn, ok := searchSortColumns[sortBy]
+	if !ok {
+		log("unknown sort key %q", query.SortBy)
+		return nil, errSearchSortKey
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	// the query is assembled from fixed fragments only; every user supplied value is passed as a parameter
+	conditions := []string{"is_deleted = 0"}
+	var args []any
+
+	if name := strings.TrimSpace(query.Name); name != "" {
+		conditions = append(conditions, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(name)+"%")
+	}
+	// stored mimetypes are lowercased, see filestoreutils.NormalizeMimeType
+	if mimeType := filestoreutils.NormalizeMimeType(query.MimeType); mimeType != "" {
+		if strings.HasSuffix(mimeType, "/") {
+			// a type without subtype, e.g. "image/", matches all of its subtypes. Comparing against the next string after
+			// the prefix instead of using LIKE lets SQLite use the index on mime_type.
+			conditions = append(conditions, "mime_type >= ? AND mime_type < ?")
+			args = append(args, mimeType, strings.TrimSuffix(mimeType, "/")+"0")
+		} else {
+			conditions = append(conditions, "mime_type = ?")
+			args = append(args, mimeType)
+		}
+	}
+	if query.MinSize > 0 {
+		conditions = append(conditions, "size >= ?")
+		args = append(args, query.MinSize)
+	}
+	if query.MaxSize > 0 {
+		conditions = append(conditions, "size <= ?")
+		args = append(args, query.MaxSize)
+	}
+	if query.ReceivedAfter != "" {
+		after, err := parseSearchTime(query.ReceivedAfter)
+		if err != nil {
+			log("invalid receivedAfter %q: %v", query.ReceivedAfter, err)
+			return nil, errSearchQuery
+		}
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, after)
+	}
+	if query.ReceivedBefore != "" {
+		before, err := parseSearchTime(query.ReceivedBefore)
+		if err != nil {
+			log("invalid receivedBefore %q: %v", query.ReceivedBefore, err)
+			return nil, errSearchQuery
+		}
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, before)
+	}
+	if query.FolderID != 0 {
+		if query.IncludeSubfolders {
+			conditions = append(conditions, `folder_id IN (
+				WITH RECURSIVE subtree(id) AS (
+					SELECT id FROM folders WHERE id = ?
+					UNION
+					SELECT folders.id FROM folders JOIN subtree ON folders.parent_id = subtree.id
+				)
+				SELECT id FROM subtree
+			)`)
+		} else {
+			conditions = append(conditions, "folder_id = ?")
+		}
+		args = append(args, query.FolderID)
+	}
+
+	for _, tag := range query.Tags {
+		normalized, err := filestoreutils.NormalizeTag(tag)
+		if err != nil {
+			log("invalid tag %q: %v", tag, err)
+			return nil, errSearchQuery
+		}
+		conditions = append(conditions, "id IN (SELECT ft.file_id FROM file_tags ft JOIN tags t ON t.id = ft.tag_id WHERE t.name = ?)")
+		args = append(args, normalized)
+	}
+
+	direction, comparison := "ASC", ">"
+	if query.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeSearchCursor(query.Cursor)
+		if err != nil || cursor.SortBy != sortBy || cursor.Desc != query.SortDesc {
+			log("invalid search cursor: %v", err)
+			return nil, errSearchCursor
+		}
+		// keyset pagination: continue after the (sort value, id) of the last file of the previous page
+		conditions = append(conditions, "("+sortColumn+" "+comparison+" ? OR ("+sortColumn+" = ? AND id "+comparison+" ?))")
+		args = append(args, cursor.Value, cursor.Value, cursor.ID)
+	}
+
+	// fetch one extra row to know whether there is a next page
+	args = append(args, limit+1)
+	rows, err := s.db.Query(`
+		SELECT id, name, mime_type, created_at, size, COALESCE(sha256, ''), folder_id, COALESCE(blurhash, ''), created_at || ''
+		FROM files
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY `+sortColumn+` `+direction+`, id `+direction+`
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		log("failed to search files: %v", err)
+		return nil, errSearchFiles
+	}
+	defer rows.Close()
+
+	result := &FileSearchResult{Files: []FileInfo{}}
+	// created_at as stored, as the driver reformats the timestamp scanned into FileInfo.Timestamp
+	var rawDates []string
+	for rows.Next() {
+		var file FileInfo
+		var rawDate string
+		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size, &file.SHA256, &file.FolderID, &file.Blurhash, &rawDate); err != nil {
+			log("failed to scan file: %v", err)
+			return nil, errSearchFiles
+		}
+		result.Files = append(result.Files, file)
+		rawDates = append(rawDates, rawDate)
+	}
+	if err := rows.Err(); err != nil {
+		log("error iterating files: %v", err)
+		return nil, errSearchFiles
+	}
+
+	if len(result.Files) > limit {
+		result.Files = result.Files[:limit]
+		last := result.Files[limit-1]
+		cursor := searchCursor{SortBy: sortBy, Desc: query.SortDesc, ID: last.ID}
+		switch sortBy {
+		case "date":
+			cursor.Value = rawDates[limit-1]
+		case "name":
+			cursor.Value = last.Name
+		case "size":
+			cursor.Value = last.Size
+		case "mimeType":
+			cursor.Value = last.MimeType
+		}
+		result.NextCursor = encodeSearchCursor(cursor)
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package filestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// searchAll pages through the results of a query, returning the IDs of the files in the order they were returned
+func searchAll(t *testing.T, s *service, query FileSearchQuery) []int64 {
+	t.Helper()
+	var ids []int64
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatalf("SearchFiles(%+v) keeps returning pages", query)
+		}
+		result, err := s.SearchFiles(query)
+		if err != nil {
+			t.Fatalf("SearchFiles(%+v) failed: %v", query, err)
+		}
+		if len(result.Files) > query.Limit {
+			t.Fatalf("SearchFiles() returned %d files, more than the limit of %d", len(result.Files), query.Limit)
+		}
+		for _, file := range result.Files {
+			ids = append(ids, file.ID)
+		}
+		if result.NextCursor == "" {
+			return ids
+		}
+		query.Cursor = result.NextCursor
+	}
+}
+
+func TestSearchFilesCursorVisitsEveryFileOnce(t *testing.T) {
+	s := setupServiceTest(t)
+	// files stored within the same second share their date, and pairs of them their size, so that pages break ties
+	sizes := []int{300, 100, 200, 100, 300, 200, 100}
+	var want []int64
+	for i, size := range sizes {
+		id, _ := storeTestFile(t, s, 1, fmt.Sprintf("file-%d.bin", len(sizes)-i), size)
+		want = append(want, id)
+	}
+
+	for _, sortBy := range []string{"date", "name", "size", "mimeType"} {
+		for _, desc := range []bool{false, true} {
+			first := searchAll(t, s, FileSearchQuery{SortBy: sortBy, SortDesc: desc, Limit: len(sizes)})
+			paged := searchAll(t, s, FileSearchQuery{SortBy: sortBy, SortDesc: desc, Limit: 2})
+			if fmt.Sprint(paged) != fmt.Sprint(first) {
+				t.Errorf("Pages sorted by %s (desc %v) = %v, want %v as in a single page", sortBy, desc, paged, first)
+			}
+			if len(first) != len(want) {
+				t.Errorf("Search sorted by %s returned %v, want all of %v", sortBy, first, want)
+			}
+		}
+	}
+
+	sizeOrder := searchAll(t, s, FileSearchQuery{SortBy: "size", Limit: 3})
+	if fmt.Sprint(sizeOrder) != fmt.Sprint([]int64{want[1], want[3], want[6], want[2], want[5], want[0], want[4]}) {
+		t.Errorf("Files sorted by size = %v, want by size and then ID", sizeOrder)
+	}
+}
+
+func TestSearchFilesRefusesForeignCursors(t *testing.T) {
+	s := setupServiceTest(t)
+	for i := 0; i < 3; i++ {
+		storeTestFile(t, s, 1, "file.bin", 100)
+	}
+	result, err := s.SearchFiles(FileSearchQuery{SortBy: "size", Limit: 1})
+	if err != nil || result.NextCursor == "" {
+		t.Fatalf("SearchFiles() = %+v, %v, want a next page", result, err)
+	}
+
+	for name, query := range map[string]FileSearchQuery{
+		"another sort key":  {SortBy: "name", Limit: 1, Cursor: result.NextCursor},
+		"another direction": {SortBy: "size", SortDesc: true, Limit: 1, Cursor: result.NextCursor},
+		"a garbled cursor":  {SortBy: "size", Limit: 1, Cursor: result.NextCursor + "!"},
+	} {
+		if _, err := s.SearchFiles(query); err != errSearchCursor {
+			t.Errorf("SearchFiles() with %s = %v, want %v", name, err, errSearchCursor)
+		}
+	}
+}
+
+func TestSearchFilesMatchesMimeTypesIgnoringCase(t *testing.T) {
+	s := setupServiceTest(t)
+	data := []byte("not really a photo")
+	sum := sha256.Sum256(data)
+	photo, err := s.StoreFile(1, int64(len(data)), hex.EncodeToString(sum[:]), "photo.jpg", "Image/JPEG", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("StoreFile() failed: %v", err)
+	}
+	storeTestFile(t, s, 1, "other.bin", 100)
+
+	for _, mimeType := range []string{"image/jpeg", "IMAGE/JPEG", " image/ ", "Image/"} {
+		ids := searchAll(t, s, FileSearchQuery{MimeType: mimeType, Limit: 10})
+		if len(ids) != 1 || ids[0] != photo.ID {
+			t.Errorf("Search for %q = %v, want file %d", mimeType, ids, photo.ID)
+		}
+	}
+}
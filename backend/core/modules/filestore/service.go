@@ -1,7 +1,9 @@
 package filestore
 
 import (
+	"Tella-Desktop/backend/utils/auditutils"
 	"Tella-Desktop/backend/utils/authutils"
+	"Tella-Desktop/backend/utils/config"
 	"Tella-Desktop/backend/utils/filestoreutils"
 	util "Tella-Desktop/backend/utils/genericutil"
 	"Tella-Desktop/backend/utils/devlog"
@@ -12,7 +14,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
+	"bytes"
 	"crypto/sha256"
 
 	"github.com/gabriel-vasile/mimetype"
@@ -26,20 +30,38 @@ type service struct {
 	db         *sql.DB
 	tvaultPath string
 	dbKey      []byte
+	// vaultMu guards the layout of the TVault: operations allocating, overwriting or relocating ciphertext take the write
+	// lock, operations only reading ciphertext take the read lock. Always acquire it before beginning a transaction.
+	vaultMu    sync.RWMutex
+	// duplicatePolicy is what StoreFile does with content that is already stored, one of the config.DuplicatePolicy
+	// constants. It is guarded by vaultMu.
+	duplicatePolicy string
+	// done is closed by Lock to stop background work, such as the trash purge and export jobs, which background waits
+	// on. lockMu orders closing done against starting background work, see goBackground.
+	done       chan struct{}
+	lockMu     sync.Mutex
+	background sync.WaitGroup
+	// exportJobs holds the running export jobs by ID
+	exportJobs sync.Map
 }
 
 func NewService(ctx context.Context, db *sql.DB, dbKey []byte) Service {
 	return &service{
-		ctx:        ctx,
-		db:         db,
-		tvaultPath: authutils.GetTVaultPath(),
-		dbKey:      dbKey,
+		ctx:             ctx,
+		db:              db,
+		tvaultPath:      authutils.GetTVaultPath(),
+		dbKey:           dbKey,
+		duplicatePolicy: config.ReadConfig().GetDuplicatePolicy(),
+		done:            make(chan struct{}),
 	}
 }
 
 var errStoreFile = errors.New("failed to store file")
 // StoreFile encrypts and stores a file in TVault
 func (s *service) StoreFile(folderID, claimedSize int64, claimedHash string, fileName string, claimedMimeType string, reader io.Reader) (*FileMetadata, error) {
+	s.vaultMu.Lock()
+	defer s.vaultMu.Unlock()
+
 	// Begin Transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -51,51 +73,138 @@ func (s *service) StoreFile(folderID, claimedSize int64, claimedHash string, fil
 	// Generate UUID for the file
 	fileUUID := uuid.New().String()
 
-	// Read the entire file into memory
+	// Read the start of the file to infer its mimetype; the rest is streamed into the TVault chunk by chunk
+	head := make([]byte, filestoreutils.MimeDetectionSize)
+	defer util.SecureZeroMemory(head)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		// need to return "%w" here so we can unwrap it in package transfer
+		return nil, fmt.Errorf("failed to read file data: %w", err)
+	}
+	head = head[:n]
+
+	inferredMIME := mimetype.Detect(head)
+	// TODO cblgh(2026-03-13): decide how to handle mimetype mismatch
+	if inferredMIME != nil && !inferredMIME.Is("application/octet-stream") && !inferredMIME.Is(claimedMimeType) {
+		log("MISMATCH DETECTED: claimed mimetype does not match mimetype based on file data")
+	}
+
 	// TODO cblgh(2026-02-16): when sending a ~200MB (video/quicktime) file i get a 'i/o timeout' error.
 	// this happens when i send from Tella iOS a quicktime video at the same time as a bunch of heic files.
 	// error message:
 	// Upload failed: failed to store file: failed to read file data: i/o timeout
 	//
 	// as a piece of debugging information, it happens after ~150MB is sent.
-	fileData, err := io.ReadAll(reader)
+	region, err := s.writeEncryptedFile(tx, fileUUID, claimedSize, io.MultiReader(bytes.NewReader(head), reader))
 	if err != nil {
-		// need to return "%w" here so we can unwrap it in package transfer
-		return nil, fmt.Errorf("failed to read file data: %w", err)
+		return nil, err
 	}
 
-	// TODO (2026-03-14): convert to use incremental hashing if incremental file storagage + reading is implemented
-	sum := sha256.Sum256(fileData)
-	if fmt.Sprintf("%x", sum) != claimedHash {
+	sum := fmt.Sprintf("%x", region.sum)
+	if sum != claimedHash {
+		s.discardRegion(region)
 		return nil, transferutils.ErrTransferHashMismatch
 	}
 
-	inferredMIME := mimetype.Detect(fileData)
-	// TODO cblgh(2026-03-13): decide how to handle mimetype mismatch
-	if inferredMIME != nil && !inferredMIME.Is("application/octet-stream") && !inferredMIME.Is(claimedMimeType) {
-		log("MISMATCH DETECTED: claimed mimetype does not match mimetype based on file data")
+	log("filestore %q read size %d", fileName, claimedSize)
+
+	// senders often resend the same files: identical content already in the vault needn't be stored twice
+	if s.duplicatePolicy != config.DuplicatePolicyStore {
+		existingID, err := filestoreutils.FindFileByContent(tx, sum, claimedSize)
+		if err != nil {
+			log("failed to look up stored content: %v", err)
+			s.discardRegion(region)
+			return nil, errStoreFile
+		}
+		if existingID != 0 {
+			// nothing written by this store is kept: rolling back returns the region just written to the free list
+			tx.Rollback()
+			s.discardRegion(region)
+			metadata, err := s.storeDuplicate(existingID, fileUUID, fileName, claimedMimeType, folderID)
+			if err == nil {
+				s.audit(auditutils.ActionFileStored, map[string]any{
+					"fileId": metadata.ID, "name": fileName, "size": claimedSize, "sha256": sum, "folderId": folderID,
+					"duplicateOf": existingID, "duplicatePolicy": s.duplicatePolicy,
+				})
+			}
+			return metadata, err
+		}
 	}
 
-	originalSize := int64(len(fileData))
-	log("filestore %q read size %d", fileName, originalSize)
-	if originalSize != claimedSize {
-		log("file %q: downloaded size (%d) did not match claimed size (%d) from prepareUpload (difference: %d)", fileName, originalSize, claimedSize, originalSize-claimedSize)
-		return nil, errStoreFile
+	// Images get a thumbnail, stored encrypted next to the original. Go by the file's contents rather than the claimed
+	// mimetype, as that is what the image decoders will see.
+	imageMIME := claimedMimeType
+	if inferredMIME != nil && !inferredMIME.Is("application/octet-stream") {
+		imageMIME = inferredMIME.String()
 	}
-	fileKey := filestoreutils.GenerateFileKey(fileUUID, s.dbKey)
+	thumbnail := s.writeThumbnail(tx, fileUUID, imageMIME, region)
 
-	// TODO cblgh(2026-02-12): to overwrite fileData with encryptedData, do fileData[:0] -- but will the capacity be sufficient?
-	encryptedData, err := authutils.EncryptData(fileData, fileKey)
+	// Insert file metadata into database
+	fileID, err := filestoreutils.InsertFileMetadata(tx, fileUUID, fileName, claimedSize, claimedMimeType, folderID, region.offset, region.length, filestoreutils.FormatChunked, sum)
+	if err == nil {
+		err = recordThumbnail(tx, fileID, thumbnail)
+	}
 	if err != nil {
-		log("failed to encrypt file: %w", err)
+		log("failed to insert file metadata: %w", err)
+		s.discardThumbnail(thumbnail)
+		s.discardRegion(region)
+		return nil, errStoreFile
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %w", err)
+		s.discardThumbnail(thumbnail)
+		s.discardRegion(region)
 		return nil, errStoreFile
 	}
-	// at this point we have transformed fileData into encryptedData: erase fileData's contents.
-	util.SecureZeroMemory(fileData)
-	// while we're at it: erase encryptedData once we're done here
-	defer util.SecureZeroMemory(encryptedData)
 
-	encryptedSize := int64(len(encryptedData))
+	// Return metadata
+	metadata := &FileMetadata{
+		ID:        fileID,
+		UUID:      fileUUID,
+		Name:      fileName,
+		Size:      claimedSize,
+		MimeType:  claimedMimeType,
+		FolderID:  folderID,
+		Offset:    region.offset,
+		Length:    region.length,
+		SHA256:    sum,
+		CreatedAt: time.Now(),
+	}
+
+	log("Stored file %s (%s) at offset %d with size (encrypted) %d\n", fileName, fileUUID, region.offset, region.length)
+	s.audit(auditutils.ActionFileStored, map[string]any{
+		"fileId": fileID, "name": fileName, "size": claimedSize, "sha256": sum, "folderId": folderID,
+	})
+	return metadata, nil
+}
+
+// writtenRegion describes ciphertext written to the TVault that is not yet referenced by a committed files row
+type writtenRegion struct {
+	offset    int64
+	length    int64
+	vaultSize int64 // size of the TVault before the region was allocated
+	sum       []byte
+}
+
+var errFileSizeMismatch = errors.New("file size did not match claimed size")
+
+// writeEncryptedFile allocates space for a file of exactly `size` bytes in the TVault and streams the plaintext read from
+// `reader` into it using the chunked format, hashing it on the way. Memory use is bounded by the chunk size,
+// regardless of the size of the file.
+func (s *service) writeEncryptedFile(tx *sql.Tx, fileUUID string, size int64, reader io.Reader) (*writtenRegion, error) {
+	if size < 0 {
+		log("file %s: invalid size %d", fileUUID, size)
+		return nil, errStoreFile
+	}
+	encryptedSize := authutils.ChunkedCiphertextSize(size, authutils.DefaultChunkSize)
+
+	vaultInfo, err := os.Stat(s.tvaultPath)
+	if err != nil {
+		log("failed to stat TVault: %w", err)
+		return nil, errStoreFile
+	}
 
 	// Find space in TVault to store the file
 	offset, err := filestoreutils.FindSpace(tx, encryptedSize, s.tvaultPath)
@@ -103,6 +212,7 @@ func (s *service) StoreFile(folderID, claimedSize int64, claimedHash string, fil
 		log("failed to find space in TVault: %w", err)
 		return nil, errStoreFile
 	}
+	region := &writtenRegion{offset: offset, length: encryptedSize, vaultSize: vaultInfo.Size()}
 
 	// Open TVault file
 	tvault, err := os.OpenFile(s.tvaultPath, os.O_RDWR, util.USER_ONLY_FILE_PERMS)
@@ -112,41 +222,60 @@ func (s *service) StoreFile(folderID, claimedSize int64, claimedHash string, fil
 	}
 	defer tvault.Close()
 
-	// Write encrypted data to TVault
-	_, err = tvault.WriteAt(encryptedData, offset)
+	fileKey := filestoreutils.GenerateFileKey(fileUUID, s.dbKey)
+	defer util.SecureZeroMemory(fileKey)
+	encrypter, err := authutils.NewChunkedWriter(io.NewOffsetWriter(tvault, offset), fileKey, []byte(fileUUID))
 	if err != nil {
 		log("failed to write to TVault: %w", err)
+		s.discardRegion(region)
 		return nil, errStoreFile
 	}
 
-	// Insert file metadata into database
-	fileID, err := filestoreutils.InsertFileMetadata(tx, fileUUID, fileName, originalSize, claimedMimeType, folderID, offset, encryptedSize)
+	hasher := sha256.New()
+	copyBuf := make([]byte, 32*1024)
+	defer util.SecureZeroMemory(copyBuf)
+
+	// never write more than the space we allocated: copy exactly `size` bytes and then make sure nothing is left
+	copied, err := io.CopyBuffer(io.MultiWriter(encrypter, hasher), io.LimitReader(reader, size), copyBuf)
+	if err == nil && copied < size {
+		err = errFileSizeMismatch
+	}
+	if err == nil {
+		if extra, _ := io.ReadFull(reader, copyBuf[:1]); extra > 0 {
+			err = errFileSizeMismatch
+		}
+	}
 	if err != nil {
-		log("failed to insert file metadata: %w", err)
-		return nil, errStoreFile
+		encrypter.Discard()
+		s.discardRegion(region)
+		if errors.Is(err, errFileSizeMismatch) {
+			log("file %s: downloaded size did not match claimed size (%d)", fileUUID, size)
+			return nil, errStoreFile
+		}
+		// need to return "%w" here so we can unwrap it in package transfer
+		return nil, fmt.Errorf("failed to read file data: %w", err)
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		log("failed to commit transaction: %w", err)
+	if err := encrypter.Close(); err != nil {
+		log("failed to write to TVault: %w", err)
+		s.discardRegion(region)
 		return nil, errStoreFile
 	}
 
-	// Return metadata
-	metadata := &FileMetadata{
-		ID:        fileID,
-		UUID:      fileUUID,
-		Name:      fileName,
-		Size:      originalSize,
-		MimeType:  claimedMimeType,
-		FolderID:  folderID,
-		Offset:    offset,
-		Length:    encryptedSize,
-		CreatedAt: time.Now(),
-	}
+	region.sum = hasher.Sum(nil)
+	return region, nil
+}
 
-	log("Stored file %s (%s) at offset %d with size (encrypted) %d\n", fileName, fileUUID, offset, encryptedSize)
-	return metadata, nil
+// discardRegion gives back TVault space written to by an aborted store. If the allocation grew the TVault, it is
+// truncated back to its previous size, while space allocated from free_spaces is given back when the surrounding
+// transaction is rolled back.
+func (s *service) discardRegion(region *writtenRegion) {
+	if region.offset+region.length <= region.vaultSize {
+		return
+	}
+	if err := os.Truncate(s.tvaultPath, region.vaultSize); err != nil {
+		log("failed to truncate TVault after aborted store: %v", err)
+	}
 }
 
 var errGetFolders = errors.New("failed to get folders")
@@ -201,7 +330,7 @@ func (s *service) GetFilesInFolder(folderID int64) (*FilesInFolderResponse, erro
 	}
 
 	rows, err := s.db.Query(`
-		SELECT id, name, mime_type, created_at, size 
+		SELECT id, name, mime_type, created_at, size, COALESCE(sha256, ''), COALESCE(blurhash, '')
 		FROM files 
 		WHERE folder_id = ? AND is_deleted = 0 
 		ORDER BY created_at DESC
@@ -215,10 +344,11 @@ func (s *service) GetFilesInFolder(folderID int64) (*FilesInFolderResponse, erro
 	var files []FileInfo
 	for rows.Next() {
 		var file FileInfo
-		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size); err != nil {
+		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size, &file.SHA256, &file.Blurhash); err != nil {
 			log("failed to scan file: %w", err)
 			return nil, errGetFilesFolder
 		}
+		file.FolderID = folderID
 		files = append(files, file)
 	}
 
@@ -235,10 +365,25 @@ func (s *service) GetFilesInFolder(folderID int64) (*FilesInFolderResponse, erro
 
 var errExportFiles = errors.New("failed to export files")
 func (s *service) ExportFiles(ids []int64) ([]string, error) {
+	return s.ExportFilesWithOptions(ids, ExportOptions{})
+}
+
+// ExportFilesWithOptions exports files to the user's downloads directory. With a manifest, the paths of its JSON and
+// CSV sidecar files follow the paths of the exported files.
+func (s *service) ExportFilesWithOptions(ids []int64, options ExportOptions) ([]string, error) {
+	return s.exportFiles(ids, options, nil)
+}
+
+// exportFiles is ExportFilesWithOptions, reporting to job if it runs as one. A cancelled export removes every file it
+// wrote and returns filestoreutils.ErrExportCancelled.
+func (s *service) exportFiles(ids []int64, options ExportOptions, job *exportJob) ([]string, error) {
 	if len(ids) == 0 {
 		log("no file IDs provided")
 		return nil, errExportFiles
 	}
+	if err := checkLooseExport(options); err != nil {
+		return nil, err
+	}
 
 	if len(ids) == 1 {
 		log("Exporting single file with ID: %d", ids[0])
@@ -246,14 +391,34 @@ func (s *service) ExportFiles(ids []int64) ([]string, error) {
 		log("Exporting %d files in batch", len(ids))
 	}
 
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
+
 	var exportedPaths []string
+	// signatures aren't returned, they are found next to what they sign
+	var signaturePaths []string
+	var exportedFiles []filestoreutils.ExportedFile
 	var failedFiles []string
 
+	var totalBytes int64
+	for _, id := range ids {
+		if metadata, err := filestoreutils.GetFileMetadataByID(s.db, id); err == nil {
+			totalBytes += metadata.Size
+		}
+	}
+	job.planned(len(ids), totalBytes)
+
+	// the manifest gets signed
+	if options.IncludeManifest {
+		if err := s.checkSigningKey(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get export directory once
-	exportDir := authutils.GetExportDir()
-	if err := os.MkdirAll(exportDir, util.USER_ONLY_DIR_PERMS); err != nil {
-		log("failed to create export dir: %w", err)
-		return nil, errExportFiles
+	exportDir, err := filestoreutils.ExportDir(options.Destination, totalBytes)
+	if err != nil {
+		return nil, err
 	}
 
 	// Open TVault once for all operations
@@ -266,14 +431,24 @@ func (s *service) ExportFiles(ids []int64) ([]string, error) {
 
 	for _, id := range ids {
 		// Export each file individually
-		exportPath, err := filestoreutils.ExportSingleFile(s.db, s.dbKey, id, tvault, exportDir)
+		exported, err := filestoreutils.ExportSingleFile(s.db, s.dbKey, id, tvault, exportDir, job.tracker())
+		if errors.Is(err, filestoreutils.ErrExportCancelled) {
+			log("Export cancelled, removing %d exported files", len(exportedPaths))
+			removeExports(exportedPaths)
+			return nil, err
+		}
+		if job != nil {
+			job.FileDone()
+		}
 		if err != nil {
 			log("Failed to export file ID %d: %v", id, err)
 			failedFiles = append(failedFiles, fmt.Sprintf("ID %d", id))
 			continue
 		}
+		exportPath := exported.Path
 
 		exportedPaths = append(exportedPaths, exportPath)
+		exportedFiles = append(exportedFiles, *exported)
 		if len(ids) == 1 {
 			log("File exported successfully to: %s", exportPath)
 		} else {
@@ -290,37 +465,100 @@ func (s *service) ExportFiles(ids []int64) ([]string, error) {
 		log("Warning: Some files failed to export: %v", failedFiles)
 	}
 
+	// an export asked to have a manifest is incomplete without it, so every file written is removed if it fails
+	if options.IncludeManifest {
+		manifest, err := filestoreutils.BuildManifest(s.db, exportedFiles)
+		if err != nil {
+			log("failed to build manifest, removing %d exported files: %v", len(exportedPaths), err)
+			removeExports(exportedPaths)
+			return nil, errExportFiles
+		}
+		manifestPaths, err := filestoreutils.WriteManifestSidecars(manifest, exportDir)
+		exportedPaths = append(exportedPaths, manifestPaths...)
+		if err != nil {
+			log("failed to write manifest, removing %d exported files: %v", len(exportedPaths), err)
+			removeExports(exportedPaths)
+			return nil, errExportFiles
+		}
+		// the manifest holds the hash of every exported file, so signing it vouches for all of them
+		for _, manifestPath := range manifestPaths {
+			sigPath, err := s.signExport(manifestPath)
+			if err != nil {
+				log("failed to sign manifest, removing %d exported files: %v", len(exportedPaths)+len(signaturePaths), err)
+				removeExports(append(exportedPaths, signaturePaths...))
+				return nil, errExportFiles
+			}
+			signaturePaths = append(signaturePaths, sigPath)
+		}
+	}
+
+	// the last chance to cancel: once the export is audited, it is done
+	if err := job.Err(); err != nil {
+		log("Export cancelled, removing %d exported files", len(exportedPaths)+len(signaturePaths))
+		removeExports(append(exportedPaths, signaturePaths...))
+		return nil, err
+	}
+
 	if len(ids) == 1 {
 		log("Export completed successfully")
 	} else {
-		log("Batch export completed: %d/%d files exported successfully", len(exportedPaths), len(ids))
+		log("Batch export completed: %d/%d files exported successfully", len(exportedFiles), len(ids))
 	}
 
+	exportedIDs := make([]int64, 0, len(exportedFiles))
+	for _, file := range exportedFiles {
+		exportedIDs = append(exportedIDs, file.FileID)
+	}
+	s.audit(auditutils.ActionFilesExported, map[string]any{
+		"format": "files", "fileIds": exportedIDs, "manifest": options.IncludeManifest, "paths": exportedPaths,
+		"signatures": signaturePaths,
+	})
 	return exportedPaths, nil
 }
 
 var errExportZipFolders = errors.New("failed to export zip folders")
 func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) ([]string, error) {
+	return s.ExportZipFoldersWithOptions(folderIDs, selectedFileIDs, ExportOptions{})
+}
+
+func (s *service) ExportZipFoldersWithOptions(folderIDs []int64, selectedFileIDs []int64, options ExportOptions) ([]string, error) {
+	return s.exportZipFolders(folderIDs, selectedFileIDs, options, nil)
+}
+
+// folderExport is a folder to be exported as an archive, with the files going into it
+type folderExport struct {
+	name  string
+	files []filestoreutils.FileInfo
+}
+
+// exportZipFolders is ExportZipFoldersWithOptions, reporting to job if it runs as one. A cancelled export removes
+// every archive it wrote and returns filestoreutils.ErrExportCancelled.
+func (s *service) exportZipFolders(folderIDs []int64, selectedFileIDs []int64, options ExportOptions, job *exportJob) ([]string, error) {
 	if len(folderIDs) == 0 {
 		log("no folder IDs provided")
 		return nil, errExportZipFolders
 	}
-
-	var exportedPaths []string
-	exportDir := authutils.GetExportDir()
-	if err := os.MkdirAll(exportDir, util.USER_ONLY_DIR_PERMS); err != nil {
-		log("failed to create export dir: %w", err)
-		return nil, errExportZipFolders
-	}
-
-	// Open TVault once for all operations
-	tvault, err := os.Open(s.tvaultPath)
+	format, err := archiveFormat(options)
 	if err != nil {
-		log("failed to open TVault: %w", err)
-		return nil, errExportZipFolders
+		return nil, err
 	}
-	defer tvault.Close()
+	// every archive gets signed
+	if err := s.checkSigningKey(); err != nil {
+		return nil, err
+	}
+
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
 
+	var exportedPaths []string
+	// signatures aren't returned, they are found next to what they sign
+	var signaturePaths []string
+	var exportedIDs []int64
+
+	// the files of every folder are listed up front, so that the size of the whole export is known
+	var folders []folderExport
+	var totalFiles int
+	var totalBytes int64
 	for _, folderID := range folderIDs {
 		// Get folder info using filestoreutils
 		folderInfo, err := filestoreutils.GetFolderInfo(s.db, folderID)
@@ -351,6 +589,7 @@ func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) (
 					MimeType:  file.MimeType,
 					Timestamp: file.Timestamp,
 					Size:      file.Size,
+					SHA256:    file.SHA256,
 				})
 			}
 		}
@@ -365,14 +604,50 @@ func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) (
 			continue
 		}
 
+		folders = append(folders, folderExport{name: folderInfo.Name, files: filesToExport})
+		totalFiles += len(filesToExport)
+		for _, file := range filesToExport {
+			totalBytes += file.Size
+		}
+	}
+	job.planned(totalFiles, totalBytes)
+
+	exportDir, err := filestoreutils.ExportDir(options.Destination, totalBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open TVault once for all operations
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		log("failed to open TVault: %w", err)
+		return nil, errExportZipFolders
+	}
+	defer tvault.Close()
+
+	for _, folder := range folders {
 		// Create ZIP file using filestoreutils
-		zipPath, err := filestoreutils.CreateZipFile(s.db, s.dbKey, folderInfo.Name, filesToExport, tvault, exportDir)
+		zipPath, err := filestoreutils.CreateArchive(s.db, s.dbKey, folder.name, folder.files, tvault, exportDir, format, options.Passphrase, options.IncludeManifest, job.tracker())
+		if errors.Is(err, filestoreutils.ErrExportCancelled) {
+			log("Export cancelled, removing %d exported files", len(exportedPaths)+len(signaturePaths))
+			removeExports(append(exportedPaths, signaturePaths...))
+			return nil, err
+		}
 		if err != nil {
-			log("Failed to create ZIP for folder '%s': %v", folderInfo.Name, err)
+			log("Failed to create ZIP for folder '%s': %v", folder.name, err)
 			continue
 		}
-
 		exportedPaths = append(exportedPaths, zipPath)
+		sigPath, err := s.signExport(zipPath)
+		if err != nil {
+			log("Failed to sign ZIP for folder '%s', removing %d exported files: %v", folder.name, len(exportedPaths)+len(signaturePaths), err)
+			removeExports(append(exportedPaths, signaturePaths...))
+			return nil, errExportZipFolders
+		}
+		signaturePaths = append(signaturePaths, sigPath)
+		for _, file := range folder.files {
+			exportedIDs = append(exportedIDs, file.ID)
+		}
 		log("ZIP created successfully: %s", zipPath)
 	}
 
@@ -381,10 +656,94 @@ func (s *service) ExportZipFolders(folderIDs []int64, selectedFileIDs []int64) (
 		return nil, errExportZipFolders
 	}
 
+	if err := job.Err(); err != nil {
+		log("Export cancelled, removing %d exported files", len(exportedPaths)+len(signaturePaths))
+		removeExports(append(exportedPaths, signaturePaths...))
+		return nil, err
+	}
+
 	log("ZIP export completed: %d ZIP files created", len(exportedPaths))
+	s.audit(auditutils.ActionFilesExported, map[string]any{
+		"format": format, "folderIds": folderIDs, "fileIds": exportedIDs, "manifest": options.IncludeManifest,
+		"paths": exportedPaths, "signatures": signaturePaths,
+	})
 	return exportedPaths, nil
 }
 
+var errExportZipFiles = errors.New("failed to export files as zip")
+func (s *service) ExportZipFiles(zipName string, ids []int64) (string, error) {
+	return s.ExportZipFilesWithOptions(zipName, ids, ExportOptions{})
+}
+
+func (s *service) ExportZipFilesWithOptions(zipName string, ids []int64, options ExportOptions) (string, error) {
+	if len(ids) == 0 {
+		log("no file IDs provided")
+		return "", errExportZipFiles
+	}
+	format, err := archiveFormat(options)
+	if err != nil {
+		return "", err
+	}
+	// the archive gets signed
+	if err := s.checkSigningKey(); err != nil {
+		return "", err
+	}
+
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
+
+	var filesToExport []filestoreutils.FileInfo
+	var totalBytes int64
+	for _, id := range ids {
+		metadata, err := filestoreutils.GetFileMetadataByID(s.db, id)
+		if err != nil {
+			log("Failed to get file %d: %v", id, err)
+			continue
+		}
+		filesToExport = append(filesToExport, filestoreutils.FileInfo{ID: id, Name: metadata.Name, MimeType: metadata.MimeType, SHA256: metadata.SHA256})
+		totalBytes += metadata.Size
+	}
+	if len(filesToExport) == 0 {
+		log("no files to export")
+		return "", errExportZipFiles
+	}
+
+	exportDir, err := filestoreutils.ExportDir(options.Destination, totalBytes)
+	if err != nil {
+		return "", err
+	}
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		log("failed to open TVault: %w", err)
+		return "", errExportZipFiles
+	}
+	defer tvault.Close()
+
+	zipPath, err := filestoreutils.CreateArchive(s.db, s.dbKey, zipName, filesToExport, tvault, exportDir, format, options.Passphrase, options.IncludeManifest, nil)
+	if err != nil {
+		log("Failed to create ZIP '%s': %v", zipName, err)
+		return "", errExportZipFiles
+	}
+	sigPath, err := s.signExport(zipPath)
+	if err != nil {
+		log("Failed to sign ZIP '%s', removing it: %v", zipName, err)
+		removeExports([]string{zipPath})
+		return "", errExportZipFiles
+	}
+
+	log("ZIP created successfully: %s", zipPath)
+	exportedIDs := make([]int64, 0, len(filesToExport))
+	for _, file := range filesToExport {
+		exportedIDs = append(exportedIDs, file.ID)
+	}
+	s.audit(auditutils.ActionFilesExported, map[string]any{
+		"format": format, "fileIds": exportedIDs, "manifest": options.IncludeManifest, "paths": []string{zipPath},
+		"signatures": []string{sigPath},
+	})
+	return zipPath, nil
+}
+
 var errDeleteFiles = errors.New("error when deleting files")
 func (s *service) DeleteFiles(ids []int64) error {
 	if len(ids) == 0 {
@@ -392,6 +751,9 @@ func (s *service) DeleteFiles(ids []int64) error {
 		return errDeleteFiles
 	}
 
+	s.vaultMu.Lock()
+	defer s.vaultMu.Unlock()
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -412,7 +774,7 @@ func (s *service) DeleteFiles(ids []int64) error {
 		return errDeleteFiles
 	}
 
-	// Mark files as deleted in database and add to free spaces
+	// Mark files as deleted in database
 	for _, metadata := range filesMetadata {
 		_, err := tx.Exec(`
 			UPDATE files 
@@ -424,14 +786,33 @@ func (s *service) DeleteFiles(ids []int64) error {
 			log("failed to mark file %d as deleted: %w", metadata.ID, err)
 			return errDeleteFiles
 		}
+	}
 
-		// Add the file's space to free_spaces table
-		err = filestoreutils.AddFreeSpace(tx, metadata.Offset, metadata.Length)
-		if err != nil {
-			log("failed to add free space for file %d: %w", metadata.ID, err)
+	// Files purged from the trash are already marked as deleted, and only leave the trash
+	if err := filestoreutils.RemoveFromTrash(tx, ids); err != nil {
+		log("failed to remove deleted files from the trash: %v", err)
+		return errDeleteFiles
+	}
+
+	// tags and custom metadata can be as sensitive as the files themselves
+	for _, metadata := range filesMetadata {
+		if err := filestoreutils.AnnotatedFiles.DeleteAnnotations(tx, metadata.ID); err != nil {
+			log("failed to delete annotations of file %d: %v", metadata.ID, err)
 			return errDeleteFiles
 		}
 	}
+	if err := filestoreutils.DeleteUnusedTags(tx); err != nil {
+		log("failed to delete unused tags: %v", err)
+		return errDeleteFiles
+	}
+
+	// Add the space of the files and their thumbnails to free_spaces table. Linked files share their ciphertext, so
+	// a region is only freed once no file is left referencing it.
+	freed, err := freeUnreferencedRegions(tx, filesMetadata)
+	if err != nil {
+		log("failed to free space of deleted files: %w", err)
+		return errDeleteFiles
+	}
 
 	// Commit database transaction first
 	if err := tx.Commit(); err != nil {
@@ -440,25 +821,80 @@ func (s *service) DeleteFiles(ids []int64) error {
 	}
 
 	// Now securely overwrite the file data in TVault
-	for _, metadata := range filesMetadata {
-		err := filestoreutils.SecurelyOverwriteFileData(s.tvaultPath, metadata.Offset, metadata.Length)
+	for _, region := range freed {
+		err := filestoreutils.SecurelyOverwriteFileData(s.tvaultPath, region.Offset, region.Length)
 		if err != nil {
 			// Log error but don't fail the entire operation since DB is already updated
-			log("Warning: Failed to securely overwrite data for file %s (ID: %d): %v\n",
-				metadata.Name, metadata.ID, err)
+			log("Warning: Failed to securely overwrite data at offset %d: %v\n", region.Offset, err)
 		}
 	}
 
+	// names and hashes are in the entries recording how the files were stored
+	deletedIDs := make([]int64, 0, len(filesMetadata))
+	for _, metadata := range filesMetadata {
+		deletedIDs = append(deletedIDs, metadata.ID)
+	}
+	s.audit(auditutils.ActionFilesDeleted, map[string]any{"fileIds": deletedIDs})
 	return nil
 }
 
+// freeUnreferencedRegions adds the regions of deleted files and their thumbnails that no other file references to the
+// free list, returning them so they can be overwritten
+func freeUnreferencedRegions(tx *sql.Tx, deleted []filestoreutils.FileMetadata) ([]filestoreutils.VaultRegion, error) {
+	seen := map[filestoreutils.VaultRegion]bool{}
+	var freed []filestoreutils.VaultRegion
+	for _, metadata := range deleted {
+		for _, region := range []filestoreutils.VaultRegion{
+			{Offset: metadata.Offset, Length: metadata.Length},
+			{Offset: metadata.ThumbnailOffset, Length: metadata.ThumbnailLength},
+		} {
+			if region.Length <= 0 || seen[region] {
+				continue
+			}
+			seen[region] = true
+
+			referenced, err := filestoreutils.RegionReferenced(tx, region)
+			if err != nil {
+				return nil, err
+			}
+			if referenced {
+				continue
+			}
+			if err := filestoreutils.AddFreeSpace(tx, region.Offset, region.Length); err != nil {
+				return nil, err
+			}
+			freed = append(freed, region)
+		}
+	}
+	return freed, nil
+}
+
 var errDeleteFolders = errors.New("error when deleting folders")
+
+// DeleteFolders deletes folders along with their subfolders, securely deleting the files in them right away
 func (s *service) DeleteFolders(folderIDs []int64) error {
+	return s.deleteFolders(folderIDs, false)
+}
+
+// TrashFolders deletes folders along with their subfolders, moving the files in them to the trash. Restoring such a
+// file recreates the folders it was in, see RestoreFiles.
+func (s *service) TrashFolders(folderIDs []int64) error {
+	return s.deleteFolders(folderIDs, true)
+}
+
+func (s *service) deleteFolders(folderIDs []int64, trash bool) error {
 	if len(folderIDs) == 0 {
 		log("no folder IDs provided for deletion")
 		return errDeleteFolders
 	}
 
+	// Deleting a folder deletes all of its subfolders too
+	folderIDs, err := s.getFolderSubtrees(folderIDs)
+	if err != nil {
+		log("failed to get subfolders: %w", err)
+		return errDeleteFolders
+	}
+
 	// First, get all file IDs in the selected folders
 	fileIDs, err := s.getFileIDsInFolders(folderIDs)
 	if err != nil {
@@ -466,16 +902,19 @@ func (s *service) DeleteFolders(folderIDs []int64) error {
 		return errDeleteFolders
 	}
 
-	// Delete all files using the existing DeleteFiles method
 	if len(fileIDs) > 0 {
-		err = s.DeleteFiles(fileIDs)
+		if trash {
+			err = s.TrashFiles(fileIDs)
+		} else {
+			err = s.DeleteFiles(fileIDs)
+		}
 		if err != nil {
 			log("failed to delete files in folders: %w", err)
 			return errDeleteFolders
 		}
 	}
 
-	// Now delete the empty folders
+	// Now delete the empty folders, subfolders before their parents
 	tx, err := s.db.Begin()
 	if err != nil {
 		log("failed to begin transaction: %w", err)
@@ -485,20 +924,36 @@ func (s *service) DeleteFolders(folderIDs []int64) error {
 
 	for _, folderID := range folderIDs {
 		_, err := tx.Exec("DELETE FROM folders WHERE id = ?", folderID)
+		if err == nil {
+			err = filestoreutils.AnnotatedFolders.DeleteAnnotations(tx, folderID)
+		}
 		if err != nil {
 			log("failed to delete folder %d: %w", folderID, err)
 			return errDeleteFolders
 		}
 	}
+	if err := filestoreutils.DeleteUnusedTags(tx); err != nil {
+		log("failed to delete unused tags: %w", err)
+		return errDeleteFolders
+	}
 
 	if err := tx.Commit(); err != nil {
 		log("failed to commit folder deletion: %w", err)
 		return errDeleteFolders
 	}
 
+	s.audit(auditutils.ActionFoldersDeleted, map[string]any{"folderIds": folderIDs, "fileCount": len(fileIDs), "trashed": trash})
 	return nil
 }
 
+// audit records a completed operation in the audit log. The operation has already taken effect, so failing to record
+// it is only logged. No transaction may be open.
+func (s *service) audit(action string, details any) {
+	if err := auditutils.Append(s.db, action, details); err != nil {
+		log("failed to record %s in the audit log: %v", action, err)
+	}
+}
+
 // Helper method to get all file IDs in the specified folders
 var errGetFileIDsFolders = errors.New("error when getting files")
 func (s *service) getFileIDsInFolders(folderIDs []int64) ([]int64, error) {
@@ -0,0 +1,96 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/utils/config"
+	"Tella-Desktop/backend/utils/constants"
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+// setupServiceTest returns a service on a new database and an empty TVault, with a top-level folder with ID 1
+func setupServiceTest(t *testing.T) *service {
+	t.Helper()
+	dir := t.TempDir()
+
+	key := make([]byte, constants.KeyLength)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	db, err := database.Initialize(filepath.Join(dir, "tella.db"), key)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("INSERT INTO folders (name) VALUES ('Received Files')"); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	tvaultPath := filepath.Join(dir, "tvault")
+	if err := os.WriteFile(tvaultPath, make([]byte, constants.TVaultHeaderSize), 0600); err != nil {
+		t.Fatalf("Failed to create TVault: %v", err)
+	}
+
+	// there's no frontend to send events to
+	emitEvent = func(context.Context, string, ...interface{}) {}
+	s := &service{
+		ctx:             context.Background(),
+		db:              db.DB,
+		tvaultPath:      tvaultPath,
+		dbKey:           key,
+		duplicatePolicy: config.DuplicatePolicyStore,
+		done:            make(chan struct{}),
+	}
+	t.Cleanup(s.Lock)
+	return s
+}
+
+// storeTestFile stores size random bytes as a file named name in folderID, returning its ID and contents
+func storeTestFile(t *testing.T, s *service, folderID int64, name string, size int) (int64, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("Failed to generate file: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	metadata, err := s.StoreFile(folderID, int64(size), hex.EncodeToString(sum[:]), name, "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("StoreFile() failed: %v", err)
+	}
+	return metadata.ID, data
+}
+
+// checkTestFile fails the test unless a live file decrypts to want
+func checkTestFile(t *testing.T, s *service, fileID int64, want []byte) {
+	t.Helper()
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, fileID)
+	if err != nil {
+		t.Fatalf("File %d is not live: %v", fileID, err)
+	}
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		t.Fatalf("Failed to open TVault: %v", err)
+	}
+	defer tvault.Close()
+	reader, err := filestoreutils.OpenFileReader(metadata, s.dbKey, tvault)
+	if err != nil {
+		t.Fatalf("OpenFileReader(%d) failed: %v", fileID, err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read file %d: %v", fileID, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("File %d has %d bytes that differ from the %d stored", fileID, len(got), len(want))
+	}
+}
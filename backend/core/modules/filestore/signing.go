@@ -0,0 +1,36 @@
+package filestore
+
+import (
+	"errors"
+
+	util "Tella-Desktop/backend/utils/genericutil"
+	"Tella-Desktop/backend/utils/signutils"
+)
+
+// signExport signs an exported file with the vault's signing key, returning the path of the signature written next
+// to it
+func (s *service) signExport(path string) (string, error) {
+	privateKey, err := signutils.LoadKey(s.db)
+	if err != nil {
+		return "", err
+	}
+	defer util.SecureZeroMemory(privateKey)
+	return signutils.SignFile(privateKey, path)
+}
+
+var errSigningKey = errors.New("failed to load the signing key")
+
+// checkSigningKey makes sure the vault's signing key can be loaded, so that an export that gets signed fails before
+// anything is written rather than leaving unsigned files behind. It returns signutils.ErrNoKey for vaults without one.
+func (s *service) checkSigningKey() error {
+	privateKey, err := signutils.LoadKey(s.db)
+	if errors.Is(err, signutils.ErrNoKey) {
+		return err
+	}
+	if err != nil {
+		log("failed to load signing key: %v", err)
+		return errSigningKey
+	}
+	util.SecureZeroMemory(privateKey)
+	return nil
+}
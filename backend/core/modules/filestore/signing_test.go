@@ -0,0 +1,59 @@
+package filestore
+
+import (
+	"os"
+	"testing"
+
+	"Tella-Desktop/backend/utils/signutils"
+)
+
+func TestArchiveExportsAreSigned(t *testing.T) {
+	s := setupServiceTest(t)
+	if err := signutils.EnsureKey(s.db); err != nil {
+		t.Fatalf("EnsureKey() failed: %v", err)
+	}
+	cases := createTestFolder(t, s, "Cases", 0)
+	notes := createTestFolder(t, s, "Notes", 0)
+	first, _ := storeTestFile(t, s, cases, "first.bin", 1000)
+	second, _ := storeTestFile(t, s, notes, "second.bin", 1000)
+	exportDir := t.TempDir()
+
+	paths, err := s.ExportZipFoldersWithOptions([]int64{cases, notes}, nil, ExportOptions{Destination: exportDir})
+	if err != nil {
+		t.Fatalf("ExportZipFoldersWithOptions() failed: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("ExportZipFoldersWithOptions() = %v, want one archive for each folder", paths)
+	}
+	zipPath, err := s.ExportZipFilesWithOptions("Report", []int64{first, second}, ExportOptions{Destination: exportDir})
+	if err != nil {
+		t.Fatalf("ExportZipFilesWithOptions() failed: %v", err)
+	}
+
+	for _, path := range append(paths, zipPath) {
+		verification, err := signutils.VerifyFile(path)
+		if err != nil || !verification.Valid {
+			t.Errorf("VerifyFile(%s) = %+v, %v, want a valid signature", path, verification, err)
+		}
+	}
+	if entries, err := os.ReadDir(exportDir); err != nil || len(entries) != 6 {
+		t.Errorf("Export directory holds %v, %v, want three archives and their signatures", entries, err)
+	}
+}
+
+func TestArchiveExportsNeedSigningKey(t *testing.T) {
+	s := setupServiceTest(t)
+	cases := createTestFolder(t, s, "Cases", 0)
+	first, _ := storeTestFile(t, s, cases, "first.bin", 1000)
+	exportDir := t.TempDir()
+
+	if _, err := s.ExportZipFoldersWithOptions([]int64{cases}, nil, ExportOptions{Destination: exportDir}); err != signutils.ErrNoKey {
+		t.Errorf("ExportZipFoldersWithOptions() without a signing key = %v, want %v", err, signutils.ErrNoKey)
+	}
+	if _, err := s.ExportZipFilesWithOptions("Report", []int64{first}, ExportOptions{Destination: exportDir}); err != signutils.ErrNoKey {
+		t.Errorf("ExportZipFilesWithOptions() without a signing key = %v, want %v", err, signutils.ErrNoKey)
+	}
+	if entries, err := os.ReadDir(exportDir); err != nil || len(entries) != 0 {
+		t.Errorf("Export directory holds %v, %v, want nothing written", entries, err)
+	}
+}
@@ -0,0 +1,80 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/diskutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var errVaultStats = errors.New("failed to get vault statistics")
+
+func (s *service) GetVaultStats() (*VaultStats, error) {
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
+
+	vaultInfo, err := os.Stat(s.tvaultPath)
+	if err != nil {
+		log("failed to stat TVault: %v", err)
+		return nil, errVaultStats
+	}
+	stats := &VaultStats{VaultSize: vaultInfo.Size(), Folders: []FolderUsage{}}
+
+	stats.DiskFreeBytes, err = diskutils.FreeSpace(filepath.Dir(s.tvaultPath))
+	if err != nil {
+		log("failed to get free disk space: %v", err)
+		stats.DiskFreeBytes = -1
+	}
+
+	// a single transaction, so that all numbers describe the same state of the vault
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return nil, errVaultStats
+	}
+	defer tx.Rollback()
+
+	regions, err := filestoreutils.GetReferencedRegions(tx)
+	if err != nil {
+		log("failed to query referenced regions: %v", err)
+		return nil, errVaultStats
+	}
+	for _, region := range regions {
+		stats.LiveBytes += region.Length
+	}
+
+	stats.FreeBytes, stats.FreeBlocks, stats.LargestFreeBlock, err = filestoreutils.GetFreeSpaceStats(tx)
+	if err != nil {
+		log("failed to query free spaces: %v", err)
+		return nil, errVaultStats
+	}
+
+	rows, err := tx.Query(`
+		SELECT fo.id, fo.name, COALESCE(fo.parent_id, 0), COUNT(f.id),
+			COALESCE(SUM(f.length + COALESCE(f.thumbnail_length, 0)), 0) AS bytes
+		FROM folders fo
+		LEFT JOIN files f ON f.folder_id = fo.id AND f.is_deleted = 0
+		GROUP BY fo.id
+		ORDER BY bytes DESC, fo.id ASC
+	`)
+	if err != nil {
+		log("failed to query folder usage: %v", err)
+		return nil, errVaultStats
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var folder FolderUsage
+		if err := rows.Scan(&folder.FolderID, &folder.Name, &folder.ParentID, &folder.FileCount, &folder.Bytes); err != nil {
+			log("failed to scan folder usage: %v", err)
+			return nil, errVaultStats
+		}
+		stats.Folders = append(stats.Folders, folder)
+	}
+	if err := rows.Err(); err != nil {
+		log("error iterating folder usage: %v", err)
+		return nil, errVaultStats
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,75 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	util "Tella-Desktop/backend/utils/genericutil"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// FileStream is a seekable view of the decrypted contents of a stored file. Close releases the TVault and erases any
+// plaintext held by the stream.
+type FileStream struct {
+	filestoreutils.FileReader
+	Name string
+	// MimeType is inferred from the file's contents, falling back to the mimetype claimed by the sender
+	MimeType string
+	tvault   *os.File
+}
+
+func (f *FileStream) Close() error {
+	err := f.FileReader.Close()
+	if closeErr := f.tvault.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+var errOpenFileStream = errors.New("failed to open file")
+
+// OpenFileStream opens a file for reading its plaintext piece by piece, e.g. to serve ranges of it. Only files in the
+// chunked format are decrypted chunk by chunk as they are read; files in the older blob format are decrypted into
+// memory in full every time they are opened, see filestoreutils.OpenFileReader. The vault lock is only held while
+// opening: should the file be relocated or deleted while the stream is read, its old region no longer
+// authenticates under the file's key, so reads fail rather than return another file's data.
+func (s *service) OpenFileStream(fileID int64) (*FileStream, error) {
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
+
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, fileID)
+	if err != nil {
+		return nil, errOpenFileStream
+	}
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		log("failed to open TVault: %v", err)
+		return nil, errOpenFileStream
+	}
+
+	reader, err := filestoreutils.OpenFileReader(metadata, s.dbKey, tvault)
+	if err != nil {
+		log("failed to decrypt file %d: %v", fileID, err)
+		tvault.Close()
+		return nil, errOpenFileStream
+	}
+
+	head := make([]byte, filestoreutils.MimeDetectionSize)
+	defer util.SecureZeroMemory(head)
+	n, err := reader.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		log("failed to decrypt file %d: %v", fileID, err)
+		reader.Close()
+		tvault.Close()
+		return nil, errOpenFileStream
+	}
+	mimeType := metadata.MimeType
+	if inferredMIME := mimetype.Detect(head[:n]); !inferredMIME.Is("application/octet-stream") {
+		mimeType = inferredMIME.String()
+	}
+
+	return &FileStream{FileReader: reader, Name: metadata.Name, MimeType: mimeType, tvault: tvault}, nil
+}
@@ -0,0 +1,175 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"errors"
+)
+
+var errGetTags = errors.New("failed to get tags")
+
+func (s *service) GetTags() ([]TagInfo, error) {
+	usage, err := filestoreutils.GetAllTags(s.db)
+	if err != nil {
+		log("failed to query tags: %v", err)
+		return nil, errGetTags
+	}
+	tags := make([]TagInfo, 0, len(usage))
+	for _, tag := range usage {
+		tags = append(tags, TagInfo{Name: tag.Name, FileCount: tag.FileCount, FolderCount: tag.FolderCount})
+	}
+	return tags, nil
+}
+
+var errGetAnnotations = errors.New("failed to get tags and metadata")
+
+func (s *service) GetFileAnnotations(fileID int64) (*Annotations, error) {
+	return s.getAnnotations(filestoreutils.AnnotatedFiles, fileID)
+}
+
+func (s *service) GetFolderAnnotations(folderID int64) (*Annotations, error) {
+	return s.getAnnotations(filestoreutils.AnnotatedFolders, folderID)
+}
+
+func (s *service) getAnnotations(annotated filestoreutils.Annotated, id int64) (*Annotations, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return nil, errGetAnnotations
+	}
+	defer tx.Rollback()
+
+	exists, err := annotated.Exists(tx, id)
+	if err != nil || !exists {
+		log("nothing to annotate with ID %d: %v", id, err)
+		return nil, errGetAnnotations
+	}
+	tags, err := annotated.GetTags(tx, id)
+	if err != nil {
+		log("failed to query tags of %d: %v", id, err)
+		return nil, errGetAnnotations
+	}
+	metadata, err := annotated.GetCustomMetadata(tx, id)
+	if err != nil {
+		log("failed to query custom metadata of %d: %v", id, err)
+		return nil, errGetAnnotations
+	}
+	return &Annotations{Tags: tags, CustomMetadata: metadata}, nil
+}
+
+var errTag = errors.New("failed to update tags")
+
+// TagFiles adds tags to files, creating the tags that don't exist yet
+func (s *service) TagFiles(fileIDs []int64, tags []string) error {
+	return s.updateTags(filestoreutils.AnnotatedFiles, fileIDs, tags, true)
+}
+
+// UntagFiles removes tags from files
+func (s *service) UntagFiles(fileIDs []int64, tags []string) error {
+	return s.updateTags(filestoreutils.AnnotatedFiles, fileIDs, tags, false)
+}
+
+// TagFolders adds tags to folders, creating the tags that don't exist yet
+func (s *service) TagFolders(folderIDs []int64, tags []string) error {
+	return s.updateTags(filestoreutils.AnnotatedFolders, folderIDs, tags, true)
+}
+
+// UntagFolders removes tags from folders
+func (s *service) UntagFolders(folderIDs []int64, tags []string) error {
+	return s.updateTags(filestoreutils.AnnotatedFolders, folderIDs, tags, false)
+}
+
+func (s *service) updateTags(annotated filestoreutils.Annotated, ids []int64, tags []string, add bool) error {
+	if len(ids) == 0 || len(tags) == 0 {
+		log("no IDs or tags provided")
+		return errTag
+	}
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := filestoreutils.NormalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		normalized = append(normalized, tag)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errTag
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		exists, err := annotated.Exists(tx, id)
+		if err != nil || !exists {
+			log("nothing to tag with ID %d: %v", id, err)
+			return errTag
+		}
+		for _, tag := range normalized {
+			if add {
+				err = annotated.AddTag(tx, id, tag)
+			} else {
+				err = annotated.RemoveTag(tx, id, tag)
+			}
+			if err != nil {
+				log("failed to update tag of %d: %v", id, err)
+				return errTag
+			}
+		}
+	}
+	if !add {
+		if err := filestoreutils.DeleteUnusedTags(tx); err != nil {
+			log("failed to delete unused tags: %v", err)
+			return errTag
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return errTag
+	}
+	return nil
+}
+
+var errSetMetadata = errors.New("failed to update metadata")
+
+// SetFileCustomMetadata sets a custom metadata value of a file, removing the key if value is empty
+func (s *service) SetFileCustomMetadata(fileID int64, key, value string) error {
+	return s.setCustomMetadata(filestoreutils.AnnotatedFiles, fileID, key, value)
+}
+
+// SetFolderCustomMetadata sets a custom metadata value of a folder, removing the key if value is empty
+func (s *service) SetFolderCustomMetadata(folderID int64, key, value string) error {
+	return s.setCustomMetadata(filestoreutils.AnnotatedFolders, folderID, key, value)
+}
+
+func (s *service) setCustomMetadata(annotated filestoreutils.Annotated, id int64, key, value string) error {
+	key, err := filestoreutils.NormalizeMetadataKey(key)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errSetMetadata
+	}
+	defer tx.Rollback()
+
+	exists, err := annotated.Exists(tx, id)
+	if err != nil || !exists {
+		log("nothing to annotate with ID %d: %v", id, err)
+		return errSetMetadata
+	}
+	if err := annotated.SetCustomMetadata(tx, id, key, value); err != nil {
+		log("failed to set custom metadata of %d: %v", id, err)
+		if errors.Is(err, filestoreutils.ErrInvalidMetadata) {
+			return err
+		}
+		return errSetMetadata
+	}
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return errSetMetadata
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/filestoreutils"
+	util "Tella-Desktop/backend/utils/genericutil"
+	"Tella-Desktop/backend/utils/imageutils"
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+)
+
+// storedThumbnail is a thumbnail written to the TVault that is not yet referenced by a committed files row
+type storedThumbnail struct {
+	region   *writtenRegion
+	blurhash string
+}
+
+// writeThumbnail generates the thumbnail and blurhash of the image just written to source, and stores the thumbnail
+// encrypted in the TVault. Thumbnails are a convenience: if the image can't be decoded, nil is returned and the file
+// is stored without one. The caller must hold the vault write lock.
+func (s *service) writeThumbnail(tx *sql.Tx, fileUUID, mimeType string, source *writtenRegion) *storedThumbnail {
+	if !imageutils.SupportsMimeType(mimeType) {
+		return nil
+	}
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		log("failed to open TVault: %v", err)
+		return nil
+	}
+	defer tvault.Close()
+
+	reader, err := filestoreutils.OpenFileReader(&filestoreutils.FileMetadata{
+		UUID:   fileUUID,
+		Offset: source.offset,
+		Length: source.length,
+		Format: filestoreutils.FormatChunked,
+	}, s.dbKey, tvault)
+	if err != nil {
+		log("failed to decrypt image %s for thumbnail: %v", fileUUID, err)
+		return nil
+	}
+	defer reader.Close()
+
+	thumbnail, err := imageutils.MakeThumbnail(reader)
+	if err != nil {
+		log("no thumbnail for image %s: %v", fileUUID, err)
+		return nil
+	}
+	defer util.SecureZeroMemory(thumbnail.JPEG)
+
+	region, err := s.writeEncryptedFile(tx, filestoreutils.ThumbnailID(fileUUID), int64(len(thumbnail.JPEG)), bytes.NewReader(thumbnail.JPEG))
+	if err != nil {
+		log("failed to store thumbnail of image %s: %v", fileUUID, err)
+		return nil
+	}
+	return &storedThumbnail{region: region, blurhash: thumbnail.Blurhash}
+}
+
+// discardThumbnail gives back the TVault space of a thumbnail written for an aborted store
+func (s *service) discardThumbnail(thumbnail *storedThumbnail) {
+	if thumbnail != nil {
+		s.discardRegion(thumbnail.region)
+	}
+}
+
+// recordThumbnail links a stored thumbnail to its file's row
+func recordThumbnail(tx *sql.Tx, fileID int64, thumbnail *storedThumbnail) error {
+	if thumbnail == nil {
+		return nil
+	}
+	return filestoreutils.SetThumbnail(tx, fileID, thumbnail.blurhash, filestoreutils.VaultRegion{
+		Offset: thumbnail.region.offset,
+		Length: thumbnail.region.length,
+	})
+}
+
+var errGetThumbnail = errors.New("failed to get thumbnail")
+
+// GetThumbnail returns the thumbnail of an image as a data URL. Only the thumbnail is decrypted, never the image itself.
+func (s *service) GetThumbnail(fileID int64) (string, error) {
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
+
+	metadata, err := filestoreutils.GetThumbnailMetadata(s.db, fileID)
+	if err != nil {
+		if errors.Is(err, filestoreutils.ErrNoThumbnail) {
+			return "", err
+		}
+		return "", errGetThumbnail
+	}
+
+	tvault, err := os.Open(s.tvaultPath)
+	if err != nil {
+		log("failed to open TVault: %v", err)
+		return "", errGetThumbnail
+	}
+	defer tvault.Close()
+
+	reader, err := filestoreutils.OpenFileReader(metadata, s.dbKey, tvault)
+	if err != nil {
+		log("failed to decrypt thumbnail of file %d: %v", fileID, err)
+		return "", errGetThumbnail
+	}
+	defer reader.Close()
+
+	jpeg := make([]byte, reader.Size())
+	defer util.SecureZeroMemory(jpeg)
+	if _, err := io.ReadFull(reader, jpeg); err != nil {
+		log("failed to decrypt thumbnail of file %d: %v", fileID, err)
+		return "", errGetThumbnail
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(jpeg), nil
+}
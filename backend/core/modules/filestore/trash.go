@@ -0,0 +1,300 @@
+package filestore
+
+import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/config"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// trashPurgeInterval is how often files that outlived the trash retention are purged while the vault is unlocked
+const trashPurgeInterval = time.Hour
+
+var errTrashFiles = errors.New("failed to move files to the trash")
+
+// TrashFiles moves files to the trash. Their ciphertext stays in the TVault until they are restored or purged.
+func (s *service) TrashFiles(ids []int64) error {
+	if len(ids) == 0 {
+		log("no file IDs provided for the trash")
+		return errTrashFiles
+	}
+
+	s.vaultMu.Lock()
+	defer s.vaultMu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errTrashFiles
+	}
+	defer tx.Rollback()
+
+	for _, fileID := range ids {
+		if err := filestoreutils.TrashFile(tx, fileID); err != nil {
+			log("failed to move file %d to the trash: %v", fileID, err)
+			return errTrashFiles
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return errTrashFiles
+	}
+
+	log("Moved %d files to the trash", len(ids))
+	s.audit(auditutils.ActionFilesTrashed, map[string]any{"fileIds": ids})
+	return nil
+}
+
+var errRestoreFiles = errors.New("failed to restore files")
+
+// RestoreFiles brings files back from the trash, into folderID or, when folderID is 0, into the folders they were
+// deleted from. Folders deleted since are recreated, with the same names.
+func (s *service) RestoreFiles(ids []int64, folderID int64) error {
+	if len(ids) == 0 {
+		log("no file IDs provided for restoring")
+		return errRestoreFiles
+	}
+
+	s.vaultMu.Lock()
+	defer s.vaultMu.Unlock()
+
+	// the destination folders are looked up before the transaction, as queries on s.db would wait for it to end
+	destinations := make(map[int64]int64, len(ids))
+	for _, fileID := range ids {
+		destination := folderID
+		if destination == 0 {
+			if err := s.db.QueryRow("SELECT folder_id FROM files WHERE id = ?", fileID).Scan(&destination); err != nil {
+				log("failed to look up folder of file %d: %v", fileID, err)
+				return errRestoreFiles
+			}
+		}
+		exists, err := s.folderExists(destination)
+		if err != nil {
+			log("failed to look up folder %d: %v", destination, err)
+			return errRestoreFiles
+		}
+		if !exists && folderID == 0 {
+			destination, err = s.recreateTrashedFolder(fileID)
+			if err != nil {
+				log("failed to recreate folder of file %d: %v", fileID, err)
+				return errRestoreFiles
+			}
+			exists = destination != 0
+		}
+		if !exists {
+			log("folder not found with ID %d to restore file %d into", destination, fileID)
+			return errFolderNotFound
+		}
+		destinations[fileID] = destination
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errRestoreFiles
+	}
+	defer tx.Rollback()
+
+	for _, fileID := range ids {
+		if err := filestoreutils.RestoreFile(tx, fileID, destinations[fileID]); err != nil {
+			log("failed to restore file %d: %v", fileID, err)
+			return errRestoreFiles
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return errRestoreFiles
+	}
+
+	log("Restored %d files from the trash", len(ids))
+	s.audit(auditutils.ActionFilesRestored, map[string]any{"fileIds": ids, "folderIdsByFile": destinations})
+	return nil
+}
+
+// recreateTrashedFolder returns the folder a trashed file was in, found by the names of it and its parents or created
+// again where it is gone. It returns 0 when the path of the folder was not recorded.
+func (s *service) recreateTrashedFolder(fileID int64) (int64, error) {
+	names, err := filestoreutils.GetTrashedFolderPath(s.db, fileID)
+	if err != nil {
+		return 0, err
+	}
+	var parentID int64
+	for _, name := range names {
+		var folderID int64
+		err := s.db.QueryRow("SELECT id FROM folders WHERE name = ? AND parent_id IS ? ORDER BY id LIMIT 1", name, nullableParent(parentID)).Scan(&folderID)
+		if errors.Is(err, sql.ErrNoRows) {
+			folderID, err = s.CreateFolder(name, parentID)
+		}
+		if err != nil {
+			return 0, err
+		}
+		parentID = folderID
+	}
+	return parentID, nil
+}
+
+var errGetTrash = errors.New("failed to get the trash")
+
+// GetTrash lists the files in the trash, most recently trashed first
+func (s *service) GetTrash() ([]TrashedFileInfo, error) {
+	trashed, err := filestoreutils.GetTrashedFiles(s.db)
+	if err != nil {
+		log("failed to query the trash: %v", err)
+		return nil, errGetTrash
+	}
+
+	retention := time.Duration(config.ReadConfig().GetTrashRetentionDays()) * 24 * time.Hour
+	files := make([]TrashedFileInfo, 0, len(trashed))
+	for _, file := range trashed {
+		info := TrashedFileInfo{
+			File: FileInfo{
+				ID:        file.ID,
+				Name:      file.Name,
+				MimeType:  file.MimeType,
+				Timestamp: file.CreatedAt,
+				Size:      file.Size,
+				SHA256:    file.SHA256,
+				FolderID:  file.FolderID,
+				Blurhash:  file.Blurhash,
+			},
+			TrashedAt: file.TrashedAt,
+		}
+		for _, timeFmt := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+			if trashedAt, err := time.Parse(timeFmt, file.TrashedAt); err == nil {
+				info.PurgeAt = trashedAt.Add(retention).Format(time.RFC3339)
+				break
+			}
+		}
+		files = append(files, info)
+	}
+	return files, nil
+}
+
+var errPurgeTrash = errors.New("failed to purge files from the trash")
+
+// PurgeTrash securely deletes files from the trash ahead of the retention period
+func (s *service) PurgeTrash(ids []int64) error {
+	if len(ids) == 0 {
+		log("no file IDs provided for purging")
+		return errPurgeTrash
+	}
+
+	// only files in the trash can be purged, so that live files aren't deleted by mistake
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errPurgeTrash
+	}
+	for _, fileID := range ids {
+		trashed, err := filestoreutils.InTrash(tx, fileID)
+		if err != nil || !trashed {
+			tx.Rollback()
+			log("file %d is not in the trash: %v", fileID, err)
+			return errPurgeTrash
+		}
+	}
+	tx.Rollback()
+
+	if err := s.DeleteFiles(ids); err != nil {
+		return errPurgeTrash
+	}
+	return nil
+}
+
+// EmptyTrash securely deletes every file in the trash
+func (s *service) EmptyTrash() error {
+	trashed, err := filestoreutils.GetTrashedFiles(s.db)
+	if err != nil {
+		log("failed to query the trash: %v", err)
+		return errPurgeTrash
+	}
+	if len(trashed) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(trashed))
+	for _, file := range trashed {
+		ids = append(ids, file.ID)
+	}
+	if err := s.DeleteFiles(ids); err != nil {
+		return errPurgeTrash
+	}
+	log("Emptied the trash of %d files", len(ids))
+	return nil
+}
+
+// PurgeExpiredTrash securely deletes the files that have been in the trash longer than the configured retention,
+// returning how many were purged
+func (s *service) PurgeExpiredTrash() (int, error) {
+	retention := time.Duration(config.ReadConfig().GetTrashRetentionDays()) * 24 * time.Hour
+	ids, err := filestoreutils.GetTrashedBefore(s.db, time.Now().Add(-retention))
+	if err != nil {
+		log("failed to query the trash: %v", err)
+		return 0, errPurgeTrash
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := s.DeleteFiles(ids); err != nil {
+		return 0, errPurgeTrash
+	}
+	log("Purged %d files past the trash retention", len(ids))
+	return len(ids), nil
+}
+
+// StartTrashPurge purges expired files from the trash now and then every trashPurgeInterval, until Lock is called
+func (s *service) StartTrashPurge() {
+	s.goBackground(func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			// checked first so that no purge starts once the vault is being locked
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			if _, err := s.PurgeExpiredTrash(); err != nil {
+				log("failed to purge expired files from the trash: %v", err)
+			}
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// goBackground runs work in the background until Lock is called, returning false without running it if the vault is
+// being locked. Checking done and adding to background happen under lockMu, so that Lock can't close done and start
+// waiting in between and close the database under work that started late.
+func (s *service) goBackground(work func()) bool {
+	s.lockMu.Lock()
+	defer s.lockMu.Unlock()
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		work()
+	}()
+	return true
+}
+
+// Lock stops the background work of the service, waiting for a purge in progress to finish and for export jobs to be
+// cancelled, so that the database can be closed
+func (s *service) Lock() {
+	s.lockMu.Lock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	s.lockMu.Unlock()
+	s.background.Wait()
+}
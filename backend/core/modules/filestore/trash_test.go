@@ -0,0 +1,121 @@
+package filestore
+
+import (
+	"testing"
+
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+// trashedIDs returns the IDs of the files in the trash
+func trashedIDs(t *testing.T, s *service) map[int64]bool {
+	t.Helper()
+	trashed, err := filestoreutils.GetTrashedFiles(s.db)
+	if err != nil {
+		t.Fatalf("GetTrashedFiles() failed: %v", err)
+	}
+	ids := map[int64]bool{}
+	for _, file := range trashed {
+		ids[file.ID] = true
+	}
+	return ids
+}
+
+// folderOf returns the ID of the folder of a live file
+func folderOf(t *testing.T, s *service, fileID int64) int64 {
+	t.Helper()
+	metadata, err := filestoreutils.GetFileMetadataByID(s.db, fileID)
+	if err != nil {
+		t.Fatalf("File %d is not live: %v", fileID, err)
+	}
+	return metadata.FolderID
+}
+
+func TestRestoreFilesFromTrash(t *testing.T) {
+	s := setupServiceTest(t)
+	other := createTestFolder(t, s, "Other", 0)
+	first, firstData := storeTestFile(t, s, 1, "first.bin", 1000)
+	second, secondData := storeTestFile(t, s, 1, "second.bin", 1000)
+
+	if err := s.TrashFiles([]int64{first, second}); err != nil {
+		t.Fatalf("TrashFiles() failed: %v", err)
+	}
+	if trashed := trashedIDs(t, s); !trashed[first] || !trashed[second] {
+		t.Fatalf("Trash holds %v, want files %d and %d", trashed, first, second)
+	}
+	if _, err := s.OpenFileStream(first); err == nil {
+		t.Errorf("Trashed file %d can still be opened", first)
+	}
+	if err := s.TrashFiles([]int64{first}); err == nil {
+		t.Errorf("TrashFiles() trashed file %d twice", first)
+	}
+	if err := s.RestoreFiles([]int64{first}, 999); err != errFolderNotFound {
+		t.Errorf("RestoreFiles() into a missing folder = %v, want %v", err, errFolderNotFound)
+	}
+
+	if err := s.RestoreFiles([]int64{first}, 0); err != nil {
+		t.Fatalf("RestoreFiles() into the original folder failed: %v", err)
+	}
+	if err := s.RestoreFiles([]int64{second}, other); err != nil {
+		t.Fatalf("RestoreFiles() into another folder failed: %v", err)
+	}
+	if folderOf(t, s, first) != 1 || folderOf(t, s, second) != other {
+		t.Errorf("Files restored into folders %d and %d, want 1 and %d", folderOf(t, s, first), folderOf(t, s, second), other)
+	}
+	checkTestFile(t, s, first, firstData)
+	checkTestFile(t, s, second, secondData)
+	if trashed := trashedIDs(t, s); len(trashed) != 0 {
+		t.Errorf("Trash holds %v after restoring everything", trashed)
+	}
+	if err := s.RestoreFiles([]int64{first}, 0); err == nil {
+		t.Errorf("RestoreFiles() restored file %d, which is not in the trash", first)
+	}
+}
+
+func TestRestoreFilesRecreatesDeletedFolders(t *testing.T) {
+	s := setupServiceTest(t)
+	cases := createTestFolder(t, s, "Cases", 0)
+	open := createTestFolder(t, s, "Open", cases)
+	statement, statementData := storeTestFile(t, s, open, "statement.bin", 1000)
+	notes, notesData := storeTestFile(t, s, cases, "notes.bin", 1000)
+
+	if err := s.TrashFolders([]int64{cases}); err != nil {
+		t.Fatalf("TrashFolders() failed: %v", err)
+	}
+	if trashed := trashedIDs(t, s); !trashed[statement] || !trashed[notes] {
+		t.Fatalf("Trash holds %v, want the files of the deleted folders", trashed)
+	}
+
+	if err := s.RestoreFiles([]int64{statement, notes}, 0); err != nil {
+		t.Fatalf("RestoreFiles() failed: %v", err)
+	}
+	tree, err := s.GetFolderTree()
+	if err != nil {
+		t.Fatalf("GetFolderTree() failed: %v", err)
+	}
+	// Cases > Open is recreated once, with both files back in place
+	if len(tree) != 2 || tree[0].Name != "Cases" || tree[0].FileCount != 1 || len(tree[0].Children) != 1 ||
+		tree[0].Children[0].Name != "Open" || tree[0].Children[0].FileCount != 1 {
+		t.Fatalf("GetFolderTree() = %+v, want Cases > Open recreated", tree)
+	}
+	if folderOf(t, s, statement) != tree[0].Children[0].ID || folderOf(t, s, notes) != tree[0].ID {
+		t.Errorf("Files restored into folders %d and %d, want the recreated ones", folderOf(t, s, statement), folderOf(t, s, notes))
+	}
+	checkTestFile(t, s, statement, statementData)
+	checkTestFile(t, s, notes, notesData)
+}
+
+func TestDeleteFoldersSkipsTrash(t *testing.T) {
+	s := setupServiceTest(t)
+	cases := createTestFolder(t, s, "Cases", 0)
+	statement, _ := storeTestFile(t, s, cases, "statement.bin", 1000)
+
+	if err := s.DeleteFolders([]int64{cases}); err != nil {
+		t.Fatalf("DeleteFolders() failed: %v", err)
+	}
+	if trashed := trashedIDs(t, s); len(trashed) != 0 {
+		t.Errorf("Trash holds %v, want the files deleted for good", trashed)
+	}
+	if err := s.RestoreFiles([]int64{statement}, 1); err == nil {
+		t.Errorf("RestoreFiles() restored permanently deleted file %d", statement)
+	}
+}
@@ -0,0 +1,16 @@
+package reports
+
+import "Tella-Desktop/backend/core/modules/filestore"
+
+type ReportInfo struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Timestamp string `json:"timestamp"`
+	UpdatedAt string `json:"updatedAt"`
+	FileCount int    `json:"fileCount"`
+}
+
+type ReportFilesResponse struct {
+	ReportName string               `json:"reportName"`
+	Files      []filestore.FileInfo `json:"files"`
+}
@@ -0,0 +1,27 @@
+package reports
+
+type Service interface {
+	// CreateReport creates an empty report, returning its ID
+	CreateReport(name string) (int64, error)
+
+	// RenameReport changes the name of a report
+	RenameReport(reportID int64, name string) error
+
+	// DeleteReport deletes a report; the files in it are kept
+	DeleteReport(reportID int64) error
+
+	// AddFilesToReport adds files to a report, skipping files that are already part of it
+	AddFilesToReport(reportID int64, fileIDs []int64) error
+
+	// RemoveFilesFromReport removes files from a report without deleting them
+	RemoveFilesFromReport(reportID int64, fileIDs []int64) error
+
+	// GetReports returns all reports with their file counts
+	GetReports() ([]ReportInfo, error)
+
+	// GetReportFiles returns the files in a report
+	GetReportFiles(reportID int64) (*ReportFilesResponse, error)
+
+	// ExportReportZip exports the files in a report as a ZIP archive named after the report
+	ExportReportZip(reportID int64) (string, error)
+}
@@ -0,0 +1,294 @@
+package reports
+
+import (
+	"Tella-Desktop/backend/core/modules/filestore"
+	"Tella-Desktop/backend/utils/devlog"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var log = devlog.Logger("reports")
+
+// Reports group files from any folder into a named package. A file can be part of several reports; report_files only
+// references files, so deleting a report never touches the files themselves.
+type service struct {
+	ctx         context.Context
+	db          *sql.DB
+	fileService filestore.Service
+}
+
+func NewService(ctx context.Context, db *sql.DB, fileService filestore.Service) Service {
+	return &service{
+		ctx:         ctx,
+		db:          db,
+		fileService: fileService,
+	}
+}
+
+var (
+	errReportName     = errors.New("report name must not be empty")
+	errReportNotFound = errors.New("report not found")
+)
+
+var errCreateReport = errors.New("failed to create report")
+func (s *service) CreateReport(name string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, errReportName
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO reports (name, created_at, updated_at)
+		VALUES (?, datetime('now'), datetime('now'))
+	`, name)
+	if err != nil {
+		log("failed to create report: %v", err)
+		return 0, errCreateReport
+	}
+
+	reportID, err := result.LastInsertId()
+	if err != nil {
+		log("failed to get report ID: %v", err)
+		return 0, errCreateReport
+	}
+
+	log("Created report '%s' with ID: %d", name, reportID)
+	return reportID, nil
+}
+
+var errRenameReport = errors.New("failed to rename report")
+func (s *service) RenameReport(reportID int64, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errReportName
+	}
+
+	result, err := s.db.Exec("UPDATE reports SET name = ? WHERE id = ?", name, reportID)
+	if err != nil {
+		log("failed to rename report %d: %v", reportID, err)
+		return errRenameReport
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("report not found with ID: %d", reportID)
+		return errReportNotFound
+	}
+	return nil
+}
+
+var errDeleteReport = errors.New("failed to delete report")
+func (s *service) DeleteReport(reportID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errDeleteReport
+	}
+	defer tx.Rollback()
+
+	// only the links to the files are removed, the files stay in the vault
+	if _, err := tx.Exec("DELETE FROM report_files WHERE report_id = ?", reportID); err != nil {
+		log("failed to remove files from report %d: %v", reportID, err)
+		return errDeleteReport
+	}
+	result, err := tx.Exec("DELETE FROM reports WHERE id = ?", reportID)
+	if err != nil {
+		log("failed to delete report %d: %v", reportID, err)
+		return errDeleteReport
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("report not found with ID: %d", reportID)
+		return errReportNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		log("failed to commit report deletion: %v", err)
+		return errDeleteReport
+	}
+	return nil
+}
+
+var errAddReportFiles = errors.New("failed to add files to report")
+func (s *service) AddFilesToReport(reportID int64, fileIDs []int64) error {
+	if len(fileIDs) == 0 {
+		log("no file IDs provided")
+		return errAddReportFiles
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errAddReportFiles
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE reports SET updated_at = datetime('now') WHERE id = ?", reportID)
+	if err != nil {
+		log("failed to update report %d: %v", reportID, err)
+		return errAddReportFiles
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("report not found with ID: %d", reportID)
+		return errReportNotFound
+	}
+
+	for _, fileID := range fileIDs {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM files WHERE id = ? AND is_deleted = 0)", fileID).Scan(&exists); err != nil || !exists {
+			log("file not found with ID: %d", fileID)
+			return errAddReportFiles
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO report_files (report_id, file_id) VALUES (?, ?)", reportID, fileID); err != nil {
+			log("failed to add file %d to report %d: %v", fileID, reportID, err)
+			return errAddReportFiles
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return errAddReportFiles
+	}
+	return nil
+}
+
+var errRemoveReportFiles = errors.New("failed to remove files from report")
+func (s *service) RemoveFilesFromReport(reportID int64, fileIDs []int64) error {
+	if len(fileIDs) == 0 {
+		log("no file IDs provided")
+		return errRemoveReportFiles
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log("failed to begin transaction: %v", err)
+		return errRemoveReportFiles
+	}
+	defer tx.Rollback()
+
+	for _, fileID := range fileIDs {
+		if _, err := tx.Exec("DELETE FROM report_files WHERE report_id = ? AND file_id = ?", reportID, fileID); err != nil {
+			log("failed to remove file %d from report %d: %v", fileID, reportID, err)
+			return errRemoveReportFiles
+		}
+	}
+	if _, err := tx.Exec("UPDATE reports SET updated_at = datetime('now') WHERE id = ?", reportID); err != nil {
+		log("failed to update report %d: %v", reportID, err)
+		return errRemoveReportFiles
+	}
+
+	if err := tx.Commit(); err != nil {
+		log("failed to commit transaction: %v", err)
+		return errRemoveReportFiles
+	}
+	return nil
+}
+
+var errGetReports = errors.New("failed to get reports")
+func (s *service) GetReports() ([]ReportInfo, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			r.id,
+			r.name,
+			r.created_at,
+			r.updated_at,
+			COUNT(files.id) as file_count
+		FROM reports r
+		LEFT JOIN report_files rf ON rf.report_id = r.id
+		LEFT JOIN files ON files.id = rf.file_id AND files.is_deleted = 0
+		GROUP BY r.id, r.name, r.created_at, r.updated_at
+		ORDER BY r.updated_at DESC
+	`)
+	if err != nil {
+		log("failed to query reports: %v", err)
+		return nil, errGetReports
+	}
+	defer rows.Close()
+
+	reports := []ReportInfo{}
+	for rows.Next() {
+		var report ReportInfo
+		if err := rows.Scan(&report.ID, &report.Name, &report.Timestamp, &report.UpdatedAt, &report.FileCount); err != nil {
+			log("failed to scan report: %v", err)
+			return nil, errGetReports
+		}
+		reports = append(reports, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		log("error iterating reports: %v", err)
+		return nil, errGetReports
+	}
+	return reports, nil
+}
+
+var errGetReportFiles = errors.New("failed to get files in report")
+func (s *service) GetReportFiles(reportID int64) (*ReportFilesResponse, error) {
+	var reportName string
+	err := s.db.QueryRow("SELECT name FROM reports WHERE id = ?", reportID).Scan(&reportName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log("report not found with ID: %d", reportID)
+			return nil, errReportNotFound
+		}
+		log("failed to get report name: %v", err)
+		return nil, errGetReportFiles
+	}
+
+	rows, err := s.db.Query(`
+		SELECT files.id, files.name, files.mime_type, files.created_at, files.size, COALESCE(files.sha256, ''), files.folder_id,
+			COALESCE(files.blurhash, '')
+		FROM report_files rf
+		JOIN files ON files.id = rf.file_id AND files.is_deleted = 0
+		WHERE rf.report_id = ?
+		ORDER BY files.created_at DESC
+	`, reportID)
+	if err != nil {
+		log("failed to query files in report: %v", err)
+		return nil, errGetReportFiles
+	}
+	defer rows.Close()
+
+	files := []filestore.FileInfo{}
+	for rows.Next() {
+		var file filestore.FileInfo
+		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size, &file.SHA256, &file.FolderID, &file.Blurhash); err != nil {
+			log("failed to scan file: %v", err)
+			return nil, errGetReportFiles
+		}
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		log("error iterating files: %v", err)
+		return nil, errGetReportFiles
+	}
+
+	return &ReportFilesResponse{
+		ReportName: reportName,
+		Files:      files,
+	}, nil
+}
+
+var errExportReport = errors.New("failed to export report")
+func (s *service) ExportReportZip(reportID int64) (string, error) {
+	report, err := s.GetReportFiles(reportID)
+	if err != nil {
+		return "", err
+	}
+	if len(report.Files) == 0 {
+		log("report %d has no files to export", reportID)
+		return "", errExportReport
+	}
+
+	fileIDs := make([]int64, 0, len(report.Files))
+	for _, file := range report.Files {
+		fileIDs = append(fileIDs, file.ID)
+	}
+
+	zipPath, err := s.fileService.ExportZipFiles(report.ReportName, fileIDs)
+	if err != nil {
+		log("failed to export report %d: %v", reportID, err)
+		return "", errExportReport
+	}
+	return zipPath, nil
+}
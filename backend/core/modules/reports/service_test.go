@@ -0,0 +1,208 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/utils/constants"
+	"Tella-Desktop/backend/utils/filestoreutils"
+)
+
+// setupReportsTest returns a service on a new database holding a folder with ID 1 and the given number of files
+func setupReportsTest(t *testing.T, files int) (*service, []int64) {
+	t.Helper()
+	db, err := database.Initialize(filepath.Join(t.TempDir(), "tella.db"), make([]byte, constants.KeyLength))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("INSERT INTO folders (name) VALUES ('Received Files')"); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	s := &service{ctx: context.Background(), db: db.DB}
+	var ids []int64
+	err = withTx(t, s.db, func(tx *sql.Tx) error {
+		for i := 0; i < files; i++ {
+			name := fmt.Sprintf("file-%d.bin", i)
+			// reports only reference files, so their contents are never read
+			id, err := filestoreutils.InsertFileMetadata(tx, name, name, 100, "application/octet-stream", 1, 0, 0, filestoreutils.FormatChunked, "")
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert files: %v", err)
+	}
+	return s, ids
+}
+
+func withTx(t *testing.T, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// fileCounts returns the file count of every report by ID
+func fileCounts(t *testing.T, s *service) map[int64]int {
+	t.Helper()
+	reports, err := s.GetReports()
+	if err != nil {
+		t.Fatalf("GetReports() failed: %v", err)
+	}
+	counts := map[int64]int{}
+	for _, report := range reports {
+		counts[report.ID] = report.FileCount
+	}
+	return counts
+}
+
+func TestReportLifecycleKeepsFiles(t *testing.T) {
+	s, files := setupReportsTest(t, 2)
+
+	if _, err := s.CreateReport("   "); err != errReportName {
+		t.Errorf("CreateReport() with a blank name = %v, want %v", err, errReportName)
+	}
+	reportID, err := s.CreateReport("  Flood damage  ")
+	if err != nil {
+		t.Fatalf("CreateReport() failed: %v", err)
+	}
+	if err := s.RenameReport(reportID, "Flood damage, March"); err != nil {
+		t.Fatalf("RenameReport() failed: %v", err)
+	}
+	if err := s.RenameReport(reportID, ""); err != errReportName {
+		t.Errorf("RenameReport() to a blank name = %v, want %v", err, errReportName)
+	}
+	if err := s.RenameReport(999, "Missing"); err != errReportNotFound {
+		t.Errorf("RenameReport() of a missing report = %v, want %v", err, errReportNotFound)
+	}
+	if err := s.AddFilesToReport(reportID, files); err != nil {
+		t.Fatalf("AddFilesToReport() failed: %v", err)
+	}
+	report, err := s.GetReportFiles(reportID)
+	if err != nil {
+		t.Fatalf("GetReportFiles() failed: %v", err)
+	}
+	if report.ReportName != "Flood damage, March" || len(report.Files) != 2 {
+		t.Errorf("GetReportFiles() = %+v, want the renamed report with both files", report)
+	}
+
+	if err := s.DeleteReport(reportID); err != nil {
+		t.Fatalf("DeleteReport() failed: %v", err)
+	}
+	if err := s.DeleteReport(reportID); err != errReportNotFound {
+		t.Errorf("DeleteReport() twice = %v, want %v", err, errReportNotFound)
+	}
+	if _, err := s.GetReportFiles(reportID); err != errReportNotFound {
+		t.Errorf("GetReportFiles() of a deleted report = %v, want %v", err, errReportNotFound)
+	}
+	var links, live int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM report_files").Scan(&links); err != nil || links != 0 {
+		t.Errorf("%d files are still linked to reports (%v), want none", links, err)
+	}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM files WHERE is_deleted = 0").Scan(&live); err != nil || live != 2 {
+		t.Errorf("%d files are left in the vault (%v), want both kept", live, err)
+	}
+}
+
+func TestReportsSkipTrashedFiles(t *testing.T) {
+	s, files := setupReportsTest(t, 3)
+	reportID, err := s.CreateReport("Interviews")
+	if err != nil {
+		t.Fatalf("CreateReport() failed: %v", err)
+	}
+	if err := s.AddFilesToReport(reportID, files[:2]); err != nil {
+		t.Fatalf("AddFilesToReport() failed: %v", err)
+	}
+	err = withTx(t, s.db, func(tx *sql.Tx) error {
+		if err := filestoreutils.TrashFile(tx, files[0]); err != nil {
+			return err
+		}
+		return filestoreutils.TrashFile(tx, files[2])
+	})
+	if err != nil {
+		t.Fatalf("Failed to trash files: %v", err)
+	}
+
+	// a trashed file can't be added, and one added before it was trashed is left out but stays linked
+	if err := s.AddFilesToReport(reportID, files[2:]); err != errAddReportFiles {
+		t.Errorf("AddFilesToReport() of a trashed file = %v, want %v", err, errAddReportFiles)
+	}
+	report, err := s.GetReportFiles(reportID)
+	if err != nil {
+		t.Fatalf("GetReportFiles() failed: %v", err)
+	}
+	if len(report.Files) != 1 || report.Files[0].ID != files[1] {
+		t.Errorf("GetReportFiles() = %+v, want only file %d", report.Files, files[1])
+	}
+	if counts := fileCounts(t, s); counts[reportID] != 1 {
+		t.Errorf("Report counts %d files, want 1", counts[reportID])
+	}
+
+	err = withTx(t, s.db, func(tx *sql.Tx) error { return filestoreutils.RestoreFile(tx, files[0], 1) })
+	if err != nil {
+		t.Fatalf("Failed to restore file: %v", err)
+	}
+	if counts := fileCounts(t, s); counts[reportID] != 2 {
+		t.Errorf("Report counts %d files after a restore, want 2", counts[reportID])
+	}
+
+	if err := s.RemoveFilesFromReport(reportID, []int64{files[0], files[2]}); err != nil {
+		t.Fatalf("RemoveFilesFromReport() failed: %v", err)
+	}
+	if counts := fileCounts(t, s); counts[reportID] != 1 {
+		t.Errorf("Report counts %d files after a removal, want 1", counts[reportID])
+	}
+}
+
+func TestReportFileCounts(t *testing.T) {
+	s, files := setupReportsTest(t, 3)
+	empty, err := s.CreateReport("Empty")
+	if err != nil {
+		t.Fatalf("CreateReport() failed: %v", err)
+	}
+	first, err := s.CreateReport("First")
+	if err != nil {
+		t.Fatalf("CreateReport() failed: %v", err)
+	}
+	second, err := s.CreateReport("Second")
+	if err != nil {
+		t.Fatalf("CreateReport() failed: %v", err)
+	}
+
+	// files can be part of several reports, and adding a file twice links it once
+	if err := s.AddFilesToReport(first, files); err != nil {
+		t.Fatalf("AddFilesToReport() failed: %v", err)
+	}
+	if err := s.AddFilesToReport(first, files[:1]); err != nil {
+		t.Fatalf("AddFilesToReport() again failed: %v", err)
+	}
+	if err := s.AddFilesToReport(second, files[1:2]); err != nil {
+		t.Fatalf("AddFilesToReport() failed: %v", err)
+	}
+	if err := s.AddFilesToReport(999, files); err != errReportNotFound {
+		t.Errorf("AddFilesToReport() of a missing report = %v, want %v", err, errReportNotFound)
+	}
+	if err := s.AddFilesToReport(second, []int64{999}); err != errAddReportFiles {
+		t.Errorf("AddFilesToReport() of a missing file = %v, want %v", err, errAddReportFiles)
+	}
+
+	counts := fileCounts(t, s)
+	if len(counts) != 3 || counts[empty] != 0 || counts[first] != 3 || counts[second] != 1 {
+		t.Errorf("Report file counts = %v, want 0, 3 and 1", counts)
+	}
+}
@@ -0,0 +1,13 @@
+package signing
+
+// ExportVerification is the result of checking an exported file against its signature
+type ExportVerification struct {
+	// Valid is set if the file is unchanged since it was signed
+	Valid       bool   `json:"valid"`
+	Fingerprint string `json:"fingerprint"`
+	// SignedByThisVault is set if the signing key is this vault's; otherwise the fingerprint has to be compared with
+	// the one published by the sender
+	SignedByThisVault bool   `json:"signedByThisVault"`
+	SignedAt          string `json:"signedAt"`
+	Problem           string `json:"problem"`
+}
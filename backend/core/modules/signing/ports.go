@@ -0,0 +1,16 @@
+package signing
+
+type Service interface {
+	// EnsureKey generates the vault's signing key unless it already has one
+	EnsureKey() error
+
+	// GetFingerprint returns the fingerprint of the vault's signing key, for recipients to check signatures against
+	GetFingerprint() (string, error)
+
+	// ExportPublicKey writes the public signing key with its fingerprint to the export directory, returning the path
+	// of the file
+	ExportPublicKey() (string, error)
+
+	// VerifyExport checks an exported file against the signature next to it
+	VerifyExport(path string) (*ExportVerification, error)
+}
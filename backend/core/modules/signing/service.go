@@ -0,0 +1,103 @@
+package signing
+
+import (
+	"Tella-Desktop/backend/utils/devlog"
+	"Tella-Desktop/backend/utils/filestoreutils"
+	util "Tella-Desktop/backend/utils/genericutil"
+	"Tella-Desktop/backend/utils/signutils"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+)
+
+var log = devlog.Logger("signing")
+
+// Exports are signed with an Ed25519 key that is generated with the vault and kept in its encrypted database. The
+// filestore signs exports through signutils; this service manages the key and checks signatures.
+type service struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func NewService(ctx context.Context, db *sql.DB) Service {
+	return &service{
+		ctx: ctx,
+		db:  db,
+	}
+}
+
+var errEnsureKey = errors.New("failed to create the signing key")
+
+func (s *service) EnsureKey() error {
+	if err := signutils.EnsureKey(s.db); err != nil {
+		log("failed to create signing key: %v", err)
+		return errEnsureKey
+	}
+	return nil
+}
+
+var errGetFingerprint = errors.New("failed to get the signing key fingerprint")
+
+func (s *service) GetFingerprint() (string, error) {
+	publicKey, err := signutils.LoadPublicKey(s.db)
+	if err != nil {
+		log("failed to load public key: %v", err)
+		return "", errGetFingerprint
+	}
+	return signutils.Fingerprint(publicKey), nil
+}
+
+var errExportPublicKey = errors.New("failed to export the signing key")
+
+func (s *service) ExportPublicKey() (string, error) {
+	publicKey, err := signutils.LoadPublicKey(s.db)
+	if err != nil {
+		log("failed to load public key: %v", err)
+		return "", errExportPublicKey
+	}
+	encoded, err := signutils.EncodePublicKey(publicKey)
+	if err != nil {
+		log("failed to encode public key: %v", err)
+		return "", errExportPublicKey
+	}
+
+	exportDir, err := filestoreutils.ExportDir("", 0)
+	if err != nil {
+		return "", err
+	}
+	exportPath := filestoreutils.CreateUniqueFilename(exportDir, "tella-desktop-signing-key.pem")
+	if err := os.WriteFile(exportPath, encoded, util.USER_ONLY_FILE_PERMS); err != nil {
+		log("failed to write public key: %v", err)
+		return "", errExportPublicKey
+	}
+
+	log("Exported signing key to: %s", exportPath)
+	return exportPath, nil
+}
+
+var errVerifyExport = errors.New("failed to verify the export")
+
+func (s *service) VerifyExport(path string) (*ExportVerification, error) {
+	result, err := signutils.VerifyFile(path)
+	if errors.Is(err, signutils.ErrNoSignature) {
+		return nil, err
+	}
+	if err != nil {
+		log("failed to verify %s: %v", path, err)
+		return nil, errVerifyExport
+	}
+
+	verification := &ExportVerification{
+		Valid:       result.Valid,
+		Fingerprint: result.Fingerprint,
+		SignedAt:    result.SignedAt,
+		Problem:     result.Problem,
+	}
+	if publicKey, err := signutils.LoadPublicKey(s.db); err == nil {
+		verification.SignedByThisVault = result.Fingerprint == signutils.Fingerprint(publicKey)
+	} else {
+		log("failed to load public key: %v", err)
+	}
+	return verification, nil
+}
@@ -0,0 +1,114 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/utils/constants"
+	"Tella-Desktop/backend/utils/signutils"
+)
+
+// setupSigningTest returns a service on a new database with a signing key
+func setupSigningTest(t *testing.T) *service {
+	t.Helper()
+	db, err := database.Initialize(filepath.Join(t.TempDir(), "tella.db"), make([]byte, constants.KeyLength))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := &service{ctx: context.Background(), db: db.DB}
+	if err := s.EnsureKey(); err != nil {
+		t.Fatalf("EnsureKey() failed: %v", err)
+	}
+	return s
+}
+
+// signTestExport writes a file and signs it with privateKey, returning its path
+func signTestExport(t *testing.T, privateKey ed25519.PrivateKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Cases.zip")
+	if err := os.WriteFile(path, []byte("exported content"), 0600); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+	if _, err := signutils.SignFile(privateKey, path); err != nil {
+		t.Fatalf("SignFile() failed: %v", err)
+	}
+	return path
+}
+
+func TestVerifyExportSignedByThisVault(t *testing.T) {
+	s := setupSigningTest(t)
+	fingerprint, err := s.GetFingerprint()
+	if err != nil {
+		t.Fatalf("GetFingerprint() failed: %v", err)
+	}
+	if err := s.EnsureKey(); err != nil {
+		t.Fatalf("second EnsureKey() failed: %v", err)
+	}
+	if again, err := s.GetFingerprint(); err != nil || again != fingerprint {
+		t.Fatalf("GetFingerprint() after a second EnsureKey() = %q, %v, want the key kept as %q", again, err, fingerprint)
+	}
+
+	privateKey, err := signutils.LoadKey(s.db)
+	if err != nil {
+		t.Fatalf("LoadKey() failed: %v", err)
+	}
+	path := signTestExport(t, privateKey)
+
+	verification, err := s.VerifyExport(path)
+	if err != nil {
+		t.Fatalf("VerifyExport() failed: %v", err)
+	}
+	if !verification.Valid || !verification.SignedByThisVault || verification.Fingerprint != fingerprint {
+		t.Errorf("VerifyExport() = %+v, want a valid signature by this vault's key %s", verification, fingerprint)
+	}
+}
+
+func TestVerifyExportFailsOnTampering(t *testing.T) {
+	s := setupSigningTest(t)
+	privateKey, err := signutils.LoadKey(s.db)
+	if err != nil {
+		t.Fatalf("LoadKey() failed: %v", err)
+	}
+	path := signTestExport(t, privateKey)
+	if err := os.WriteFile(path, []byte("exported c0ntent"), 0600); err != nil {
+		t.Fatalf("Failed to tamper with export: %v", err)
+	}
+
+	verification, err := s.VerifyExport(path)
+	if err != nil {
+		t.Fatalf("VerifyExport() failed: %v", err)
+	}
+	if verification.Valid || verification.Problem == "" {
+		t.Errorf("VerifyExport() of a tampered export = %+v, want it invalid with a problem", verification)
+	}
+
+	if _, err := s.VerifyExport(filepath.Join(t.TempDir(), "unsigned.zip")); err != signutils.ErrNoSignature {
+		t.Errorf("VerifyExport() of an unsigned export = %v, want %v", err, signutils.ErrNoSignature)
+	}
+}
+
+func TestVerifyExportSignedByAnotherVault(t *testing.T) {
+	s := setupSigningTest(t)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	path := signTestExport(t, otherKey)
+
+	verification, err := s.VerifyExport(path)
+	if err != nil {
+		t.Fatalf("VerifyExport() failed: %v", err)
+	}
+	if !verification.Valid || verification.SignedByThisVault {
+		t.Errorf("VerifyExport() = %+v, want a valid signature by another key", verification)
+	}
+	if want := signutils.Fingerprint(otherKey.Public().(ed25519.PublicKey)); verification.Fingerprint != want {
+		t.Errorf("VerifyExport() fingerprint = %s, want %s", verification.Fingerprint, want)
+	}
+}
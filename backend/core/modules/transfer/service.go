@@ -1,6 +1,8 @@
 package transfer
 
 import (
+	"Tella-Desktop/backend/utils/auditutils"
+	"Tella-Desktop/backend/utils/filestoreutils"
 	"Tella-Desktop/backend/utils/transferutils"
 	"context"
 	"database/sql"
@@ -8,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -224,6 +227,7 @@ func (s *service) AcceptTransfer(sessionID string) error {
 	select {
 	case pendingTransfer.ResponseChan <- response:
 		log("Transfer accepted for session: %s", sessionID)
+		s.audit(auditutils.ActionTransferAccepted, pendingTransfer, map[string]any{"folderId": folderID})
 		return nil
 	default:
 		log("failed to send acceptance response")
@@ -248,6 +252,7 @@ func (s *service) RejectTransfer(sessionID string) error {
 	select {
 	case pendingTransfer.ErrorChan <- errReject:
 		log("Transfer rejected for session: %s", sessionID)
+		s.audit(auditutils.ActionTransferRejected, pendingTransfer, map[string]any{})
 		return nil
 	default:
 		log("failed to send rejection response")
@@ -255,6 +260,21 @@ func (s *service) RejectTransfer(sessionID string) error {
 	}
 }
 
+// audit records the user's decision on a transfer in the audit log, adding the transfer's title and files to details
+func (s *service) audit(action string, pendingTransfer *PendingTransfer, details map[string]any) {
+	var totalSize int64
+	for _, file := range pendingTransfer.Files {
+		totalSize += file.Size
+	}
+	details["sessionId"] = pendingTransfer.SessionID
+	details["title"] = pendingTransfer.Title
+	details["fileCount"] = len(pendingTransfer.Files)
+	details["totalSize"] = totalSize
+	if err := auditutils.Append(s.db, action, details); err != nil {
+		log("failed to record %s in the audit log: %v", action, err)
+	}
+}
+
 func (s *service) GetTransfer(fileID string) (*Transfer, error) {
 	if value, ok := s.transfers.Load(fileID); ok {
 		if transfers, ok := value.(*Transfer); ok {
@@ -409,6 +429,13 @@ resolveLoop:
 	}
 
 	ongoingSession.NumReceived = ongoingSession.NumReceived + 1
+
+	// under the skip duplicate policy metadata describes the file stored before, which keeps its own provenance
+	if metadata.DuplicateOf != metadata.ID {
+		if err := s.fileService.SetTransferTitle(metadata.ID, ongoingSession.Title); err != nil {
+			log("failed to record transfer title of file %d: %v", metadata.ID, err)
+		}
+	}
 	runtime.EventsEmit(s.ctx, "file-received", map[string]interface{}{
 		"sessionId": sessionID,
 		"fileId":    fileID,
@@ -483,34 +510,24 @@ func (s *service) calculateTotalSize(files []FileInfo) int64 {
 
 var errCreateFolder = errors.New("failed to create transfer folder")
 func (s *service) createTransferFolder(title string) (int64, error) {
-	tx, err := s.db.Begin()
-	if err != nil {
-		log("failed to begin transaction: %w", err)
-		return 0, errCreateFolder
-	}
-	defer tx.Rollback()
-
 	// Create folder with the transfer title
-	result, err := tx.Exec(`
-		INSERT INTO folders (name, parent_id, created_at, updated_at) 
-		VALUES (?, NULL, datetime('now'), datetime('now'))
-	`, title)
+	name := transferFolderName(title, time.Now())
+	folderID, err := s.fileService.CreateFolder(name, 0)
 	if err != nil {
 		log("failed to create folder: %w", err)
 		return 0, errCreateFolder
 	}
 
-	folderID, err := result.LastInsertId()
-	if err != nil {
-		log("failed to get folder ID: %w", err)
-		return 0, errCreateFolder
-	}
+	log("Created transfer folder '%s' with ID: %d", name, folderID)
+	return folderID, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		log("failed to commit transaction: %w", err)
-		return 0, errCreateFolder
+// transferFolderName turns the title a sender gave a transfer into a folder name. Slashes, which folder names can't
+// contain, become dashes, and untitled transfers are named after the time they were accepted.
+func transferFolderName(title string, acceptedAt time.Time) string {
+	name := strings.TrimSpace(strings.NewReplacer("/", "-", "\\", "-", "\x00", "").Replace(title))
+	if !filestoreutils.ValidName(name) {
+		return "Transfer " + acceptedAt.Format("2006-01-02 15:04")
 	}
-
-	log("Created transfer folder '%s' with ID: %d", title, folderID)
-	return folderID, nil
+	return name
 }
@@ -0,0 +1,196 @@
+// Package auditutils keeps the audit log: an append-only record of vault operations in which every entry carries the
+// hash of the entry before it, so that altering, removing or reordering entries breaks the chain.
+package auditutils
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionFileStored       = "file_stored"
+	ActionFilesExported    = "files_exported"
+	ActionFilesDeleted     = "files_deleted"
+	ActionFoldersDeleted   = "folders_deleted"
+	ActionFileRenamed      = "file_renamed"
+	ActionFilesMoved       = "files_moved"
+	ActionFilesCopied      = "files_copied"
+	ActionFilesTrashed     = "files_trashed"
+	ActionFilesRestored    = "files_restored"
+	ActionFolderRenamed    = "folder_renamed"
+	ActionFolderMoved      = "folder_moved"
+	ActionTransferAccepted = "transfer_accepted"
+	ActionTransferRejected = "transfer_rejected"
+	ActionVaultUnlocked    = "vault_unlocked"
+	ActionVaultLocked      = "vault_locked"
+)
+
+// GenesisHash is the previous hash of the first entry
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Entry is a single operation in the audit log
+type Entry struct {
+	ID int64 `json:"id"`
+	// Timestamp is an RFC 3339 timestamp in UTC, with nanoseconds
+	Timestamp string `json:"timestamp"`
+	Action    string `json:"action"`
+	// Details is a JSON object describing the operation
+	Details  json.RawMessage `json:"details"`
+	PrevHash string          `json:"prevHash"`
+	Hash     string          `json:"hash"`
+}
+
+// ComputeHash returns the hash an entry must carry: the SHA-256 of its fields, separated by NUL bytes, which can't
+// occur in any of them
+func (e *Entry) ComputeHash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s\x00%s\x00%s\x00%s", e.ID, e.Timestamp, e.Action, e.Details, e.PrevHash)))
+	return hex.EncodeToString(sum[:])
+}
+
+// appendMu keeps the chain linear: no two entries may be appended to the same head
+var appendMu sync.Mutex
+
+// Append records an operation at the end of the audit log. details is encoded as a JSON object. It begins a
+// transaction of its own, so no transaction may be open on db.
+func Append(db *sql.DB, action string, details any) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	appendMu.Lock()
+	defer appendMu.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	entry := Entry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Action:    action,
+		Details:   encoded,
+		PrevHash:  GenesisHash,
+	}
+	var lastID int64
+	err = tx.QueryRow("SELECT id, hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&lastID, &entry.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	entry.ID = lastID + 1
+	entry.Hash = entry.ComputeHash()
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_log (id, created_at, action, details, prev_hash, hash) VALUES (?, ?, ?, ?, ?, ?)
+	`, entry.ID, entry.Timestamp, entry.Action, string(entry.Details), entry.PrevHash, entry.Hash)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReadLog returns the whole audit log, oldest entry first, along with the highest ID it ever handed out, see VerifyChain.
+// Both are read in one transaction, so that an entry appended in between can't be taken for a removed one. It begins
+// a transaction of its own, so no transaction may be open on db.
+func ReadLog(db *sql.DB) ([]Entry, int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	// nothing is written
+	defer tx.Rollback()
+
+	entries, err := getEntries(tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	lastID, err := getLastAssignedID(tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, lastID, nil
+}
+
+// getEntries returns the whole audit log, oldest entry first
+func getEntries(tx *sql.Tx) ([]Entry, error) {
+	rows, err := tx.Query("SELECT id, created_at, action, details, prev_hash, hash FROM audit_log ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		var details string
+		if err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.Action, &details, &entry.PrevHash, &entry.Hash); err != nil {
+			return nil, err
+		}
+		entry.Details = json.RawMessage(details)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// getLastAssignedID returns the highest ID the audit log ever handed out, which SQLite keeps apart from the entries
+// themselves. It is 0 if nothing was ever logged.
+func getLastAssignedID(tx *sql.Tx) (int64, error) {
+	var lastID int64
+	err := tx.QueryRow("SELECT seq FROM sqlite_sequence WHERE name = 'audit_log'").Scan(&lastID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return lastID, err
+}
+
+// Verification is the result of VerifyChain
+type Verification struct {
+	Entries int  `json:"entries"`
+	Valid   bool `json:"valid"`
+	// HeadHash is the hash of the last entry, which can be written down elsewhere to detect later truncation
+	HeadHash string `json:"headHash"`
+	// BrokenAt is the ID of the first entry that fails verification, 0 if none does
+	BrokenAt int64  `json:"brokenAt"`
+	Problem  string `json:"problem"`
+}
+
+// VerifyChain checks that entries form an unbroken chain from the first entry ever logged to lastAssignedID, see
+// ReadLog
+func VerifyChain(entries []Entry, lastAssignedID int64) Verification {
+	result := Verification{Entries: len(entries), HeadHash: GenesisHash}
+	broken := func(id int64, format string, args ...any) Verification {
+		result.BrokenAt = id
+		result.Problem = fmt.Sprintf(format, args...)
+		return result
+	}
+
+	prevHash := GenesisHash
+	for i, entry := range entries {
+		if entry.ID != int64(i)+1 {
+			return broken(entry.ID, "expected entry %d, found entry %d: entries were removed", i+1, entry.ID)
+		}
+		if entry.PrevHash != prevHash {
+			return broken(entry.ID, "entry does not link to the entry before it")
+		}
+		if entry.ComputeHash() != entry.Hash {
+			return broken(entry.ID, "entry was modified after it was logged")
+		}
+		prevHash = entry.Hash
+	}
+	if int64(len(entries)) != lastAssignedID {
+		return broken(int64(len(entries))+1, "the log ends at entry %d, but entries up to %d were logged", len(entries), lastAssignedID)
+	}
+
+	result.Valid = true
+	result.HeadHash = prevHash
+	return result
+}
@@ -0,0 +1,130 @@
+package auditutils
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/utils/constants"
+)
+
+func setupAuditTest(t *testing.T) *sql.DB {
+	t.Helper()
+	key := make([]byte, constants.KeyLength)
+	db, err := database.Initialize(filepath.Join(t.TempDir(), "tella.db"), key)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for i, action := range []string{ActionVaultUnlocked, ActionFileStored, ActionFilesExported, ActionVaultLocked} {
+		if err := Append(db.DB, action, map[string]any{"step": i}); err != nil {
+			t.Fatalf("Append(%s) failed: %v", action, err)
+		}
+	}
+	return db.DB
+}
+
+func verify(t *testing.T, db *sql.DB) Verification {
+	t.Helper()
+	entries, lastID, err := ReadLog(db)
+	if err != nil {
+		t.Fatalf("ReadLog() failed: %v", err)
+	}
+	return VerifyChain(entries, lastID)
+}
+
+func TestAppendBuildsValidChain(t *testing.T) {
+	db := setupAuditTest(t)
+
+	entries, _, err := ReadLog(db)
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("ReadLog() = %d entries, %v, want 4", len(entries), err)
+	}
+	if entries[0].PrevHash != GenesisHash {
+		t.Errorf("first entry links to %s, want the genesis hash", entries[0].PrevHash)
+	}
+	if string(entries[1].Details) != `{"step":1}` {
+		t.Errorf("details = %s, want {\"step\":1}", entries[1].Details)
+	}
+
+	result := verify(t, db)
+	if !result.Valid || result.Entries != 4 || result.HeadHash != entries[3].Hash {
+		t.Errorf("VerifyChain() = %+v, want a valid chain of 4 ending in %s", result, entries[3].Hash)
+	}
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	db := setupAuditTest(t)
+
+	if _, err := db.Exec("UPDATE audit_log SET action = 'nothing' WHERE id = 2"); err == nil {
+		t.Errorf("updating an entry succeeded")
+	}
+	if _, err := db.Exec("DELETE FROM audit_log WHERE id = 4"); err == nil {
+		t.Errorf("deleting an entry succeeded")
+	}
+	if result := verify(t, db); !result.Valid {
+		t.Errorf("VerifyChain() = %+v, want a valid chain", result)
+	}
+}
+
+func TestVerifyChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   string
+		brokenAt int64
+	}{
+		{"modified entry", "UPDATE audit_log SET details = '{\"step\":9}' WHERE id = 2", 2},
+		{"removed entry", "DELETE FROM audit_log WHERE id = 2", 3},
+		{"truncated log", "DELETE FROM audit_log WHERE id = 4", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupAuditTest(t)
+			// someone holding the database key can drop the triggers, which the chain must reveal
+			if _, err := db.Exec("DROP TRIGGER audit_log_no_update; DROP TRIGGER audit_log_no_delete"); err != nil {
+				t.Fatalf("Failed to drop triggers: %v", err)
+			}
+			if _, err := db.Exec(tt.tamper); err != nil {
+				t.Fatalf("Failed to tamper with the log: %v", err)
+			}
+
+			result := verify(t, db)
+			if result.Valid || result.BrokenAt != tt.brokenAt {
+				t.Errorf("VerifyChain() = %+v, want broken at entry %d", result, tt.brokenAt)
+			}
+		})
+	}
+}
+
+func TestReadLogDuringAppends(t *testing.T) {
+	db := setupAuditTest(t)
+
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 50; i++ {
+			if err := Append(db, ActionFileStored, map[string]any{"step": i}); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for appending := true; appending; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Append() failed: %v", err)
+			}
+			appending = false
+		default:
+		}
+		if result := verify(t, db); !result.Valid {
+			t.Fatalf("VerifyChain() while appending = %+v, want a valid chain", result)
+		}
+	}
+	if result := verify(t, db); !result.Valid || result.Entries != 54 {
+		t.Errorf("VerifyChain() = %+v, want a valid chain of 54", result)
+	}
+}
@@ -0,0 +1,335 @@
+package authutils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	util "Tella-Desktop/backend/utils/genericutil"
+)
+
+// Segmented AEAD format used for file payloads stored in the TVault.
+//
+// A chunked ciphertext starts with a fixed header, followed by one or more sealed chunks:
+//
+//	header: magic "TVC1" (4 bytes) | plaintext chunk size (uint32, little endian)
+//	chunk:  nonce (12 bytes) | AES-256-GCM ciphertext | tag (16 bytes)
+//
+// Every chunk except the last holds exactly `chunk size` bytes of plaintext, the last holds between 0 and `chunk size`
+// bytes. An empty plaintext is stored as a single, empty, final chunk. The additional data of each chunk is
+//
+//	header | associated id | chunk index (uint64, big endian) | final flag (1 byte)
+//
+// which binds each chunk to its file (the associated id is the file UUID) and its position, and prevents chunks from
+// being reordered, swapped between files or the stream from being truncated without detection.
+const (
+	ChunkedHeaderSize = 8
+	DefaultChunkSize  = 1 << 20 // 1 MiB of plaintext per chunk
+	chunkNonceSize    = 12
+	chunkTagSize      = 16
+	ChunkOverhead     = chunkNonceSize + chunkTagSize
+)
+
+var chunkedMagic = []byte("TVC1")
+
+var (
+	ErrChunkedHeader    = errors.New("invalid chunked ciphertext header")
+	ErrChunkedTruncated = errors.New("chunked ciphertext is truncated")
+	ErrChunkedAuth      = errors.New("chunk failed authentication")
+	ErrChunkedClosed    = errors.New("chunked writer already closed")
+)
+
+// ChunkedCiphertextSize returns the exact number of bytes a plaintext of size plaintextSize occupies once encrypted in
+// the chunked format.
+func ChunkedCiphertextSize(plaintextSize int64, chunkSize int) int64 {
+	numChunks := plaintextSize / int64(chunkSize)
+	if plaintextSize%int64(chunkSize) != 0 || numChunks == 0 {
+		numChunks++
+	}
+	return ChunkedHeaderSize + plaintextSize + numChunks*ChunkOverhead
+}
+
+func newChunkAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithRandomNonce(block)
+}
+
+func chunkAdditionalData(dst, header, associatedID []byte, index uint64, final bool) []byte {
+	dst = append(dst[:0], header...)
+	dst = append(dst, associatedID...)
+	dst = binary.BigEndian.AppendUint64(dst, index)
+	if final {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// ChunkedWriter encrypts everything written to it into the chunked format. Close must be called to seal the final
+// chunk; the ciphertext is incomplete (and will fail to decrypt) until then.
+type ChunkedWriter struct {
+	dst          io.Writer
+	aead         cipher.AEAD
+	header       []byte
+	associatedID []byte
+	chunkSize    int
+	buf          []byte
+	sealed       []byte
+	ad           []byte
+	index        uint64
+	closed       bool
+}
+
+// NewChunkedWriter writes the chunked header to dst and returns a writer sealing plaintext chunks with key.
+func NewChunkedWriter(dst io.Writer, key, associatedID []byte) (*ChunkedWriter, error) {
+	return newChunkedWriterSize(dst, key, associatedID, DefaultChunkSize)
+}
+
+func newChunkedWriterSize(dst io.Writer, key, associatedID []byte, chunkSize int) (*ChunkedWriter, error) {
+	aead, err := newChunkAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, ChunkedHeaderSize)
+	copy(header, chunkedMagic)
+	binary.LittleEndian.PutUint32(header[len(chunkedMagic):], uint32(chunkSize))
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &ChunkedWriter{
+		dst:          dst,
+		aead:         aead,
+		header:       header,
+		associatedID: associatedID,
+		chunkSize:    chunkSize,
+		buf:          make([]byte, 0, chunkSize),
+		sealed:       make([]byte, 0, chunkSize+ChunkOverhead),
+	}, nil
+}
+
+func (w *ChunkedWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrChunkedClosed
+	}
+	written := 0
+	for len(p) > 0 {
+		// a full buffer is only sealed once more data arrives, as we can't know if it is the final chunk before then
+		if len(w.buf) == w.chunkSize {
+			if err := w.sealChunk(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):w.chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *ChunkedWriter) sealChunk(final bool) error {
+	w.ad = chunkAdditionalData(w.ad, w.header, w.associatedID, w.index, final)
+	w.sealed = w.aead.Seal(w.sealed[:0], nil, w.buf, w.ad)
+	util.SecureZeroMemory(w.buf)
+	w.buf = w.buf[:0]
+	w.index++
+	_, err := w.dst.Write(w.sealed)
+	return err
+}
+
+// Close seals the final chunk and erases the writer's plaintext buffer. It does not close the underlying writer.
+func (w *ChunkedWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.sealChunk(true)
+	util.SecureZeroMemory(w.buf)
+	util.SecureZeroMemory(w.sealed)
+	return err
+}
+
+// Discard erases the writer's buffers without sealing the final chunk, for writes that are being aborted. The
+// ciphertext written so far is left incomplete.
+func (w *ChunkedWriter) Discard() {
+	w.closed = true
+	util.SecureZeroMemory(w.buf)
+	util.SecureZeroMemory(w.sealed)
+}
+
+// ChunkedReader decrypts a chunked ciphertext. Every chunk is authenticated before any of its plaintext is returned,
+// and reaching the end of the stream without having seen the final chunk is reported as ErrChunkedTruncated.
+type ChunkedReader struct {
+	src          io.ReaderAt
+	aead         cipher.AEAD
+	header       []byte
+	associatedID []byte
+	chunkSize    int64
+	numChunks    int64
+	size         int64
+	pos          int64
+	// currently decrypted chunk
+	plain      []byte
+	plainIndex int64
+	sealed     []byte
+	ad         []byte
+}
+
+// NewChunkedReader reads the chunked header from the first bytes of src, where length is the total ciphertext length.
+func NewChunkedReader(src io.ReaderAt, length int64, key, associatedID []byte) (*ChunkedReader, error) {
+	if length < ChunkedHeaderSize+ChunkOverhead {
+		return nil, ErrChunkedTruncated
+	}
+	header := make([]byte, ChunkedHeaderSize)
+	if _, err := src.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if string(header[:len(chunkedMagic)]) != string(chunkedMagic) {
+		return nil, ErrChunkedHeader
+	}
+	chunkSize := int64(binary.LittleEndian.Uint32(header[len(chunkedMagic):]))
+	if chunkSize == 0 {
+		return nil, ErrChunkedHeader
+	}
+
+	aead, err := newChunkAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	body := length - ChunkedHeaderSize
+	sealedChunkSize := chunkSize + ChunkOverhead
+	numChunks := body / sealedChunkSize
+	if rest := body % sealedChunkSize; rest != 0 {
+		if rest < ChunkOverhead {
+			return nil, ErrChunkedTruncated
+		}
+		numChunks++
+	}
+
+	return &ChunkedReader{
+		src:          src,
+		aead:         aead,
+		header:       header,
+		associatedID: associatedID,
+		chunkSize:    chunkSize,
+		numChunks:    numChunks,
+		size:         body - numChunks*ChunkOverhead,
+		plainIndex:   -1,
+	}, nil
+}
+
+// Size returns the plaintext size of the stream.
+func (r *ChunkedReader) Size() int64 {
+	return r.size
+}
+
+func (r *ChunkedReader) loadChunk(index int64) error {
+	if index == r.plainIndex {
+		return nil
+	}
+	start := ChunkedHeaderSize + index*(r.chunkSize+ChunkOverhead)
+	sealedLen := r.chunkSize + ChunkOverhead
+	if index == r.numChunks-1 {
+		sealedLen = r.size - index*r.chunkSize + ChunkOverhead
+	}
+
+	if int64(cap(r.sealed)) < sealedLen {
+		r.sealed = make([]byte, sealedLen)
+	}
+	r.sealed = r.sealed[:sealedLen]
+	if _, err := r.src.ReadAt(r.sealed, start); err != nil {
+		if errors.Is(err, io.EOF) {
+			return ErrChunkedTruncated
+		}
+		return err
+	}
+
+	r.ad = chunkAdditionalData(r.ad, r.header, r.associatedID, uint64(index), index == r.numChunks-1)
+	if r.plain != nil {
+		util.SecureZeroMemory(r.plain)
+	}
+	plain, err := r.aead.Open(r.plain[:0], nil, r.sealed, r.ad)
+	if err != nil {
+		r.plainIndex = -1
+		return ErrChunkedAuth
+	}
+	r.plain = plain
+	r.plainIndex = index
+	return nil
+}
+
+func (r *ChunkedReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		// an empty plaintext still has to authenticate its (empty) final chunk
+		if r.size == 0 && r.plainIndex != 0 {
+			if err := r.loadChunk(0); err != nil {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && r.pos < r.size {
+		index := r.pos / r.chunkSize
+		if err := r.loadChunk(index); err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], r.plain[r.pos-index*r.chunkSize:])
+		n += copied
+		r.pos += int64(copied)
+	}
+	return n, nil
+}
+
+// ReadAt implements io.ReaderAt in terms of Read, leaving the read offset used by Read untouched. It is not safe for
+// concurrent use.
+func (r *ChunkedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errChunkedSeek
+	}
+	pos := r.pos
+	defer func() { r.pos = pos }()
+	r.pos = off
+	n, err := io.ReadFull(r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+var errChunkedSeek = errors.New("invalid seek position")
+
+func (r *ChunkedReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.pos + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errChunkedSeek
+	}
+	if abs < 0 {
+		return 0, errChunkedSeek
+	}
+	r.pos = abs
+	return abs, nil
+}
+
+// Close erases the reader's plaintext buffer.
+func (r *ChunkedReader) Close() error {
+	if r.plain != nil {
+		util.SecureZeroMemory(r.plain)
+	}
+	r.plainIndex = -1
+	return nil
+}
@@ -0,0 +1,189 @@
+package authutils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"Tella-Desktop/backend/utils/constants"
+)
+
+func encryptChunkedForTest(t *testing.T, data, key, associatedID []byte, chunkSize int) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := newChunkedWriterSize(&out, key, associatedID, chunkSize)
+	if err != nil {
+		t.Fatalf("Failed to create chunked writer: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Failed to write data: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close chunked writer: %v", err)
+	}
+	return out.Bytes()
+}
+
+func randomKeyForTest(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, constants.KeyLength)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate random key: %v", err)
+	}
+	return key
+}
+
+func TestChunkedRoundTrip(t *testing.T) {
+	key := randomKeyForTest(t)
+	id := []byte("3f1c6a52-0d7e-4b1a-9c55-2f0b8b7a1e11")
+	chunkSize := 64
+
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize, 10*chunkSize + 7}
+	for _, size := range sizes {
+		t.Run(fmt.Sprintf("Size_%d", size), func(t *testing.T) {
+			data := make([]byte, size)
+			if _, err := rand.Read(data); err != nil {
+				t.Fatalf("Failed to generate random data: %v", err)
+			}
+
+			encrypted := encryptChunkedForTest(t, data, key, id, chunkSize)
+			if int64(len(encrypted)) != ChunkedCiphertextSize(int64(size), chunkSize) {
+				t.Fatalf("Ciphertext length %d does not match predicted length %d", len(encrypted), ChunkedCiphertextSize(int64(size), chunkSize))
+			}
+
+			r, err := NewChunkedReader(bytes.NewReader(encrypted), int64(len(encrypted)), key, id)
+			if err != nil {
+				t.Fatalf("Failed to create chunked reader: %v", err)
+			}
+			defer r.Close()
+			if r.Size() != int64(size) {
+				t.Errorf("Reader reports size %d, expected %d", r.Size(), size)
+			}
+
+			decrypted, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Failed to decrypt data: %v", err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Errorf("Decrypted data does not match original data")
+			}
+		})
+	}
+}
+
+func TestChunkedSeek(t *testing.T) {
+	key := randomKeyForTest(t)
+	id := []byte("file-id")
+	data := make([]byte, 1000)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("Failed to generate random data: %v", err)
+	}
+	encrypted := encryptChunkedForTest(t, data, key, id, 64)
+
+	r, err := NewChunkedReader(bytes.NewReader(encrypted), int64(len(encrypted)), key, id)
+	if err != nil {
+		t.Fatalf("Failed to create chunked reader: %v", err)
+	}
+	defer r.Close()
+
+	for _, off := range []int64{0, 63, 64, 500, 999} {
+		buf := make([]byte, 100)
+		n, err := r.ReadAt(buf, off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			t.Fatalf("ReadAt(%d) failed: %v", off, err)
+		}
+		if !bytes.Equal(buf[:n], data[off:off+int64(n)]) {
+			t.Errorf("ReadAt(%d) returned wrong data", off)
+		}
+	}
+
+	// ReadAt must not move the offset used by Read
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to decrypt data: %v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Errorf("Read after ReadAt did not start at the beginning of the stream")
+	}
+
+	if _, err := r.Seek(900, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read after seek: %v", err)
+	}
+	if !bytes.Equal(rest, data[900:]) {
+		t.Errorf("Read after Seek returned wrong data")
+	}
+}
+
+func TestChunkedTampering(t *testing.T) {
+	key := randomKeyForTest(t)
+	id := []byte("file-id")
+	chunkSize := 64
+	data := make([]byte, 4*chunkSize)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("Failed to generate random data: %v", err)
+	}
+	encrypted := encryptChunkedForTest(t, data, key, id, chunkSize)
+	sealedChunk := chunkSize + ChunkOverhead
+
+	decrypt := func(ciphertext, key, id []byte) error {
+		r, err := NewChunkedReader(bytes.NewReader(ciphertext), int64(len(ciphertext)), key, id)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadAll(r)
+		return err
+	}
+
+	t.Run("WrongKey", func(t *testing.T) {
+		if err := decrypt(encrypted, randomKeyForTest(t), id); err == nil {
+			t.Errorf("Expected error when decrypting with wrong key")
+		}
+	})
+
+	t.Run("WrongAssociatedID", func(t *testing.T) {
+		if err := decrypt(encrypted, key, []byte("other-file-id")); err == nil {
+			t.Errorf("Expected error when decrypting with another file's id")
+		}
+	})
+
+	t.Run("FlippedBit", func(t *testing.T) {
+		tampered := bytes.Clone(encrypted)
+		tampered[ChunkedHeaderSize+sealedChunk+20] ^= 1
+		if err := decrypt(tampered, key, id); !errors.Is(err, ErrChunkedAuth) {
+			t.Errorf("Expected ErrChunkedAuth, got %v", err)
+		}
+	})
+
+	t.Run("SwappedChunks", func(t *testing.T) {
+		tampered := bytes.Clone(encrypted)
+		first := ChunkedHeaderSize
+		second := ChunkedHeaderSize + sealedChunk
+		copy(tampered[first:second], encrypted[second:second+sealedChunk])
+		copy(tampered[second:second+sealedChunk], encrypted[first:second])
+		if err := decrypt(tampered, key, id); !errors.Is(err, ErrChunkedAuth) {
+			t.Errorf("Expected ErrChunkedAuth, got %v", err)
+		}
+	})
+
+	t.Run("TruncatedAtChunkBoundary", func(t *testing.T) {
+		truncated := encrypted[:ChunkedHeaderSize+2*sealedChunk]
+		if err := decrypt(truncated, key, id); err == nil {
+			t.Errorf("Expected error when the final chunks are missing")
+		}
+	})
+
+	t.Run("BadHeader", func(t *testing.T) {
+		tampered := bytes.Clone(encrypted)
+		tampered[0] = 'X'
+		if err := decrypt(tampered, key, id); !errors.Is(err, ErrChunkedHeader) {
+			t.Errorf("Expected ErrChunkedHeader, got %v", err)
+		}
+	})
+}
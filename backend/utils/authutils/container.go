@@ -0,0 +1,141 @@
+package authutils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"Tella-Desktop/backend/utils/constants"
+
+	"github.com/matthewhartstonge/argon2"
+)
+
+// Password-encrypted container format used for exports that leave the machine, e.g. on a USB stick. The format, how to
+// read it without Tella Desktop and why it was chosen over age or encrypted ZIP are in
+// docs/export-container-format.md; keep the two in step.
+//
+// A container starts with a fixed header, followed by the chunked format (see ChunkedWriter) of its contents:
+//
+//	header: magic "TEC1" (4 bytes) | Argon2id time cost (uint32, little endian) |
+//	        Argon2id memory cost in KiB (uint32, little endian) | Argon2id parallelism (1 byte) | salt (16 bytes)
+//
+// The key of the chunked ciphertext is Argon2id(passphrase, salt) with the parameters of the header, and the header is
+// its associated id, so that a container whose header was tampered with fails authentication. The contents are a tar
+// archive of the exported files, along with their manifest.
+const (
+	ContainerHeaderSize          = 29
+	containerSaltSize            = 16
+	ContainerPassphraseMinLength = 12
+	// containerMaxTimeCost and containerMaxMemoryCost bound the work an imported container can ask for, so that a
+	// forged header can't exhaust the machine
+	containerMaxTimeCost   = 16
+	containerMaxMemoryCost = 1 << 20 // 1 GiB
+)
+
+var containerMagic = []byte("TEC1")
+
+var (
+	ErrContainerHeader     = errors.New("not an encrypted export container")
+	ErrContainerPassphrase = errors.New("wrong passphrase, or the container is damaged")
+	ErrWeakPassphrase      = errors.New("passphrases must be 12 to 1000 characters long")
+)
+
+// CheckContainerPassphrase checks that a passphrase is long enough to protect a container that may be lost. It is
+// kept to a higher standard than vault passwords, as anyone finding a container can attack it offline.
+func CheckContainerPassphrase(passphrase string) error {
+	length := utf8.RuneCountInString(passphrase)
+	if length < ContainerPassphraseMinLength || length > constants.PasswordMaxLength {
+		return ErrWeakPassphrase
+	}
+	return nil
+}
+
+func deriveContainerKey(passphrase string, header []byte) ([]byte, error) {
+	config := argon2.Config{
+		HashLength:  constants.KeyLength,
+		SaltLength:  containerSaltSize,
+		TimeCost:    binary.LittleEndian.Uint32(header[4:]),
+		MemoryCost:  binary.LittleEndian.Uint32(header[8:]),
+		Parallelism: header[12],
+		Mode:        argon2.ModeArgon2id,
+	}
+	raw, err := config.Hash([]byte(passphrase), header[13:])
+	if err != nil {
+		return nil, err
+	}
+	return raw.Hash, nil
+}
+
+// NewContainerWriter writes a container header to dst and returns a writer encrypting the contents under passphrase.
+// Close must be called to seal the container.
+func NewContainerWriter(dst io.Writer, passphrase string) (*ChunkedWriter, error) {
+	if err := CheckContainerPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+	defaults := argon2.MemoryConstrainedDefaults()
+	header := make([]byte, ContainerHeaderSize)
+	copy(header, containerMagic)
+	binary.LittleEndian.PutUint32(header[4:], defaults.TimeCost)
+	binary.LittleEndian.PutUint32(header[8:], defaults.MemoryCost)
+	header[12] = defaults.Parallelism
+	if _, err := rand.Read(header[13:]); err != nil {
+		return nil, err
+	}
+
+	key, err := deriveContainerKey(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	defer argon2.SecureZeroMemory(key)
+
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+	return NewChunkedWriter(dst, key, header)
+}
+
+// OpenContainer reads the container header from the first bytes of src, where length is the size of the container, and
+// returns a reader decrypting its contents. The first chunk is authenticated right away, so that a wrong passphrase is
+// reported as ErrContainerPassphrase here rather than on the first read.
+func OpenContainer(src io.ReaderAt, length int64, passphrase string) (*ChunkedReader, error) {
+	header := make([]byte, ContainerHeaderSize)
+	if _, err := src.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrContainerHeader
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(containerMagic)], containerMagic) {
+		return nil, ErrContainerHeader
+	}
+	timeCost := binary.LittleEndian.Uint32(header[4:])
+	memoryCost := binary.LittleEndian.Uint32(header[8:])
+	if timeCost == 0 || timeCost > containerMaxTimeCost || memoryCost == 0 || memoryCost > containerMaxMemoryCost || header[12] == 0 {
+		return nil, ErrContainerHeader
+	}
+
+	key, err := deriveContainerKey(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	defer argon2.SecureZeroMemory(key)
+
+	reader, err := NewChunkedReader(io.NewSectionReader(src, ContainerHeaderSize, length-ContainerHeaderSize), length-ContainerHeaderSize, key, header)
+	if err != nil {
+		if errors.Is(err, ErrChunkedHeader) || errors.Is(err, ErrChunkedTruncated) {
+			return nil, ErrContainerHeader
+		}
+		return nil, err
+	}
+	if err := reader.loadChunk(0); err != nil {
+		reader.Close()
+		if errors.Is(err, ErrChunkedAuth) {
+			return nil, ErrContainerPassphrase
+		}
+		return nil, err
+	}
+	return reader, nil
+}
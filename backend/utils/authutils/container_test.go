@@ -0,0 +1,80 @@
+package authutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func encryptContainerForTest(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewContainerWriter(&out, testPassphrase)
+	if err != nil {
+		t.Fatalf("Failed to create container writer: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Failed to write data: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close container writer: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestContainerRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("exported evidence "), 100000)
+	container := encryptContainerForTest(t, data)
+
+	reader, err := OpenContainer(bytes.NewReader(container), int64(len(container)), testPassphrase)
+	if err != nil {
+		t.Fatalf("OpenContainer() failed: %v", err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read container: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Decrypted contents differ from what was written")
+	}
+}
+
+func TestContainerRejectsWrongPassphrase(t *testing.T) {
+	container := encryptContainerForTest(t, []byte("secret"))
+	_, err := OpenContainer(bytes.NewReader(container), int64(len(container)), "not the right passphrase")
+	if !errors.Is(err, ErrContainerPassphrase) {
+		t.Errorf("OpenContainer() returned %v, want ErrContainerPassphrase", err)
+	}
+}
+
+func TestContainerRejectsTamperedHeader(t *testing.T) {
+	container := encryptContainerForTest(t, []byte("secret"))
+
+	// a different salt derives a different key, and the header is authenticated along with every chunk
+	salted := bytes.Clone(container)
+	salted[ContainerHeaderSize-1] ^= 1
+	if _, err := OpenContainer(bytes.NewReader(salted), int64(len(salted)), testPassphrase); !errors.Is(err, ErrContainerPassphrase) {
+		t.Errorf("OpenContainer() with a changed salt returned %v, want ErrContainerPassphrase", err)
+	}
+
+	// parameters beyond the limits are refused before any key is derived
+	costly := bytes.Clone(container)
+	costly[11] = 0xff
+	if _, err := OpenContainer(bytes.NewReader(costly), int64(len(costly)), testPassphrase); !errors.Is(err, ErrContainerHeader) {
+		t.Errorf("OpenContainer() with a huge memory cost returned %v, want ErrContainerHeader", err)
+	}
+
+	if _, err := OpenContainer(bytes.NewReader([]byte("PK\x03\x04")), 4, testPassphrase); !errors.Is(err, ErrContainerHeader) {
+		t.Errorf("OpenContainer() on a ZIP returned %v, want ErrContainerHeader", err)
+	}
+}
+
+func TestContainerRefusesWeakPassphrase(t *testing.T) {
+	if _, err := NewContainerWriter(io.Discard, "short"); !errors.Is(err, ErrWeakPassphrase) {
+		t.Errorf("NewContainerWriter() returned %v, want ErrWeakPassphrase", err)
+	}
+}
@@ -16,30 +16,116 @@ type Config struct {
 	MaxFileSizeBytes int64 `json:"maxFileSizeBytes"`
 	MaxFileCount     int   `json:"maxFileCount"`
 	Port             int   `json:"defaultPort"`
+	// DuplicatePolicy decides what happens to a file whose content is already stored: one of the DuplicatePolicy
+	// constants. Empty means the default.
+	DuplicatePolicy string `json:"duplicatePolicy"`
+	// TrashRetentionDays is how long deleted files stay in the trash before they are securely purged. Zero or less
+	// means the default.
+	TrashRetentionDays int `json:"trashRetentionDays"`
+	// ExportDir is where exports are written unless a destination is given. Empty means the default.
+	ExportDir string `json:"exportDir"`
+	// RefuseCloudSyncExports refuses export destinations inside folders synced to cloud storage
+	RefuseCloudSyncExports bool `json:"refuseCloudSyncExports"`
 }
 
+const (
+	// DuplicatePolicyStore stores duplicates like any other file
+	DuplicatePolicyStore = "store"
+	// DuplicatePolicyLink adds duplicates as new files sharing the ciphertext already stored. It is opt-in: securely
+	// deleting one of the files leaves the shared ciphertext in place for as long as another file refers to it.
+	DuplicatePolicyLink = "link"
+	// DuplicatePolicySkip doesn't add duplicates at all
+	DuplicatePolicySkip = "skip"
+)
+
 var defaultMaxFileSize int64 = 3000000000 // 3 GB
 var defaultMaxFileCount int = 1000
 var defaultPort = 53320
+var defaultDuplicatePolicy = DuplicatePolicyStore
+var defaultTrashRetentionDays = 30
+
+// ValidDuplicatePolicy reports whether policy is one of the DuplicatePolicy constants
+func ValidDuplicatePolicy(policy string) bool {
+	switch policy {
+	case DuplicatePolicyStore, DuplicatePolicyLink, DuplicatePolicySkip:
+		return true
+	}
+	return false
+}
+
+// GetDuplicatePolicy returns the configured duplicate policy, falling back to the default for missing or unknown values
+func (c Config) GetDuplicatePolicy() string {
+	if ValidDuplicatePolicy(c.DuplicatePolicy) {
+		return c.DuplicatePolicy
+	}
+	return defaultDuplicatePolicy
+}
+
+// GetTrashRetentionDays returns the configured trash retention, falling back to the default for missing or invalid values
+func (c Config) GetTrashRetentionDays() int {
+	if c.TrashRetentionDays <= 0 {
+		return defaultTrashRetentionDays
+	}
+	return c.TrashRetentionDays
+}
+
+// GetExportDir returns the configured export directory, falling back to the default
+func (c Config) GetExportDir() string {
+	if c.ExportDir == "" {
+		return authutils.GetExportDir()
+	}
+	return c.ExportDir
+}
+
+func defaultConfig() Config {
+	return Config{
+		MaxFileSizeBytes:   defaultMaxFileSize,
+		MaxFileCount:       defaultMaxFileCount,
+		Port:               defaultPort,
+		DuplicatePolicy:    defaultDuplicatePolicy,
+		TrashRetentionDays: defaultTrashRetentionDays,
+	}
+}
 
 func WriteDefaultConfig() {
-	defaultConfig := fmt.Sprintf(`maxFileSizeBytes = %d
-maxFileCount = %d
-defaultPort = %d
-`, defaultMaxFileSize, defaultMaxFileCount, defaultPort)
-	err := os.WriteFile(authutils.GetConfigFilePath(), []byte(defaultConfig), genericutil.USER_ONLY_FILE_PERMS)
-	if err != nil {
+	if err := writeConfig(defaultConfig()); err != nil {
 		panic(err)
 	}
 }
 
+// tomlString quotes a string for TOML; the escapes of JSON strings are valid in TOML basic strings
+func tomlString(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+func writeConfig(conf Config) error {
+	content := fmt.Sprintf(`maxFileSizeBytes = %d
+maxFileCount = %d
+defaultPort = %d
+duplicatePolicy = %s
+trashRetentionDays = %d
+exportDir = %s
+refuseCloudSyncExports = %t
+`, conf.MaxFileSizeBytes, conf.MaxFileCount, conf.Port, tomlString(conf.DuplicatePolicy), conf.TrashRetentionDays,
+		tomlString(conf.ExportDir), conf.RefuseCloudSyncExports)
+	return os.WriteFile(authutils.GetConfigFilePath(), []byte(content), genericutil.USER_ONLY_FILE_PERMS)
+}
+
+// UpdateConfig applies update to the config and writes it back to the config file
+func UpdateConfig(update func(conf *Config)) error {
+	conf := ReadConfig()
+	update(&conf)
+	return writeConfig(conf)
+}
+
 // TODO cblgh(2026-03-06): decide how to handle filesystem-level errors
 func ReadConfig() Config {
 	content, err := os.ReadFile(authutils.GetConfigFilePath())
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			WriteDefaultConfig()
-			return Config{defaultMaxFileSize, defaultMaxFileCount, defaultPort}
+			return defaultConfig()
 		} else {
 			panic(err)
 		}
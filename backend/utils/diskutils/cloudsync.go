@@ -0,0 +1,100 @@
+package diskutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cloudSyncEnvVars name environment variables that sync clients set to the folders they sync
+var cloudSyncEnvVars = []string{"OneDrive", "OneDriveConsumer", "OneDriveCommercial"}
+
+// cloudSyncHomeFolders are the names, or name prefixes ending in a space, of the folders sync clients create in the
+// home directory
+var cloudSyncHomeFolders = []string{
+	"Dropbox", "Dropbox ", "OneDrive", "OneDrive ", "Google Drive", "GoogleDrive", "My Drive", "iCloudDrive",
+	"iCloud Drive", "Box", "Box Sync", "pCloudDrive", "pCloud Drive", "MEGA", "MEGAsync", "Nextcloud", "ownCloud",
+	"SynologyDrive", "Yandex.Disk",
+}
+
+// cloudSyncLibraryFolders are where macOS keeps the folders of iCloud Drive and of file provider based sync clients,
+// relative to the home directory
+var cloudSyncLibraryFolders = []string{
+	filepath.Join("Library", "Mobile Documents"),
+	filepath.Join("Library", "CloudStorage"),
+}
+
+// CloudSyncRoots lists the folders known to be synced to cloud storage for the user with the given home directory.
+// getenv looks up environment variables. Only folders in their usual places are known: a sync client can be set up to
+// sync any folder, such as the Documents folder itself.
+func CloudSyncRoots(home string, getenv func(string) string) []string {
+	var roots []string
+	for _, name := range cloudSyncEnvVars {
+		if root := getenv(name); root != "" {
+			roots = append(roots, root)
+		}
+	}
+	for _, folder := range cloudSyncLibraryFolders {
+		roots = append(roots, filepath.Join(home, folder))
+	}
+
+	entries, err := os.ReadDir(home)
+	if err != nil {
+		return roots
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		for _, known := range cloudSyncHomeFolders {
+			if entry.Name() == known || (strings.HasSuffix(known, " ") && strings.HasPrefix(entry.Name(), known)) {
+				roots = append(roots, filepath.Join(home, entry.Name()))
+				break
+			}
+		}
+	}
+	return roots
+}
+
+// InCloudSyncFolder reports whether path is one of the folders known to be synced to cloud storage, or inside one, see
+// CloudSyncRoots. Symbolic links are followed, so that a link pointing into a synced folder is caught too.
+func InCloudSyncFolder(path string) (bool, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false, err
+	}
+	path, err = resolve(path)
+	if err != nil {
+		return false, err
+	}
+	for _, root := range CloudSyncRoots(home, os.Getenv) {
+		root, err := resolve(root)
+		if err != nil {
+			// roots that don't exist can't contain anything
+			continue
+		}
+		if within(root, path) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resolve returns the absolute path of path with symbolic links evaluated
+func resolve(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// within reports whether path is root or inside it. Folder names are compared ignoring case, as on the default
+// filesystems of Windows and macOS.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(strings.ToLower(root), strings.ToLower(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,6 @@
+// Package diskutils queries the filesystems files are stored on
+package diskutils
+
+import "errors"
+
+var ErrUnsupported = errors.New("free disk space is not available on this platform")
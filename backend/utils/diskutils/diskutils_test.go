@@ -0,0 +1,71 @@
+package diskutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFreeSpace(t *testing.T) {
+	free, err := FreeSpace(t.TempDir())
+	if err != nil {
+		t.Fatalf("FreeSpace() failed: %v", err)
+	}
+	if free <= 0 {
+		t.Errorf("FreeSpace() = %d, want a positive number of bytes", free)
+	}
+
+	if _, err := FreeSpace(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("FreeSpace() of a missing path succeeded")
+	}
+}
+
+func TestCloudSyncRoots(t *testing.T) {
+	home := t.TempDir()
+	for _, name := range []string{"Dropbox", "OneDrive - Example Org", "Documents", "Boxes"} {
+		if err := os.Mkdir(filepath.Join(home, name), 0700); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+	}
+	getenv := func(name string) string {
+		if name == "OneDriveCommercial" {
+			return filepath.Join(home, "Work")
+		}
+		return ""
+	}
+
+	roots := map[string]bool{}
+	for _, root := range CloudSyncRoots(home, getenv) {
+		roots[root] = true
+	}
+	for _, name := range []string{"Dropbox", "OneDrive - Example Org", "Work", filepath.Join("Library", "CloudStorage")} {
+		if !roots[filepath.Join(home, name)] {
+			t.Errorf("CloudSyncRoots() is missing %s", name)
+		}
+	}
+	for _, name := range []string{"Documents", "Boxes"} {
+		if roots[filepath.Join(home, name)] {
+			t.Errorf("CloudSyncRoots() lists %s", name)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	root := filepath.Join("home", "user", "Dropbox")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{root, true},
+		{filepath.Join(root, "Exports"), true},
+		{filepath.Join("home", "user", "dropbox", "Exports"), true},
+		{filepath.Join("home", "user", "Dropbox2"), false},
+		{filepath.Join("home", "user", "Documents"), false},
+		{filepath.Join("home", "user", "..Dropbox"), false},
+	}
+	for _, tt := range tests {
+		if got := within(root, tt.path); got != tt.want {
+			t.Errorf("within(%q, %q) = %v, want %v", root, tt.path, got, tt.want)
+		}
+	}
+}
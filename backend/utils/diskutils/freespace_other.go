@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package diskutils
+
+// FreeSpace is not implemented on this platform
+func FreeSpace(path string) (int64, error) {
+	return 0, ErrUnsupported
+}
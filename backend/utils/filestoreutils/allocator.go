@@ -0,0 +1,156 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The free_spaces table is the free list of the TVault allocator. Its blocks never overlap and never touch each other:
+// AddFreeSpace merges a freed region with any adjacent blocks, and FindSpace hands out the front of the smallest
+// block that fits, keeping whatever is left over as a smaller block.
+
+type freeBlock struct {
+	id     int64
+	offset int64
+	length int64
+}
+
+// FindSpace allocates size bytes of the TVault, returning the offset of the allocated region. It prefers the smallest
+// free block that fits and otherwise allocates at the end of the TVault, growing the file so that the region can't be
+// handed out again before it is written to.
+func FindSpace(tx *sql.Tx, size int64, tvaultPath string) (int64, error) {
+	if size <= 0 {
+		return 0, fmt.Errorf("invalid allocation size %d", size)
+	}
+
+	// First try to find a free space that fits
+	var block freeBlock
+	err := tx.QueryRow(`
+		SELECT id, offset, length FROM free_spaces
+		WHERE length >= ?
+		ORDER BY length ASC, offset ASC LIMIT 1
+	`, size).Scan(&block.id, &block.offset, &block.length)
+
+	if err == nil {
+		if block.length == size {
+			_, err = tx.Exec("DELETE FROM free_spaces WHERE id = ?", block.id)
+		} else {
+			// split the block: keep the remainder as free space
+			_, err = tx.Exec("UPDATE free_spaces SET offset = ?, length = ? WHERE id = ?", block.offset+size, block.length-size, block.id)
+		}
+		if err != nil {
+			return 0, err
+		}
+		return block.offset, nil
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	// No suitable free space found, append to end of file
+	file, err := os.Stat(tvaultPath)
+	if err != nil {
+		return 0, err
+	}
+	offset := file.Size()
+
+	// a free block at the very end of the file is too small on its own, but can be extended
+	err = tx.QueryRow(`
+		SELECT id, offset FROM free_spaces
+		WHERE offset + length = ?
+	`, offset).Scan(&block.id, &block.offset)
+	if err == nil {
+		if _, err := tx.Exec("DELETE FROM free_spaces WHERE id = ?", block.id); err != nil {
+			return 0, err
+		}
+		offset = block.offset
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	if err := os.Truncate(tvaultPath, offset+size); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+var (
+	errAddFreeSpace = errors.New("failed to record free space")
+	errDoubleFree   = errors.New("region overlaps free space")
+)
+
+// AddFreeSpace records a new free space area in the database, merging it with adjacent free blocks
+func AddFreeSpace(tx *sql.Tx, offset, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+
+	// a freed region overlapping existing free space means something is being freed twice, which would lead to the
+	// same region being handed out twice
+	var overlapping int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM free_spaces
+		WHERE offset < ? AND offset + length > ?
+	`, offset+length, offset).Scan(&overlapping)
+	if err != nil {
+		log("failed to check for overlapping free space: %v", err)
+		return errAddFreeSpace
+	}
+	if overlapping > 0 {
+		log("refusing to free region at offset %d (length %d): %v", offset, length, errDoubleFree)
+		return errDoubleFree
+	}
+
+	start, end := offset, offset+length
+	var neighbour freeBlock
+	// merge with the block ending where the freed region starts
+	err = tx.QueryRow("SELECT id, offset FROM free_spaces WHERE offset + length = ?", start).Scan(&neighbour.id, &neighbour.offset)
+	if err == nil {
+		start = neighbour.offset
+		if _, err := tx.Exec("DELETE FROM free_spaces WHERE id = ?", neighbour.id); err != nil {
+			log("failed to merge free space: %v", err)
+			return errAddFreeSpace
+		}
+	} else if err != sql.ErrNoRows {
+		log("failed to look up adjacent free space: %v", err)
+		return errAddFreeSpace
+	}
+
+	// merge with the block starting where the freed region ends
+	err = tx.QueryRow("SELECT id, length FROM free_spaces WHERE offset = ?", end).Scan(&neighbour.id, &neighbour.length)
+	if err == nil {
+		end += neighbour.length
+		if _, err := tx.Exec("DELETE FROM free_spaces WHERE id = ?", neighbour.id); err != nil {
+			log("failed to merge free space: %v", err)
+			return errAddFreeSpace
+		}
+	} else if err != sql.ErrNoRows {
+		log("failed to look up adjacent free space: %v", err)
+		return errAddFreeSpace
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO free_spaces (offset, length, created_at)
+		VALUES (?, ?, datetime('now'))
+	`, start, end-start)
+
+	if err != nil {
+		log("failed to add free space record: %v", err)
+		return errAddFreeSpace
+	}
+
+	return nil
+}
+
+// GetFreeSpaceStats summarises the free list: the number of free bytes, the number of free blocks and the size of the
+// largest block. q is either a *sql.DB or a *sql.Tx.
+func GetFreeSpaceStats(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}) (freeBytes int64, blockCount int, largestBlock int64, err error) {
+	err = q.QueryRow(`
+		SELECT COALESCE(SUM(length), 0), COUNT(*), COALESCE(MAX(length), 0)
+		FROM free_spaces
+	`).Scan(&freeBytes, &blockCount, &largestBlock)
+	return freeBytes, blockCount, largestBlock, err
+}
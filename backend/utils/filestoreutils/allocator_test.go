@@ -0,0 +1,263 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/utils/constants"
+)
+
+func setupAllocatorTest(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	key := make([]byte, constants.KeyLength)
+	db, err := database.Initialize(filepath.Join(dir, "tella.db"), key)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	tvaultPath := filepath.Join(dir, "tvault")
+	if err := os.WriteFile(tvaultPath, make([]byte, constants.TVaultHeaderSize), 0600); err != nil {
+		t.Fatalf("Failed to create TVault: %v", err)
+	}
+	return db.DB, tvaultPath
+}
+
+func withTx(t *testing.T, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func allocate(t *testing.T, db *sql.DB, tvaultPath string, size int64) int64 {
+	t.Helper()
+	var offset int64
+	err := withTx(t, db, func(tx *sql.Tx) (err error) {
+		offset, err = FindSpace(tx, size, tvaultPath)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("FindSpace(%d) failed: %v", size, err)
+	}
+	return offset
+}
+
+func free(t *testing.T, db *sql.DB, offset, length int64) error {
+	t.Helper()
+	return withTx(t, db, func(tx *sql.Tx) error {
+		return AddFreeSpace(tx, offset, length)
+	})
+}
+
+func freeBlocks(t *testing.T, db *sql.DB) []VaultRegion {
+	t.Helper()
+	rows, err := db.Query("SELECT offset, length FROM free_spaces ORDER BY offset")
+	if err != nil {
+		t.Fatalf("Failed to query free spaces: %v", err)
+	}
+	defer rows.Close()
+	var blocks []VaultRegion
+	for rows.Next() {
+		var block VaultRegion
+		if err := rows.Scan(&block.Offset, &block.Length); err != nil {
+			t.Fatalf("Failed to scan free space: %v", err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func TestFindSpaceAppendsAndReserves(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+
+	first := allocate(t, db, tvaultPath, 100)
+	second := allocate(t, db, tvaultPath, 50)
+	if first != constants.TVaultHeaderSize {
+		t.Errorf("First allocation at %d, expected %d", first, constants.TVaultHeaderSize)
+	}
+	// the second allocation must not be handed the same tail, even though nothing was written to the first
+	if second != first+100 {
+		t.Errorf("Second allocation at %d, expected %d", second, first+100)
+	}
+	info, err := os.Stat(tvaultPath)
+	if err != nil {
+		t.Fatalf("Failed to stat TVault: %v", err)
+	}
+	if info.Size() != second+50 {
+		t.Errorf("TVault size %d, expected %d", info.Size(), second+50)
+	}
+}
+
+func TestFindSpaceSplitsBlocks(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	a := allocate(t, db, tvaultPath, 100)
+	allocate(t, db, tvaultPath, 10)
+	if err := free(t, db, a, 100); err != nil {
+		t.Fatalf("AddFreeSpace failed: %v", err)
+	}
+
+	if got := allocate(t, db, tvaultPath, 30); got != a {
+		t.Errorf("Allocation at %d, expected %d", got, a)
+	}
+	blocks := freeBlocks(t, db)
+	if len(blocks) != 1 || blocks[0] != (VaultRegion{Offset: a + 30, Length: 70}) {
+		t.Fatalf("Remainder not kept as free space: %v", blocks)
+	}
+
+	// exact fit consumes the block
+	if got := allocate(t, db, tvaultPath, 70); got != a+30 {
+		t.Errorf("Allocation at %d, expected %d", got, a+30)
+	}
+	if blocks := freeBlocks(t, db); len(blocks) != 0 {
+		t.Errorf("Expected no free space left, got %v", blocks)
+	}
+}
+
+func TestFindSpacePrefersBestFit(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	big := allocate(t, db, tvaultPath, 200)
+	allocate(t, db, tvaultPath, 10)
+	small := allocate(t, db, tvaultPath, 60)
+	allocate(t, db, tvaultPath, 10)
+	free(t, db, big, 200)
+	free(t, db, small, 60)
+
+	if got := allocate(t, db, tvaultPath, 50); got != small {
+		t.Errorf("Allocation at %d, expected best fit at %d", got, small)
+	}
+}
+
+func TestFindSpaceExtendsTailBlock(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	allocate(t, db, tvaultPath, 100)
+	tail := allocate(t, db, tvaultPath, 40)
+	free(t, db, tail, 40)
+
+	if got := allocate(t, db, tvaultPath, 100); got != tail {
+		t.Errorf("Allocation at %d, expected it to reuse the free tail at %d", got, tail)
+	}
+	if blocks := freeBlocks(t, db); len(blocks) != 0 {
+		t.Errorf("Expected no free space left, got %v", blocks)
+	}
+}
+
+func TestAddFreeSpaceCoalesces(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	a := allocate(t, db, tvaultPath, 10)
+	b := allocate(t, db, tvaultPath, 20)
+	c := allocate(t, db, tvaultPath, 30)
+	allocate(t, db, tvaultPath, 10)
+
+	free(t, db, a, 10)
+	free(t, db, c, 30)
+	if blocks := freeBlocks(t, db); len(blocks) != 2 {
+		t.Fatalf("Expected 2 separate free blocks, got %v", blocks)
+	}
+	// freeing the middle merges all three
+	free(t, db, b, 20)
+	blocks := freeBlocks(t, db)
+	if len(blocks) != 1 || blocks[0] != (VaultRegion{Offset: a, Length: 60}) {
+		t.Fatalf("Expected a single merged block, got %v", blocks)
+	}
+
+	freeBytes, blockCount, largest, err := GetFreeSpaceStats(db)
+	if err != nil {
+		t.Fatalf("GetFreeSpaceStats failed: %v", err)
+	}
+	if freeBytes != 60 || blockCount != 1 || largest != 60 {
+		t.Errorf("Unexpected stats: free %d, blocks %d, largest %d", freeBytes, blockCount, largest)
+	}
+}
+
+func TestAddFreeSpaceRejectsDoubleFree(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	a := allocate(t, db, tvaultPath, 100)
+	allocate(t, db, tvaultPath, 10)
+
+	if err := free(t, db, a, 100); err != nil {
+		t.Fatalf("AddFreeSpace failed: %v", err)
+	}
+	if err := free(t, db, a, 100); !errors.Is(err, errDoubleFree) {
+		t.Errorf("Expected errDoubleFree freeing the same region twice, got %v", err)
+	}
+	if err := free(t, db, a+50, 100); !errors.Is(err, errDoubleFree) {
+		t.Errorf("Expected errDoubleFree freeing a partially free region, got %v", err)
+	}
+}
+
+// TestAllocatorNeverHandsOutRegionTwice runs random allocations and frees, checking after every step that no two live
+// allocations overlap, that free space never overlaps a live allocation, and that free blocks are fully coalesced.
+func TestAllocatorNeverHandsOutRegionTwice(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	rng := rand.New(rand.NewSource(1))
+	live := map[int64]int64{}
+
+	for step := 0; step < 500; step++ {
+		if len(live) > 0 && rng.Intn(3) == 0 {
+			// free a random live allocation
+			offsets := make([]int64, 0, len(live))
+			for offset := range live {
+				offsets = append(offsets, offset)
+			}
+			sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+			offset := offsets[rng.Intn(len(offsets))]
+			if err := free(t, db, offset, live[offset]); err != nil {
+				t.Fatalf("Step %d: AddFreeSpace failed: %v", step, err)
+			}
+			delete(live, offset)
+		} else {
+			size := int64(1 + rng.Intn(200))
+			offset := allocate(t, db, tvaultPath, size)
+			if _, ok := live[offset]; ok {
+				t.Fatalf("Step %d: offset %d handed out twice", step, offset)
+			}
+			live[offset] = size
+		}
+
+		info, err := os.Stat(tvaultPath)
+		if err != nil {
+			t.Fatalf("Failed to stat TVault: %v", err)
+		}
+
+		var regions []VaultRegion
+		for offset, length := range live {
+			regions = append(regions, VaultRegion{Offset: offset, Length: length})
+		}
+		blocks := freeBlocks(t, db)
+		for i := 1; i < len(blocks); i++ {
+			if blocks[i].Offset <= blocks[i-1].End() {
+				t.Fatalf("Step %d: free blocks %v and %v overlap or are not coalesced", step, blocks[i-1], blocks[i])
+			}
+		}
+		regions = append(regions, blocks...)
+		sort.Slice(regions, func(i, j int) bool { return regions[i].Offset < regions[j].Offset })
+		cursor := int64(constants.TVaultHeaderSize)
+		for _, region := range regions {
+			if region.Offset < cursor {
+				t.Fatalf("Step %d: region %v overlaps another region", step, region)
+			}
+			cursor = region.End()
+		}
+		if cursor > info.Size() {
+			t.Fatalf("Step %d: region ends at %d, past the end of the TVault (%d)", step, cursor, info.Size())
+		}
+	}
+}
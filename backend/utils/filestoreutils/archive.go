@@ -0,0 +1,149 @@
+package filestoreutils
+
+import (
+	"Tella-Desktop/backend/utils/authutils"
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Formats of export archives
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+	// ArchiveEncrypted is a tar archive in a password-encrypted container, see authutils.NewContainerWriter. It always
+	// includes the manifest, which ImportContainer relies on.
+	ArchiveEncrypted = "encrypted"
+)
+
+// ContainerExtension is the file name extension of password-encrypted containers
+const ContainerExtension = ".tella"
+
+var ErrUnknownArchiveFormat = errors.New("unknown archive format")
+
+// NormalizeArchiveFormat checks an archive format, returning ArchiveZip for an empty one
+func NormalizeArchiveFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return ArchiveZip, nil
+	case ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveEncrypted:
+		return format, nil
+	}
+	return "", ErrUnknownArchiveFormat
+}
+
+// archiveEntry describes a file in an archive
+type archiveEntry struct {
+	name string
+	// size is the exact number of bytes that will be written, which tar needs up front
+	size     int64
+	modified time.Time
+	// comment carries a file's tags and custom metadata, see FileAnnotationComment
+	comment string
+}
+
+// archiveWriter writes the entries of an export archive, one at a time
+type archiveWriter interface {
+	// create starts a new entry, returning the writer for its contents
+	create(entry archiveEntry) (io.Writer, error)
+	// Close writes what remains of the archive, without closing the file underneath
+	Close() error
+}
+
+// newArchiveWriter starts an archive of the given format on w. Encrypted containers are sealed under passphrase, which
+// the other formats ignore.
+func newArchiveWriter(w io.Writer, format, passphrase string) (archiveWriter, error) {
+	switch format {
+	case ArchiveTar:
+		return &tarArchive{tw: tar.NewWriter(w)}, nil
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarArchive{tw: tar.NewWriter(gz), closers: []io.Closer{gz}}, nil
+	case ArchiveEncrypted:
+		container, err := authutils.NewContainerWriter(w, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return &tarArchive{tw: tar.NewWriter(container), closers: []io.Closer{container}}, nil
+	}
+	return &zipArchive{zw: zip.NewWriter(w)}, nil
+}
+
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (z *zipArchive) create(entry archiveEntry) (io.Writer, error) {
+	return z.zw.CreateHeader(&zip.FileHeader{
+		Name:     entry.name,
+		Method:   zip.Deflate,
+		Comment:  entry.comment,
+		Modified: entry.modified,
+	})
+}
+
+func (z *zipArchive) Close() error {
+	return z.zw.Close()
+}
+
+// tarArchiveComment is the PAX record carrying the comment of an entry; tar has no comments of its own
+const tarArchiveComment = "TELLA.comment"
+
+type tarArchive struct {
+	tw *tar.Writer
+	// closers finish the layers under the tar stream, such as compression or encryption, innermost first
+	closers []io.Closer
+}
+
+func (t *tarArchive) create(entry archiveEntry) (io.Writer, error) {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     entry.name,
+		Size:     entry.size,
+		// the permissions of the exported files, for whoever unpacks the archive
+		Mode:    0600,
+		ModTime: entry.modified,
+		Format:  tar.FormatPAX,
+	}
+	if entry.comment != "" {
+		header.PAXRecords = map[string]string{tarArchiveComment: entry.comment}
+	}
+	if err := t.tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	return t.tw, nil
+}
+
+func (t *tarArchive) Close() error {
+	err := t.tw.Close()
+	for _, closer := range t.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// archiveExtension returns the file name extension of an archive format
+func archiveExtension(format string) string {
+	if format == ArchiveEncrypted {
+		return ContainerExtension
+	}
+	return "." + format
+}
+
+// splitExtension splits a file name into its base and its extension, taking the double extension of compressed
+// tarballs as one so that unique names go "export-1.tar.gz" rather than "export.tar-1.gz"
+func splitExtension(fileName string) (string, string) {
+	ext := filepath.Ext(fileName)
+	if strings.EqualFold(ext, ".gz") && strings.EqualFold(filepath.Ext(fileName[:len(fileName)-len(ext)]), ".tar") {
+		ext = fileName[len(fileName)-len(".tar.gz"):]
+	}
+	return fileName[:len(fileName)-len(ext)], ext
+}
@@ -0,0 +1,200 @@
+package filestoreutils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Tella-Desktop/backend/utils/authutils"
+	"Tella-Desktop/backend/utils/constants"
+)
+
+func TestTarGzArchiveKeepsTimesAndManifest(t *testing.T) {
+	received := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	manifest := &Manifest{ExportedAt: "2026-03-02T08:30:00Z", Files: []ManifestEntry{{OriginalName: "notes.txt"}}}
+
+	var buf bytes.Buffer
+	archive, err := newArchiveWriter(&buf, ArchiveTarGz, "")
+	if err != nil {
+		t.Fatalf("newArchiveWriter() failed: %v", err)
+	}
+	w, err := archive.create(archiveEntry{name: "notes.txt", size: 5, modified: received, comment: "Tags: source"})
+	if err != nil {
+		t.Fatalf("create() failed: %v", err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if err := addManifestToArchive(archive, manifest); err != nil {
+		t.Fatalf("addManifestToArchive() failed: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read tar: %v", err)
+		}
+		names = append(names, header.Name)
+		if header.Mode != 0600 {
+			t.Errorf("Entry %s has mode %o, want 600", header.Name, header.Mode)
+		}
+		if header.Name == "notes.txt" {
+			if !header.ModTime.Equal(received) {
+				t.Errorf("Entry %s modified at %v, want %v", header.Name, header.ModTime, received)
+			}
+			if comment := header.PAXRecords[tarArchiveComment]; comment != "Tags: source" {
+				t.Errorf("Entry %s has comment %q, want %q", header.Name, comment, "Tags: source")
+			}
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			t.Fatalf("Failed to read entry %s: %v", header.Name, err)
+		}
+	}
+
+	want := []string{"notes.txt", ManifestJSONName, ManifestCSVName}
+	if len(names) != len(want) {
+		t.Fatalf("Archive has entries %q, want %q", names, want)
+	}
+	for i, name := range names {
+		if name != want[i] {
+			t.Errorf("Entry %d = %q, want %q", i, name, want[i])
+		}
+	}
+}
+
+func TestTarArchiveRejectsShortEntries(t *testing.T) {
+	archive, err := newArchiveWriter(io.Discard, ArchiveTar, "")
+	if err != nil {
+		t.Fatalf("newArchiveWriter() failed: %v", err)
+	}
+	w, err := archive.create(archiveEntry{name: "short.bin", size: 10, modified: time.Now()})
+	if err != nil {
+		t.Fatalf("create() failed: %v", err)
+	}
+	w.Write([]byte("short"))
+	if err := archive.Close(); err == nil {
+		t.Error("Close() succeeded after a short entry")
+	}
+}
+
+func TestNormalizeArchiveFormat(t *testing.T) {
+	for format, want := range map[string]string{"": ArchiveZip, "zip": ArchiveZip, "tar": ArchiveTar, "tar.gz": ArchiveTarGz} {
+		if got, err := NormalizeArchiveFormat(format); err != nil || got != want {
+			t.Errorf("NormalizeArchiveFormat(%q) = %q, %v, want %q", format, got, err, want)
+		}
+	}
+	if _, err := NormalizeArchiveFormat("rar"); err != ErrUnknownArchiveFormat {
+		t.Errorf("NormalizeArchiveFormat(\"rar\") returned %v, want ErrUnknownArchiveFormat", err)
+	}
+}
+
+func TestCreateUniqueFilenameKeepsTarGzExtension(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "export.tar.gz"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := CreateUniqueFilename(dir, "export.tar.gz"), filepath.Join(dir, "export-1.tar.gz"); got != want {
+		t.Errorf("CreateUniqueFilename() = %q, want %q", got, want)
+	}
+}
+
+// appendChunkedTestFile encrypts size random bytes into a file appended to the TVault, returning its ID and the offset
+// of its ciphertext
+func appendChunkedTestFile(t *testing.T, db *sql.DB, tvaultPath, fileUUID string, size int) (int64, int64) {
+	t.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("Failed to generate file: %v", err)
+	}
+	sum := sha256.Sum256(data)
+
+	tvault, err := os.OpenFile(tvaultPath, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open TVault: %v", err)
+	}
+	defer tvault.Close()
+	info, err := tvault.Stat()
+	if err != nil {
+		t.Fatalf("Failed to stat TVault: %v", err)
+	}
+	w, err := authutils.NewChunkedWriter(tvault, GenerateFileKey(fileUUID, make([]byte, constants.KeyLength)), []byte(fileUUID))
+	if err != nil {
+		t.Fatalf("NewChunkedWriter() failed: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Failed to encrypt file: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to seal file: %v", err)
+	}
+
+	length := authutils.ChunkedCiphertextSize(int64(size), authutils.DefaultChunkSize)
+	var fileID int64
+	err = withTx(t, db, func(tx *sql.Tx) (err error) {
+		fileID, err = InsertFileMetadata(tx, fileUUID, fileUUID+".bin", int64(size), "application/octet-stream", 1, info.Size(), length, FormatChunked, hex.EncodeToString(sum[:]))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert file: %v", err)
+	}
+	return fileID, info.Size()
+}
+
+func TestCreateArchiveDiscardsArchiveWithCorruptChunk(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	if _, err := db.Exec("INSERT INTO folders (name) VALUES ('folder')"); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	intact, _ := appendChunkedTestFile(t, db, tvaultPath, "intact-uuid", 1000)
+	corrupt, offset := appendChunkedTestFile(t, db, tvaultPath, "corrupt-uuid", 2*authutils.DefaultChunkSize+1000)
+
+	// flip a byte of the second chunk, so that the entry is cut short after the first chunk was written to it
+	secondChunk := offset + authutils.ChunkedHeaderSize + authutils.DefaultChunkSize + authutils.ChunkOverhead + 100
+	tvault, err := os.OpenFile(tvaultPath, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open TVault: %v", err)
+	}
+	defer tvault.Close()
+	b := make([]byte, 1)
+	if _, err := tvault.ReadAt(b, secondChunk); err != nil {
+		t.Fatalf("Failed to read TVault: %v", err)
+	}
+	b[0] ^= 0xff
+	if _, err := tvault.WriteAt(b, secondChunk); err != nil {
+		t.Fatalf("Failed to corrupt TVault: %v", err)
+	}
+
+	exportDir := t.TempDir()
+	files := []FileInfo{{ID: intact, Name: "intact.bin"}, {ID: corrupt, Name: "corrupt.bin"}}
+	for _, format := range []string{ArchiveZip, ArchiveTar} {
+		path, err := CreateArchive(db, make([]byte, constants.KeyLength), "folder", files, tvault, exportDir, format, "", false, nil)
+		if !errors.Is(err, errWriteArchiveEntry) {
+			t.Errorf("CreateArchive(%s) = %q, %v, want %v", format, path, err, errWriteArchiveEntry)
+		}
+	}
+	if entries, err := os.ReadDir(exportDir); err != nil || len(entries) != 0 {
+		t.Errorf("Export directory holds %v, %v, want the archives discarded", entries, err)
+	}
+}
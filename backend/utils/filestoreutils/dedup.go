@@ -0,0 +1,50 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// FindFileByContent returns the ID of a stored file with the given plaintext hash and size, or 0 if there is none
+func FindFileByContent(tx *sql.Tx, sha256 string, size int64) (int64, error) {
+	var fileID int64
+	err := tx.QueryRow(`
+		SELECT id FROM files
+		WHERE sha256 = ? AND size = ? AND is_deleted = 0
+		ORDER BY id ASC
+		LIMIT 1
+	`, sha256, size).Scan(&fileID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return fileID, err
+}
+
+var errLinkedFile = errors.New("error linking file to stored content")
+
+// InsertLinkedFile adds a file sharing the ciphertext, and thumbnail, of the stored file contentFileID. The new row
+// keeps the UUID the ciphertext is encrypted under in content_uuid, so it decrypts like the original; see
+// RegionReferenced for how the shared region is freed.
+func InsertLinkedFile(tx *sql.Tx, fileUUID, fileName, mimeType string, folderID, contentFileID int64) (int64, error) {
+	result, err := tx.Exec(`
+		INSERT INTO files (
+			uuid, name, size, folder_id, mime_type, offset, length, encryption_format, sha256,
+			content_uuid, blurhash, thumbnail_offset, thumbnail_length,
+			is_deleted, created_at, updated_at
+		)
+		SELECT
+			?, ?, size, ?, ?, offset, length, encryption_format, sha256,
+			COALESCE(content_uuid, uuid), blurhash, thumbnail_offset, thumbnail_length,
+			0, datetime('now'), datetime('now')
+		FROM files
+		WHERE id = ? AND is_deleted = 0
+	`, fileUUID, fileName, folderID, NormalizeMimeType(mimeType), contentFileID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("file to link to not found with ID: %d", contentFileID)
+		return 0, errLinkedFile
+	}
+	return result.LastInsertId()
+}
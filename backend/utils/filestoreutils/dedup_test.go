@@ -0,0 +1,65 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestLinkedFilesShareRegionUntilLastReference(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	if _, err := db.Exec("INSERT INTO folders (name) VALUES ('folder')"); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	offset := allocate(t, db, tvaultPath, 1000)
+	region := VaultRegion{Offset: offset, Length: 1000}
+
+	var originalID, linkedID int64
+	err := withTx(t, db, func(tx *sql.Tx) (err error) {
+		originalID, err = InsertFileMetadata(tx, "original-uuid", "a.jpg", 900, "image/jpeg", 1, offset, 1000, FormatChunked, "abc123")
+		if err != nil {
+			return err
+		}
+		found, err := FindFileByContent(tx, "abc123", 900)
+		if err != nil || found != originalID {
+			t.Fatalf("FindFileByContent() = %d, %v, want %d", found, err, originalID)
+		}
+		linkedID, err = InsertLinkedFile(tx, "linked-uuid", "b.jpg", "image/jpeg", 1, originalID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert files: %v", err)
+	}
+
+	// the linked file decrypts under the original's UUID
+	linked, err := GetFileMetadataByID(db, linkedID)
+	if err != nil {
+		t.Fatalf("Failed to get linked file: %v", err)
+	}
+	if linked.UUID != "original-uuid" || linked.Offset != offset || linked.Length != 1000 || linked.SHA256 != "abc123" {
+		t.Errorf("Linked file metadata = %+v, want the original's content", linked)
+	}
+
+	referenced := func() bool {
+		t.Helper()
+		var result bool
+		withTx(t, db, func(tx *sql.Tx) (err error) {
+			result, err = RegionReferenced(tx, region)
+			return err
+		})
+		return result
+	}
+
+	if _, err := db.Exec("UPDATE files SET is_deleted = 1 WHERE id = ?", originalID); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if !referenced() {
+		t.Errorf("Region unreferenced while the linked file is kept")
+	}
+
+	if _, err := db.Exec("UPDATE files SET is_deleted = 1 WHERE id = ?", linkedID); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if referenced() {
+		t.Errorf("Region still referenced after all files were deleted")
+	}
+}
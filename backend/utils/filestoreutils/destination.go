@@ -0,0 +1,89 @@
+package filestoreutils
+
+import (
+	"Tella-Desktop/backend/utils/config"
+	"Tella-Desktop/backend/utils/diskutils"
+	util "Tella-Desktop/backend/utils/genericutil"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// exportSpaceMargin is kept free on top of the plaintext size of an export, for archive overhead, manifests and
+// signatures
+const exportSpaceMargin = 16 << 20
+
+var (
+	ErrExportDirInvalid     = errors.New("the export destination must be an existing folder")
+	ErrExportDirNotWritable = errors.New("the export destination is not writable")
+	ErrExportDirFull        = errors.New("there is not enough free space at the export destination")
+	ErrExportDirCloudSync   = errors.New("the export destination is inside a folder synced to cloud storage")
+)
+
+var errCreateExportDir = errors.New("failed to create the export folder")
+
+// ExportDir returns the folder an export of requiredBytes of plaintext is written to, after checking it with
+// CheckExportDir: destination if one was chosen for the export, or else the configured export folder, which is created
+// if it doesn't exist yet
+func ExportDir(destination string, requiredBytes int64) (string, error) {
+	conf := config.ReadConfig()
+	dir := destination
+	if dir == "" {
+		dir = conf.GetExportDir()
+		if err := os.MkdirAll(dir, util.USER_ONLY_DIR_PERMS); err != nil {
+			log("failed to create export dir: %v", err)
+			return "", errCreateExportDir
+		}
+	}
+	if err := CheckExportDir(dir, requiredBytes, conf.RefuseCloudSyncExports); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// CheckExportDir checks that an export of requiredBytes of plaintext can be written to dir. With refuseCloudSync,
+// folders synced to cloud storage are refused, see diskutils.InCloudSyncFolder.
+func CheckExportDir(dir string, requiredBytes int64, refuseCloudSync bool) error {
+	if !filepath.IsAbs(dir) {
+		return ErrExportDirInvalid
+	}
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		log("export destination %s is not a folder: %v", dir, err)
+		return ErrExportDirInvalid
+	}
+
+	if refuseCloudSync {
+		synced, err := diskutils.InCloudSyncFolder(dir)
+		if err != nil {
+			log("failed to check whether %s is synced: %v", dir, err)
+			return ErrExportDirInvalid
+		}
+		if synced {
+			return ErrExportDirCloudSync
+		}
+	}
+
+	// permissions alone don't tell, e.g. for read-only mounts, so a file is created to find out
+	probe, err := os.CreateTemp(dir, ".tella-write-check-*")
+	if err != nil {
+		log("export destination %s is not writable: %v", dir, err)
+		return ErrExportDirNotWritable
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	free, err := diskutils.FreeSpace(dir)
+	if errors.Is(err, diskutils.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		log("failed to get free space at %s: %v", dir, err)
+		return nil
+	}
+	if free < requiredBytes+exportSpaceMargin {
+		log("export of %d bytes does not fit the %d bytes free at %s", requiredBytes, free, dir)
+		return ErrExportDirFull
+	}
+	return nil
+}
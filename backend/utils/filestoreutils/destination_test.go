@@ -0,0 +1,29 @@
+package filestoreutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckExportDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckExportDir(dir, 1024, true); err != nil {
+		t.Errorf("CheckExportDir() of a temporary folder = %v, want nil", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("CheckExportDir() left %d files behind", len(entries))
+	}
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, nil, 0600)
+	for _, invalid := range []string{"relative", filepath.Join(dir, "missing"), file} {
+		if err := CheckExportDir(invalid, 0, false); err != ErrExportDirInvalid {
+			t.Errorf("CheckExportDir(%q) = %v, want ErrExportDirInvalid", invalid, err)
+		}
+	}
+
+	if err := CheckExportDir(dir, 1<<62, false); err != ErrExportDirFull {
+		t.Errorf("CheckExportDir() of an export larger than the disk = %v, want ErrExportDirFull", err)
+	}
+}
@@ -1,15 +1,15 @@
 package filestoreutils
 
 import (
-	"Tella-Desktop/backend/utils/authutils"
 	util "Tella-Desktop/backend/utils/genericutil"
+	"Tella-Desktop/backend/utils/authutils"
 	"Tella-Desktop/backend/utils/devlog"
-	"archive/zip"
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,6 +20,14 @@ import (
 
 var log = devlog.Logger("filestoreutils")
 
+// Encryption formats of file ciphertexts stored in the TVault, see column files.encryption_format
+const (
+	// FormatBlob is a single AES-GCM ciphertext of the whole file (authutils.EncryptData)
+	FormatBlob = 0
+	// FormatChunked is the segmented AEAD format (authutils.ChunkedWriter)
+	FormatChunked = 1
+)
+
 // insertFileMetadata adds file metadata to the database
 func InsertFileMetadata(
 	tx *sql.Tx,
@@ -30,14 +38,16 @@ func InsertFileMetadata(
 	folderID int64,
 	offset int64,
 	length int64,
+	format int,
+	sha256 string,
 ) (int64, error) {
 	result, err := tx.Exec(`
 		INSERT INTO files (
-			uuid, name, size, folder_id, mime_type, offset, length, 
+			uuid, name, size, folder_id, mime_type, offset, length, encryption_format, sha256,
 			is_deleted, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, 0, datetime('now'), datetime('now'))
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, datetime('now'), datetime('now'))
 	`,
-		fileUUID, fileName, size, folderID, mimeType, offset, length,
+		fileUUID, fileName, size, folderID, NormalizeMimeType(mimeType), offset, length, format, sha256,
 	)
 
 	if err != nil {
@@ -47,34 +57,10 @@ func InsertFileMetadata(
 	return result.LastInsertId()
 }
 
-// findSpace looks for a suitable free space or returns the end of the file
-func FindSpace(tx *sql.Tx, size int64, tvaultPath string) (int64, error) {
-	// First try to find a free space that fits
-	var freeSpaceID, offset int64
-	err := tx.QueryRow(`
-		SELECT id, offset FROM free_spaces 
-		WHERE length >= ? 
-		ORDER BY length ASC LIMIT 1
-	`, size).Scan(&freeSpaceID, &offset)
-
-	if err == nil {
-		// Found a free space, remove or resize it
-		_, err = tx.Exec("DELETE FROM free_spaces WHERE id = ?", freeSpaceID)
-		if err != nil {
-			return 0, err
-		}
-		return offset, nil
-	} else if err != sql.ErrNoRows {
-		return 0, err
-	}
-
-	// No suitable free space found, append to end of file
-	file, err := os.Stat(tvaultPath)
-	if err != nil {
-		return 0, err
-	}
-
-	return file.Size(), nil
+// NormalizeMimeType lowercases a mimetype as claimed by a sender, since mimetypes are case-insensitive, so that search
+// filters can match stored mimetypes exactly
+func NormalizeMimeType(mimeType string) string {
+	return strings.ToLower(strings.TrimSpace(mimeType))
 }
 
 // GenerateFileKey generates a file-specific encryption key
@@ -92,8 +78,7 @@ func CreateUniqueFilename(dir, fileName string) string {
 		return originalPath
 	}
 
-	ext := filepath.Ext(fileName)
-	baseName := fileName[:len(fileName)-len(ext)]
+	baseName, ext := splitExtension(fileName)
 
 	counter := 1
 	for {
@@ -152,6 +137,8 @@ type FileInfo struct {
 	MimeType  string `json:"mimeType"`
 	Timestamp string `json:"timestamp"`
 	Size      int64  `json:"size"`
+	// SHA256 is the hex encoded hash of the plaintext, empty for files stored before hashes were recorded
+	SHA256 string `json:"sha256"`
 }
 
 // FolderInfo represents basic folder information
@@ -162,28 +149,39 @@ type FolderInfo struct {
 }
 
 // FileMetadata represents complete file metadata including encryption details
+
 type FileMetadata struct {
-	ID        int64
-	UUID      string
-	Name      string
-	Size      int64
-	MimeType  string
-	FolderID  int64
-	Offset    int64
-	Length    int64
-	CreatedAt time.Time
+	ID int64
+	// UUID is the identifier the ciphertext is encrypted under: the file's own UUID, or for a file sharing the
+	// ciphertext of an identical file, that file's UUID
+	UUID     string
+	Name     string
+	Size     int64
+	MimeType string
+	FolderID int64
+	Offset   int64
+	Length   int64
+	Format   int
+	SHA256   string
+	// ThumbnailOffset and ThumbnailLength locate the encrypted thumbnail of an image, both are 0 if there is none
+	ThumbnailOffset int64
+	ThumbnailLength int64
+	CreatedAt       time.Time
 }
 
 var errGetMetadata = errors.New("error getting file metadata")
 // GetFileMetadataByID retrieves file metadata from database by ID
 func GetFileMetadataByID(db *sql.DB, id int64) (*FileMetadata, error) {
 	var metadata FileMetadata
+	var createdAt string
 
 	err := db.QueryRow(`
-		SELECT uuid, name, mime_type, offset, length
+		SELECT COALESCE(content_uuid, uuid), name, size, folder_id, mime_type, offset, length, encryption_format, COALESCE(sha256, ''), created_at
 		FROM files
 		WHERE id = ? AND is_deleted = 0
-	`, id).Scan(&metadata.UUID, &metadata.Name, &metadata.MimeType, &metadata.Offset, &metadata.Length)
+	`, id).Scan(&metadata.UUID, &metadata.Name, &metadata.Size, &metadata.FolderID, &metadata.MimeType, &metadata.Offset, &metadata.Length, &metadata.Format, &metadata.SHA256, &createdAt)
+	metadata.ID = id
+	metadata.CreatedAt = parseCreatedAt(createdAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -231,7 +229,7 @@ func GetSelectedFilesInFolder(db *sql.DB, folderID int64, fileIDs []int64) ([]Fi
 	// uses a static prepared sql statement to get all relevant files. step 2 does a Go-based filtering on the returned db results.
 
 	// step 1: get all the files for the given folder marked as not deleted
-	filesInFolderQuery := `SELECT id, name, mime_type, created_at, size 
+	filesInFolderQuery := `SELECT id, name, mime_type, created_at, size, COALESCE(sha256, '')
 	FROM files 
 	WHERE folder_id = ? AND is_deleted = 0 
 	ORDER BY created_at DESC`
@@ -247,7 +245,7 @@ func GetSelectedFilesInFolder(db *sql.DB, folderID int64, fileIDs []int64) ([]Fi
 	var files []FileInfo
 	for rows.Next() {
 		var file FileInfo
-		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size); err != nil {
+		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Timestamp, &file.Size, &file.SHA256); err != nil {
 			log("failed to scan file: %w", err)
 			return nil, errGetSelected
 		}
@@ -270,48 +268,75 @@ func GetSelectedFilesInFolder(db *sql.DB, folderID int64, fileIDs []int64) ([]Fi
 }
 
 var errDecrypt = errors.New("error decrypting file")
-func decryptAndGetFilename(db *sql.DB, fid int64, dbKey []byte, tvault *os.File) ([]byte, string, error) {
+// openAndGetFilename opens a decrypting reader for the file and determines the name it should be exported under. The
+// returned reader verifies the file's recorded SHA-256 once it has been read to the end. The caller must Close it.
+func openAndGetFilename(db *sql.DB, fid int64, dbKey []byte, tvault *os.File) (io.ReadCloser, string, error) {
 	metadata, err := GetFileMetadataByID(db, fid)
 	if err != nil {
 		log("error getting filemetadata %v", err)
 		return nil, "", errDecrypt
 	}
 
-	// Read encrypted data from TVault
-	encryptedData := make([]byte, metadata.Length)
-	_, err = tvault.ReadAt(encryptedData, metadata.Offset)
+	reader, err := OpenFileReader(metadata, dbKey, tvault)
 	if err != nil {
-		log("failed to read file from TVault: %w", err)
+		log("failed to decrypt file: %w", err)
 		return nil, "", errDecrypt
 	}
-	defer util.SecureZeroMemory(encryptedData)
 
-	// Generate file key and decrypt
-	fileKey := GenerateFileKey(metadata.UUID, dbKey)
-	decryptedData, err := authutils.DecryptData(encryptedData, fileKey)
-	if err != nil {
+	// only the first bytes of the file are needed to infer its mimetype
+	head := make([]byte, MimeDetectionSize)
+	defer util.SecureZeroMemory(head)
+	n, err := reader.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		reader.Close()
 		log("failed to decrypt file: %w", err)
 		return nil, "", errDecrypt
 	}
 
-	inferredMIME := mimetype.Detect(decryptedData)
+	inferredMIME := mimetype.Detect(head[:n])
 	var detectedMIME string
 	if !inferredMIME.Is("application/octet-stream") {
 		detectedMIME = inferredMIME.String()
 	}
 	// Ensure filename has proper extension based on mimetype
-	fileName := EnsureFileExtension(metadata.Name, detectedMIME, metadata.MimeType)
-	return decryptedData, fileName, nil
+	fileName := EnsureFileExtension(SafeName(metadata.Name), detectedMIME, metadata.MimeType)
+	return NewVerifyingReader(reader, metadata.SHA256), fileName, nil
+}
+
+// ExportedFile describes a file written by an export
+type ExportedFile struct {
+	FileID int64
+	// Path is where a loose export was written, and empty for files exported into an archive
+	Path string
+	// Name is the name of the file in the export: its file name for loose exports, its entry name in archives
+	Name string
+	Size int64
+	// SHA256 is the hex encoded hash of the bytes written
+	SHA256 string
+}
+
+// exportPlaintext streams a file's plaintext to dst, hashing what is written and reporting it to tracker
+func exportPlaintext(dst io.Writer, reader io.Reader, fileID int64, name string, tracker ExportTracker) (*ExportedFile, error) {
+	if tracker != nil {
+		tracker.FileStarted(name)
+	}
+	hasher := sha256.New()
+	size, err := CopyDecrypted(trackWriter(io.MultiWriter(dst, hasher), tracker), reader)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportedFile{FileID: fileID, Name: name, Size: size, SHA256: fmt.Sprintf("%x", hasher.Sum(nil))}, nil
 }
 
 var errExportFile = errors.New("error exporting file")
-// ExportSingleFile exports a single file to the specified directory
-func ExportSingleFile(db *sql.DB, dbKey []byte, id int64, tvault *os.File, exportDir string) (string, error) {
-	decryptedData, fileName, err := decryptAndGetFilename(db, id, dbKey, tvault)
+// ExportSingleFile exports a single file to the specified directory, reporting its progress to tracker. A file whose
+// export is cancelled is removed again, and ErrExportCancelled returned.
+func ExportSingleFile(db *sql.DB, dbKey []byte, id int64, tvault *os.File, exportDir string, tracker ExportTracker) (*ExportedFile, error) {
+	reader, fileName, err := openAndGetFilename(db, id, dbKey, tvault)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer util.SecureZeroMemory(decryptedData)
+	defer reader.Close()
 
 	// Create unique filename in export directory
 	exportPath := CreateUniqueFilename(exportDir, fileName)
@@ -320,16 +345,23 @@ func ExportSingleFile(db *sql.DB, dbKey []byte, id int64, tvault *os.File, expor
 	exportFile, err := util.NarrowCreate(exportPath)
 	if err != nil {
 		log("failed to create export file: %w", err)
-		return "", errExportFile
+		return nil, errExportFile
 	}
 	defer exportFile.Close()
 
-	// Write decrypted data to export file
-	_, err = exportFile.Write(decryptedData)
+	// Stream decrypted data to export file
+	exported, err := exportPlaintext(exportFile, reader, id, filepath.Base(exportPath), tracker)
 	if err != nil {
 		log("failed to write to export file: %w", err)
-		return "", errExportFile
+		// don't leave a partially decrypted file behind
+		exportFile.Close()
+		os.Remove(exportPath)
+		if errors.Is(err, ErrExportCancelled) {
+			return nil, err
+		}
+		return nil, errExportFile
 	}
+	exported.Path = exportPath
 
 	// Set appropriate file permissions
 	err = os.Chmod(exportPath, util.USER_ONLY_FILE_PERMS)
@@ -337,70 +369,179 @@ func ExportSingleFile(db *sql.DB, dbKey []byte, id int64, tvault *os.File, expor
 		log("Failed to set file permissions for %s: %v", exportPath, err)
 	}
 
-	return exportPath, nil
+	return exported, nil
 }
 
-var errCreateZip = errors.New("error creating zip")
-// CreateZipFile creates a ZIP file containing the specified files
-func CreateZipFile(db *sql.DB, dbKey []byte, folderName string, files []FileInfo, tvault *os.File, exportDir string) (string, error) {
-	// Create unique ZIP filename
-	zipFileName := fmt.Sprintf("%s.zip", folderName)
-	zipPath := CreateUniqueFilename(exportDir, zipFileName)
+var errCreateArchive = errors.New("error creating archive")
+// CreateZipFile creates a ZIP file containing the specified files, see CreateArchive
+func CreateZipFile(db *sql.DB, dbKey []byte, folderName string, files []FileInfo, tvault *os.File, exportDir string, withManifest bool, tracker ExportTracker) (string, error) {
+	return CreateArchive(db, dbKey, folderName, files, tvault, exportDir, ArchiveZip, "", withManifest, tracker)
+}
 
-	// Create ZIP file
-	zipFile, err := util.NarrowCreate(zipPath)
+// CreateArchive creates an archive of the given format containing the specified files, along with a manifest of them
+// if withManifest is set, reporting its progress to tracker. Entries carry the time the files were received. Encrypted
+// containers are sealed under passphrase and always carry the manifest. An archive whose export is cancelled is
+// removed again, and ErrExportCancelled returned.
+func CreateArchive(db *sql.DB, dbKey []byte, folderName string, files []FileInfo, tvault *os.File, exportDir, format, passphrase string, withManifest bool, tracker ExportTracker) (string, error) {
+	format, err := NormalizeArchiveFormat(format)
 	if err != nil {
-		log("failed to create ZIP file: %w", err)
-		return "", errCreateZip
+		return "", err
+	}
+	if format == ArchiveEncrypted {
+		withManifest = true
 	}
-	defer zipFile.Close()
 
-	// Create ZIP writer
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	// Create unique archive filename
+	archivePath := CreateUniqueFilename(exportDir, folderName+archiveExtension(format))
+
+	// Create archive file
+	archiveFile, err := util.NarrowCreate(archivePath)
+	if err != nil {
+		log("failed to create archive file: %w", err)
+		return "", errCreateArchive
+	}
+	defer archiveFile.Close()
 
-	// Add each file to ZIP
+	archive, err := newArchiveWriter(archiveFile, format, passphrase)
+	if err != nil {
+		log("failed to start archive: %v", err)
+		archiveFile.Close()
+		os.Remove(archivePath)
+		if errors.Is(err, authutils.ErrWeakPassphrase) {
+			return "", err
+		}
+		return "", errCreateArchive
+	}
+
+	// discard closes and removes the archive, which can't be handed out
+	discard := func() {
+		archive.Close()
+		archiveFile.Close()
+		os.Remove(archivePath)
+	}
+
+	// Add each file to the archive
+	var exported []ExportedFile
+	entryNames := map[string]bool{}
 	for _, file := range files {
-		err := AddFileToZip(db, dbKey, zipWriter, file, tvault)
+		if exportCancelled(tracker) {
+			discard()
+			return "", ErrExportCancelled
+		}
+		entry, err := addFileToArchive(db, dbKey, archive, file, tvault, entryNames, tracker)
+		if errors.Is(err, ErrExportCancelled) {
+			log("Export cancelled, discarding archive")
+			discard()
+			return "", err
+		}
+		if tracker != nil {
+			tracker.FileDone()
+		}
+		if errors.Is(err, ErrHashMismatch) || errors.Is(err, errWriteArchiveEntry) {
+			// the entry has already been written, at least in part, so the archive can't be handed out
+			log("Failed to write file '%s' to archive, discarding archive: %v", file.Name, err)
+			discard()
+			return "", err
+		}
 		if err != nil {
-			log("Failed to add file '%s' to ZIP: %v", file.Name, err)
+			log("Failed to add file '%s' to archive: %v", file.Name, err)
 			continue // Continue with other files
 		}
+		exported = append(exported, *entry)
+	}
+
+	if exportCancelled(tracker) {
+		discard()
+		return "", ErrExportCancelled
+	}
+	if withManifest {
+		manifest, err := BuildManifest(db, exported)
+		if err == nil {
+			err = addManifestToArchive(archive, manifest)
+		}
+		if err != nil {
+			log("Failed to add manifest to archive: %v", err)
+			discard()
+			return "", errCreateArchive
+		}
+	}
+	// a tar entry left short by a failed file makes every later write fail, which surfaces here at the latest
+	if err := archive.Close(); err != nil {
+		log("Failed to finish archive: %v", err)
+		discard()
+		return "", errCreateArchive
 	}
 
 	// Set appropriate file permissions
-	if err := os.Chmod(zipPath, util.USER_ONLY_FILE_PERMS); err != nil {
-		log("Failed to set ZIP file permissions: %v", err)
+	if err := os.Chmod(archivePath, util.USER_ONLY_FILE_PERMS); err != nil {
+		log("Failed to set archive file permissions: %v", err)
 	}
 
-	return zipPath, nil
+	return archivePath, nil
 }
 
-var errAddFileZip = errors.New("error adding file to zip")
-// AddFileToZip adds a single file to an existing ZIP writer
-func AddFileToZip(db *sql.DB, dbKey []byte, zipWriter *zip.Writer, file FileInfo, tvault *os.File) error {
-	decryptedData, fileName, err := decryptAndGetFilename(db, file.ID, dbKey, tvault)
+var errAddFileArchive = errors.New("error adding file to archive")
+
+// errWriteArchiveEntry is returned once a file failed after its archive entry was created, leaving the entry truncated
+var errWriteArchiveEntry = errors.New("error writing file data to archive")
+
+// uniqueEntryName returns fileName, or fileName with a counter before its extension if an entry of that name was taken
+// already, and marks the name it returns as taken
+func uniqueEntryName(taken map[string]bool, fileName string) string {
+	name := fileName
+	ext := filepath.Ext(fileName)
+	for counter := 1; taken[name]; counter++ {
+		name = fmt.Sprintf("%s-%d%s", fileName[:len(fileName)-len(ext)], counter, ext)
+	}
+	taken[name] = true
+	return name
+}
+
+// addFileToArchive adds a single file to an archive being written. entryNames holds the names of the entries written
+// so far, so that files of the same name don't end up as entries of the same name. Progress is reported to tracker.
+func addFileToArchive(db *sql.DB, dbKey []byte, archive archiveWriter, file FileInfo, tvault *os.File, entryNames map[string]bool, tracker ExportTracker) (*ExportedFile, error) {
+	metadata, err := GetFileMetadataByID(db, file.ID)
 	if err != nil {
-		log("error adding file to zip %v", err)
-		return errAddFileZip
+		return nil, errAddFileArchive
+	}
+	// the entry comment carries the file's tags and custom metadata
+	comment, err := FileAnnotationComment(db, file.ID)
+	if err != nil {
+		log("failed to get annotations of file %d: %v", file.ID, err)
+		return nil, errAddFileArchive
 	}
-	defer util.SecureZeroMemory(decryptedData)
 
-	// Create file in ZIP
-	fileWriter, err := zipWriter.Create(fileName)
+	reader, fileName, err := openAndGetFilename(db, file.ID, dbKey, tvault)
+	if err != nil {
+		log("error adding file to archive %v", err)
+		return nil, errAddFileArchive
+	}
+	defer reader.Close()
+	fileName = uniqueEntryName(entryNames, fileName)
+
+	// Create file in the archive
+	fileWriter, err := archive.create(archiveEntry{
+		name:     fileName,
+		size:     metadata.Size,
+		modified: metadata.CreatedAt,
+		comment:  comment,
+	})
 	if err != nil {
-		log("failed to create file in ZIP: %w", err)
-		return errAddFileZip
+		log("failed to create file in archive: %w", err)
+		return nil, errAddFileArchive
 	}
 
-	// Write decrypted data to ZIP entry
-	_, err = fileWriter.Write(decryptedData)
+	// Stream decrypted data into the archive entry
+	exported, err := exportPlaintext(fileWriter, reader, file.ID, fileName, tracker)
 	if err != nil {
-		log("failed to write file data to ZIP: %w", err)
-		return errAddFileZip
+		log("failed to write file data to archive: %w", err)
+		if errors.Is(err, ErrHashMismatch) || errors.Is(err, ErrExportCancelled) {
+			return nil, err
+		}
+		return nil, errWriteArchiveEntry
 	}
 
-	return nil
+	return exported, nil
 }
 
 // RecordTempFile records a temporary file in the database for cleanup
@@ -414,6 +555,7 @@ func RecordTempFile(db *sql.DB, fileID int64, tempPath string) error {
 }
 
 var errOverwriteData = errors.New("error overwriting data")
+const overwriteBufferSize = 1 << 20
 // Delete files
 func SecurelyOverwriteFileData(tvaultPath string, offset, length int64) error {
 	file, err := os.OpenFile(tvaultPath, os.O_WRONLY, util.USER_ONLY_FILE_PERMS)
@@ -423,18 +565,20 @@ func SecurelyOverwriteFileData(tvaultPath string, offset, length int64) error {
 	}
 	defer file.Close()
 
-	// Generate random data to overwrite the file content
-	randomData := make([]byte, length)
-	if _, err := rand.Read(randomData); err != nil {
-		log("failed to generate random data: %v", err)
-		return errOverwriteData
-	}
-
-	// Overwrite the file data at the specified offset
-	_, err = file.WriteAt(randomData, offset)
-	if err != nil {
-		log("failed to overwrite file data: %v", err)
-		return errOverwriteData
+	// Overwrite the file data at the specified offset with random data, one buffer at a time so that overwriting
+	// large files doesn't require a buffer the size of the file
+	randomData := make([]byte, min(length, overwriteBufferSize))
+	for written := int64(0); written < length; {
+		n := min(length-written, int64(len(randomData)))
+		if _, err := rand.Read(randomData[:n]); err != nil {
+			log("failed to generate random data: %v", err)
+			return errOverwriteData
+		}
+		if _, err := file.WriteAt(randomData[:n], offset+written); err != nil {
+			log("failed to overwrite file data: %v", err)
+			return errOverwriteData
+		}
+		written += n
 	}
 
 	// Force write to disk
@@ -446,21 +590,6 @@ func SecurelyOverwriteFileData(tvaultPath string, offset, length int64) error {
 	return nil
 }
 
-// AddFreeSpace records a new free space area in the database
-func AddFreeSpace(tx *sql.Tx, offset, length int64) error {
-	_, err := tx.Exec(`
-		INSERT INTO free_spaces (offset, length, created_at)
-		VALUES (?, ?, datetime('now'))
-	`, offset, length)
-
-	if err != nil {
-		log("failed to add free space record: %v", err)
-		return fmt.Errorf("failed to record free space")
-	}
-
-	return nil
-}
-
 var errGetFileMetadataDeletion = errors.New("error getting file metadata for deletion")
 // GetFileMetadataForDeletion retrieves file metadata needed for deletion
 func GetFileMetadataForDeletion(tx *sql.Tx, ids []int64) ([]FileMetadata, error) {
@@ -470,9 +599,10 @@ func GetFileMetadataForDeletion(tx *sql.Tx, ids []int64) ([]FileMetadata, error)
 	}
 
 	metadataQuery := `
-		SELECT uuid, name, size, folder_id, offset, length, created_at 
+		SELECT uuid, name, size, folder_id, offset, length, created_at,
+			COALESCE(thumbnail_offset, 0), COALESCE(thumbnail_length, 0)
 		FROM files 
-		WHERE id = ? AND is_deleted = 0
+		WHERE id = ? AND (is_deleted = 0 OR id IN (SELECT file_id FROM trashed_files))
 	`
 
 	// NOTE: we iteratively execute the static sql query to eliminate SQLi risk from dynamic query construction
@@ -488,6 +618,7 @@ func GetFileMetadataForDeletion(tx *sql.Tx, ids []int64) ([]FileMetadata, error)
 			&metadata.UUID, &metadata.Name,
 			&metadata.Size, &metadata.FolderID, &metadata.Offset,
 			&metadata.Length, &createdAtStr,
+			&metadata.ThumbnailOffset, &metadata.ThumbnailLength,
 		)
 
 		// TODO cblgh(2026-02-09): decide how best to handle these errors now that we're iterating; terminating too early
@@ -499,22 +630,22 @@ func GetFileMetadataForDeletion(tx *sql.Tx, ids []int64) ([]FileMetadata, error)
 			log("failed to query file metadata: %v", err)
 		}
 
-		// Parse timestamp - try RFC3339 first, then fallback to SQLite format
-		timeFormats := []string{time.RFC3339, "2006-01-02 15:04:05"}
-		var createdAt time.Time
-		for _, timeFmt := range timeFormats {
-			createdAt, err = time.Parse(timeFmt, createdAtStr)
-			if err == nil {
-				break
-			}
-		}
-		if createdAt.IsZero() {
-			createdAt = time.Now()
-		}
-		metadata.CreatedAt = createdAt
+		metadata.CreatedAt = parseCreatedAt(createdAtStr)
 
 		filesMetadata = append(filesMetadata, metadata)
 	}
 
 	return filesMetadata, nil
 }
+
+// parseCreatedAt parses the created_at timestamp of a file, which the driver returns either as RFC 3339 or in SQLite's
+// own format, falling back to the current time
+func parseCreatedAt(value string) time.Time {
+	// Parse timestamp - try RFC3339 first, then fallback to SQLite format
+	for _, timeFmt := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+		if createdAt, err := time.Parse(timeFmt, value); err == nil {
+			return createdAt
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,253 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	util "Tella-Desktop/backend/utils/genericutil"
+)
+
+// Names of the manifest entries in archives; loose exports get sidecar files named after them
+const (
+	ManifestJSONName = "manifest.json"
+	ManifestCSVName  = "manifest.csv"
+)
+
+// Manifest lists the files of an export with their hashes and provenance, so that recipients can check that the
+// material they received is complete and unaltered
+type Manifest struct {
+	// ExportedAt is an RFC 3339 timestamp in UTC
+	ExportedAt string          `json:"exportedAt"`
+	Files      []ManifestEntry `json:"files"`
+}
+
+type ManifestEntry struct {
+	OriginalName string `json:"originalName"`
+	ExportedName string `json:"exportedName"`
+	Size         int64  `json:"size"`
+	// SHA256 is the hex encoded hash of the exported bytes
+	SHA256   string `json:"sha256"`
+	MimeType string `json:"mimeType"`
+	// ReceivedAt is when the file was stored in the vault
+	ReceivedAt string `json:"receivedAt"`
+	// Folder is the path of the file's folder, with folder names separated by "/"
+	Folder string `json:"folder"`
+	// TransferTitle is the title of the transfer the file was received in, empty for files that didn't arrive by
+	// transfer
+	TransferTitle string `json:"transferTitle"`
+	// Tags and Metadata are the tags and custom metadata of the file, FolderTags and FolderMetadata those of its folder
+	Tags           []string          `json:"tags"`
+	Metadata       map[string]string `json:"metadata"`
+	FolderTags     []string          `json:"folderTags"`
+	FolderMetadata map[string]string `json:"folderMetadata"`
+}
+
+var manifestCSVHeader = []string{
+	"original_name", "exported_name", "size", "sha256", "mime_type", "received_at", "folder", "transfer_title", "tags",
+	"metadata", "folder_tags", "folder_metadata",
+}
+
+// folderAnnotations are the tags and custom metadata of a folder, looked up once per manifest
+type folderAnnotations struct {
+	tags     []string
+	metadata map[string]string
+}
+
+// BuildManifest describes the exported files from their records in the database
+func BuildManifest(db *sql.DB, exported []ExportedFile) (*Manifest, error) {
+	folders, err := getFolderPaths(db)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{ExportedAt: time.Now().UTC().Format(time.RFC3339), Files: []ManifestEntry{}}
+	folderAnnotationsByID := map[int64]folderAnnotations{}
+	for _, file := range exported {
+		entry := ManifestEntry{ExportedName: file.Name, Size: file.Size, SHA256: file.SHA256}
+		var folderID int64
+		err := db.QueryRow(`
+			SELECT name, mime_type, created_at, folder_id, COALESCE(transfer_title, '')
+			FROM files
+			WHERE id = ?
+		`, file.FileID).Scan(&entry.OriginalName, &entry.MimeType, &entry.ReceivedAt, &folderID, &entry.TransferTitle)
+		if err != nil {
+			return nil, err
+		}
+		entry.Folder = folders[folderID]
+		if entry.Tags, err = AnnotatedFiles.GetTags(db, file.FileID); err != nil {
+			return nil, err
+		}
+		if entry.Metadata, err = AnnotatedFiles.GetCustomMetadata(db, file.FileID); err != nil {
+			return nil, err
+		}
+		annotations, ok := folderAnnotationsByID[folderID]
+		if !ok {
+			if annotations.tags, err = AnnotatedFolders.GetTags(db, folderID); err != nil {
+				return nil, err
+			}
+			if annotations.metadata, err = AnnotatedFolders.GetCustomMetadata(db, folderID); err != nil {
+				return nil, err
+			}
+			folderAnnotationsByID[folderID] = annotations
+		}
+		entry.FolderTags, entry.FolderMetadata = annotations.tags, annotations.metadata
+		manifest.Files = append(manifest.Files, entry)
+	}
+	return manifest, nil
+}
+
+// getFolderPaths returns the path of every folder by ID
+func getFolderPaths(db *sql.DB) (map[int64]string, error) {
+	rows, err := db.Query("SELECT id, name, COALESCE(parent_id, 0) FROM folders")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type folder struct {
+		name     string
+		parentID int64
+	}
+	folders := map[int64]folder{}
+	for rows.Next() {
+		var id int64
+		var f folder
+		if err := rows.Scan(&id, &f.name, &f.parentID); err != nil {
+			return nil, err
+		}
+		folders[id] = f
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	paths := make(map[int64]string, len(folders))
+	for id := range folders {
+		var names []string
+		// the number of steps is bounded, so that a corrupted parent cycle can't loop forever
+		for current, steps := id, 0; current != 0 && steps <= len(folders); steps++ {
+			f, ok := folders[current]
+			if !ok {
+				break
+			}
+			names = append([]string{f.name}, names...)
+			current = f.parentID
+		}
+		paths[id] = strings.Join(names, "/")
+	}
+	return paths, nil
+}
+
+// WriteJSON writes the manifest as indented JSON
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// WriteCSV writes the manifest as CSV with a header row, one row per file
+func (m *Manifest) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(manifestCSVHeader); err != nil {
+		return err
+	}
+	for _, entry := range m.Files {
+		record := []string{
+			csvText(entry.OriginalName), csvText(entry.ExportedName), fmt.Sprint(entry.Size), entry.SHA256,
+			csvText(entry.MimeType), entry.ReceivedAt, csvText(entry.Folder), csvText(entry.TransferTitle),
+			csvText(strings.Join(entry.Tags, "; ")), csvText(csvMetadata(entry.Metadata)),
+			csvText(strings.Join(entry.FolderTags, "; ")), csvText(csvMetadata(entry.FolderMetadata)),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvMetadata joins custom metadata into a single column as "key=value" pairs sorted by key
+func csvMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return strings.ToLower(pairs[i]) < strings.ToLower(pairs[j]) })
+	return strings.Join(pairs, "; ")
+}
+
+// csvText escapes values that spreadsheet applications would otherwise run as formulas. Names come from senders, so a
+// file named "=HYPERLINK(...)" must not turn into a live formula when the manifest is opened. The JSON manifest keeps
+// the values as they are.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// addManifestToArchive adds the manifest to an archive as a JSON and a CSV entry
+func addManifestToArchive(archive archiveWriter, manifest *Manifest) error {
+	exportedAt, err := time.Parse(time.RFC3339, manifest.ExportedAt)
+	if err != nil {
+		return err
+	}
+	for _, entry := range []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{ManifestJSONName, manifest.WriteJSON},
+		{ManifestCSVName, manifest.WriteCSV},
+	} {
+		// written out first, as tar entries need their size up front
+		var content bytes.Buffer
+		if err := entry.write(&content); err != nil {
+			return err
+		}
+		w, err := archive.create(archiveEntry{name: entry.name, size: int64(content.Len()), modified: exportedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(content.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteManifestSidecars writes the manifest of a loose export next to the exported files, returning the paths of the
+// JSON and the CSV file
+func WriteManifestSidecars(manifest *Manifest, exportDir string) ([]string, error) {
+	var paths []string
+	for _, sidecar := range []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{ManifestJSONName, manifest.WriteJSON},
+		{ManifestCSVName, manifest.WriteCSV},
+	} {
+		path := CreateUniqueFilename(exportDir, sidecar.name)
+		file, err := util.NarrowCreate(path)
+		if err != nil {
+			return paths, err
+		}
+		err = sidecar.write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
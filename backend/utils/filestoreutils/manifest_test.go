@@ -0,0 +1,58 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestManifestCSVEscapesFormulas(t *testing.T) {
+	manifest := &Manifest{Files: []ManifestEntry{{
+		OriginalName:  "=HYPERLINK(\"http://example.com\")",
+		ExportedName:  "report, final.pdf",
+		Size:          1234,
+		SHA256:        "abc123",
+		MimeType:      "application/pdf",
+		ReceivedAt:    "2026-03-01T10:00:00Z",
+		Folder:        "Received Files/-case",
+		TransferTitle: "@sources",
+		Tags:          []string{"evidence", "urgent"},
+		Metadata:      map[string]string{"location": "Kyiv", "Case": "=12"},
+		FolderTags:    []string{"-interviews"},
+	}}}
+
+	var buf bytes.Buffer
+	if err := manifest.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	if len(records) != 2 || len(records[1]) != len(manifestCSVHeader) {
+		t.Fatalf("CSV has records %q, want a header and one row", records)
+	}
+
+	want := []string{
+		"'=HYPERLINK(\"http://example.com\")", "report, final.pdf", "1234", "abc123", "application/pdf",
+		"2026-03-01T10:00:00Z", "Received Files/-case", "'@sources", "evidence; urgent", "Case==12; location=Kyiv",
+		"'-interviews", "",
+	}
+	for i, value := range records[1] {
+		if value != want[i] {
+			t.Errorf("Column %s = %q, want %q", manifestCSVHeader[i], value, want[i])
+		}
+	}
+}
+
+func TestUniqueEntryName(t *testing.T) {
+	taken := map[string]bool{}
+	for _, want := range []string{"photo.jpg", "photo-1.jpg", "photo-2.jpg"} {
+		if got := uniqueEntryName(taken, "photo.jpg"); got != want {
+			t.Errorf("uniqueEntryName() = %q, want %q", got, want)
+		}
+	}
+	if got := uniqueEntryName(taken, "notes"); got != "notes" {
+		t.Errorf("uniqueEntryName() = %q, want %q", got, "notes")
+	}
+}
@@ -0,0 +1,23 @@
+package filestoreutils
+
+import "strings"
+
+// ValidName reports whether a file or folder name can be stored. Exports turn names into file names and archive
+// entries, so a name must not be empty, "." or "..", or contain path separators of any platform.
+func ValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// SafeName returns the last element of a name for writing it to disk or into an archive, or storing it. Names received
+// from senders or read from containers, or stored before names were validated, may still look like paths, and must
+// not escape the export folder.
+func SafeName(name string) string {
+	if i := strings.LastIndexAny(name, "/\\"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.ReplaceAll(name, "\x00", "")
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
@@ -0,0 +1,36 @@
+package filestoreutils
+
+import "testing"
+
+func TestValidName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"report.pdf":  true,
+		"Case 12":     true,
+		"..hidden":    true,
+		"":            false,
+		".":           false,
+		"..":          false,
+		"../../x":     false,
+		"a/b":         false,
+		"..\\evil":    false,
+		"nul\x00byte": false,
+	} {
+		if got := ValidName(name); got != want {
+			t.Errorf("ValidName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	for name, want := range map[string]string{
+		"report.pdf":       "report.pdf",
+		"../../etc/passwd": "passwd",
+		"..\\..\\evil.exe": "evil.exe",
+		"dir/":             "file",
+		"..":               "file",
+	} {
+		if got := SafeName(name); got != want {
+			t.Errorf("SafeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package filestoreutils
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrExportCancelled = errors.New("export cancelled")
+
+// ExportTracker follows an export as it is written: it is told about every file and every block of plaintext, and
+// can stop the export. Exports that aren't followed get a nil ExportTracker.
+type ExportTracker interface {
+	// Err returns ErrExportCancelled once the export should stop
+	Err() error
+	// FileStarted is called as a file starts being written, with its name in the export
+	FileStarted(name string)
+	// Written is called with the number of plaintext bytes just written
+	Written(n int64)
+	// FileDone is called once a file was written or failed to be
+	FileDone()
+}
+
+// trackedWriter reports what is written through it to a tracker, and fails once the export is cancelled
+type trackedWriter struct {
+	w       io.Writer
+	tracker ExportTracker
+}
+
+func (t *trackedWriter) Write(p []byte) (int, error) {
+	if err := t.tracker.Err(); err != nil {
+		return 0, err
+	}
+	n, err := t.w.Write(p)
+	t.tracker.Written(int64(n))
+	return n, err
+}
+
+// trackWriter returns w, reporting to tracker if there is one
+func trackWriter(w io.Writer, tracker ExportTracker) io.Writer {
+	if tracker == nil {
+		return w
+	}
+	return &trackedWriter{w: w, tracker: tracker}
+}
+
+// exportCancelled reports whether a tracked export should stop
+func exportCancelled(tracker ExportTracker) bool {
+	return tracker != nil && tracker.Err() != nil
+}
@@ -0,0 +1,45 @@
+package filestoreutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// cancellingTracker cancels the export once limit bytes were written
+type cancellingTracker struct {
+	written int64
+	limit   int64
+}
+
+func (c *cancellingTracker) Err() error {
+	if c.written >= c.limit {
+		return ErrExportCancelled
+	}
+	return nil
+}
+func (c *cancellingTracker) FileStarted(string) {}
+func (c *cancellingTracker) Written(n int64)    { c.written += n }
+func (c *cancellingTracker) FileDone()          {}
+
+func TestTrackedWriterStopsOnceCancelled(t *testing.T) {
+	tracker := &cancellingTracker{limit: 10}
+	var dst bytes.Buffer
+	w := trackWriter(&dst, tracker)
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write(make([]byte, 4)); err != nil {
+			if !errors.Is(err, ErrExportCancelled) || i != 3 {
+				t.Fatalf("write %d failed with %v, want ErrExportCancelled on write 3", i, err)
+			}
+			break
+		}
+	}
+	if dst.Len() != 12 || tracker.written != 12 {
+		t.Errorf("wrote %d bytes and tracked %d, want 12", dst.Len(), tracker.written)
+	}
+
+	if w := trackWriter(io.Discard, nil); w != io.Discard {
+		t.Errorf("trackWriter() wrapped a writer without a tracker")
+	}
+}
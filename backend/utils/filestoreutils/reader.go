@@ -0,0 +1,111 @@
+package filestoreutils
+
+import (
+	"Tella-Desktop/backend/utils/authutils"
+	util "Tella-Desktop/backend/utils/genericutil"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// MimeDetectionSize is the number of bytes mimetype.Detect inspects by default
+const MimeDetectionSize = 3072
+
+// copyBufferSize is the size of the buffer used when streaming plaintext between readers and writers
+const copyBufferSize = 32 * 1024
+
+// FileReader gives access to the decrypted contents of a file stored in the TVault. Close erases any plaintext held
+// in memory by the reader.
+type FileReader interface {
+	io.ReadSeeker
+	io.ReaderAt
+	Size() int64
+	Close() error
+}
+
+// blobReader holds the complete plaintext of a file stored in the blob format; the blob format can only be
+// authenticated as a whole, so it can't be decrypted piece by piece like the chunked format
+type blobReader struct {
+	*bytes.Reader
+	plaintext []byte
+}
+
+func (b *blobReader) Close() error {
+	util.SecureZeroMemory(b.plaintext)
+	return nil
+}
+
+// OpenFileReader returns a reader decrypting the file described by metadata from the TVault. Files in the chunked
+// format are decrypted one chunk at a time as they are read, so memory use stays bounded by the chunk size. Files in
+// the older blob format are decrypted into memory in full.
+func OpenFileReader(metadata *FileMetadata, dbKey []byte, tvault *os.File) (FileReader, error) {
+	// Generate file key
+	fileKey := GenerateFileKey(metadata.UUID, dbKey)
+	defer util.SecureZeroMemory(fileKey)
+
+	if metadata.Format == FormatChunked {
+		section := io.NewSectionReader(tvault, metadata.Offset, metadata.Length)
+		return authutils.NewChunkedReader(section, metadata.Length, fileKey, []byte(metadata.UUID))
+	}
+
+	// Read encrypted data from TVault
+	encryptedData := make([]byte, metadata.Length)
+	_, err := tvault.ReadAt(encryptedData, metadata.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer util.SecureZeroMemory(encryptedData)
+
+	plaintext, err := authutils.DecryptData(encryptedData, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &blobReader{Reader: bytes.NewReader(plaintext), plaintext: plaintext}, nil
+}
+
+// CopyDecrypted streams plaintext from src to dst through a single buffer which is erased afterwards.
+func CopyDecrypted(dst io.Writer, src io.Reader) (int64, error) {
+	buf := make([]byte, copyBufferSize)
+	defer util.SecureZeroMemory(buf)
+	// hide any io.ReaderFrom / io.WriterTo implementations, which would make io.CopyBuffer copy through buffers of
+	// their own that we can't erase
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, buf)
+}
+
+// ErrHashMismatch is returned when a file's plaintext does not match the SHA-256 recorded when it was stored
+var ErrHashMismatch = errors.New("file does not match its recorded hash")
+
+// verifyingReader hashes everything read through it and checks the hash against the expected one at EOF
+type verifyingReader struct {
+	src      io.ReadCloser
+	hasher   hash.Hash
+	expected string
+}
+
+// NewVerifyingReader wraps src so that reaching its end returns ErrHashMismatch instead of io.EOF if the data read does
+// not hash to expectedSHA256. src is returned as is if there is no hash to check against, as is the case for files
+// stored before hashes were recorded.
+func NewVerifyingReader(src io.ReadCloser, expectedSHA256 string) io.ReadCloser {
+	if expectedSHA256 == "" {
+		return src
+	}
+	return &verifyingReader{src: src, hasher: sha256.New(), expected: strings.ToLower(expectedSHA256)}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.src.Read(p)
+	v.hasher.Write(p[:n])
+	if errors.Is(err, io.EOF) && hex.EncodeToString(v.hasher.Sum(nil)) != v.expected {
+		return n, ErrHashMismatch
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.src.Close()
+}
@@ -0,0 +1,254 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxTagLength           = 64
+	maxMetadataKeyLength   = 64
+	maxMetadataValueLength = 10000
+	// maxZipCommentLength is the largest comment a ZIP entry can hold
+	maxZipCommentLength = 1<<16 - 1
+)
+
+// Annotated holds the tags and custom metadata of either files or folders, see AnnotatedFiles and AnnotatedFolders.
+// Its fields are fixed table and column names, never user input, so they can be put into queries directly.
+type Annotated struct {
+	tagTable      string
+	metadataTable string
+	idColumn      string
+	// existsQuery checks that a file or folder can be annotated
+	existsQuery string
+}
+
+var (
+	AnnotatedFiles = Annotated{
+		tagTable:      "file_tags",
+		metadataTable: "file_custom_metadata",
+		idColumn:      "file_id",
+		existsQuery:   "SELECT EXISTS(SELECT 1 FROM files WHERE id = ? AND is_deleted = 0)",
+	}
+	AnnotatedFolders = Annotated{
+		tagTable:      "folder_tags",
+		metadataTable: "folder_custom_metadata",
+		idColumn:      "folder_id",
+		existsQuery:   "SELECT EXISTS(SELECT 1 FROM folders WHERE id = ?)",
+	}
+)
+
+// queryer is either a *sql.DB or a *sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// TagUsage is a tag with the number of files and folders carrying it
+type TagUsage struct {
+	Name        string
+	FileCount   int
+	FolderCount int
+}
+
+var ErrInvalidTag = errors.New("tags must be 1 to 64 characters long, without control characters")
+
+// NormalizeTag trims a tag and checks that it can be stored. Tags are compared ignoring case.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if !validLabel(tag, maxTagLength) {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+var ErrInvalidMetadata = errors.New("metadata keys must be 1 to 64 characters long, and values at most 10000 characters")
+
+// NormalizeMetadataKey trims a custom metadata key and checks that it can be stored. Keys are compared ignoring case.
+func NormalizeMetadataKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if !validLabel(key, maxMetadataKeyLength) {
+		return "", ErrInvalidMetadata
+	}
+	return key, nil
+}
+
+func validLabel(label string, maxLength int) bool {
+	if label == "" || utf8.RuneCountInString(label) > maxLength || !utf8.ValidString(label) {
+		return false
+	}
+	return strings.IndexFunc(label, unicode.IsControl) == -1
+}
+
+// Exists reports whether the file or folder with the given ID can be annotated; deleted files can't
+func (a Annotated) Exists(tx *sql.Tx, id int64) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(a.existsQuery, id).Scan(&exists)
+	return exists, err
+}
+
+// AddTag tags a file or folder, creating the tag if it doesn't exist yet. The tag must have been normalized.
+func (a Annotated) AddTag(tx *sql.Tx, id int64, tag string) error {
+	if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name, created_at) VALUES (?, datetime('now'))", tag); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT OR IGNORE INTO `+a.tagTable+` (`+a.idColumn+`, tag_id) SELECT ?, id FROM tags WHERE name = ?`, id, tag)
+	return err
+}
+
+// RemoveTag untags a file or folder. Tags left unused are dropped by DeleteUnusedTags.
+func (a Annotated) RemoveTag(tx *sql.Tx, id int64, tag string) error {
+	_, err := tx.Exec(`DELETE FROM `+a.tagTable+` WHERE `+a.idColumn+` = ? AND tag_id IN (SELECT id FROM tags WHERE name = ?)`, id, tag)
+	return err
+}
+
+// GetTags returns the tags of a file or folder, sorted by name
+func (a Annotated) GetTags(q queryer, id int64) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT t.name FROM `+a.tagTable+` x
+		JOIN tags t ON t.id = x.tag_id
+		WHERE x.`+a.idColumn+` = ?
+		ORDER BY t.name COLLATE NOCASE ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// SetCustomMetadata sets a custom metadata value of a file or folder, or removes the key if value is empty. The key must
+// have been normalized.
+func (a Annotated) SetCustomMetadata(tx *sql.Tx, id int64, key, value string) error {
+	if utf8.RuneCountInString(value) > maxMetadataValueLength || !utf8.ValidString(value) {
+		return ErrInvalidMetadata
+	}
+	if value == "" {
+		_, err := tx.Exec(`DELETE FROM `+a.metadataTable+` WHERE `+a.idColumn+` = ? AND key = ?`, id, key)
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO `+a.metadataTable+` (`+a.idColumn+`, key, value, updated_at) VALUES (?, ?, ?, datetime('now'))
+		ON CONFLICT (`+a.idColumn+`, key) DO UPDATE SET key = excluded.key, value = excluded.value, updated_at = excluded.updated_at
+	`, id, key, value)
+	return err
+}
+
+// GetCustomMetadata returns the custom metadata of a file or folder
+func (a Annotated) GetCustomMetadata(q queryer, id int64) (map[string]string, error) {
+	rows, err := q.Query(`SELECT key, value FROM `+a.metadataTable+` WHERE `+a.idColumn+` = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metadata := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		metadata[key] = value
+	}
+	return metadata, rows.Err()
+}
+
+// DeleteAnnotations removes all tags and custom metadata of a file or folder, once it is deleted for good
+func (a Annotated) DeleteAnnotations(tx *sql.Tx, id int64) error {
+	if _, err := tx.Exec(`DELETE FROM `+a.tagTable+` WHERE `+a.idColumn+` = ?`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM `+a.metadataTable+` WHERE `+a.idColumn+` = ?`, id)
+	return err
+}
+
+// CopyFileAnnotations gives the file copyID the tags and custom metadata of the file fileID
+func CopyFileAnnotations(tx *sql.Tx, fileID, copyID int64) error {
+	if _, err := tx.Exec("INSERT OR IGNORE INTO file_tags (file_id, tag_id) SELECT ?, tag_id FROM file_tags WHERE file_id = ?", copyID, fileID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT OR IGNORE INTO file_custom_metadata (file_id, key, value, updated_at)
+		SELECT ?, key, value, updated_at FROM file_custom_metadata WHERE file_id = ?
+	`, copyID, fileID)
+	return err
+}
+
+// DeleteUnusedTags drops the tags no file or folder carries anymore, files in the trash included
+func DeleteUnusedTags(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		DELETE FROM tags
+		WHERE id NOT IN (SELECT tag_id FROM file_tags) AND id NOT IN (SELECT tag_id FROM folder_tags)
+	`)
+	return err
+}
+
+// GetAllTags lists every tag by name, with the number of live files and folders carrying it
+func GetAllTags(db *sql.DB) ([]TagUsage, error) {
+	rows, err := db.Query(`
+		SELECT t.name,
+			(SELECT COUNT(*) FROM file_tags ft JOIN files f ON f.id = ft.file_id WHERE ft.tag_id = t.id AND f.is_deleted = 0),
+			(SELECT COUNT(*) FROM folder_tags WHERE tag_id = t.id)
+		FROM tags t
+		ORDER BY t.name COLLATE NOCASE ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []TagUsage
+	for rows.Next() {
+		var tag TagUsage
+		if err := rows.Scan(&tag.Name, &tag.FileCount, &tag.FolderCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// FileAnnotationComment describes the tags and custom metadata of a file in a few lines of text, for the comment of its
+// ZIP entry. It is empty if the file has neither.
+func FileAnnotationComment(q queryer, fileID int64) (string, error) {
+	tags, err := AnnotatedFiles.GetTags(q, fileID)
+	if err != nil {
+		return "", err
+	}
+	metadata, err := AnnotatedFiles.GetCustomMetadata(q, fileID)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	if len(tags) > 0 {
+		lines = append(lines, "Tags: "+strings.Join(tags, ", "))
+	}
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return strings.ToLower(keys[i]) < strings.ToLower(keys[j]) })
+	for _, key := range keys {
+		lines = append(lines, key+": "+metadata[key])
+	}
+
+	comment := strings.Join(lines, "\n")
+	for len(comment) > maxZipCommentLength {
+		_, size := utf8.DecodeLastRuneInString(comment)
+		comment = comment[:len(comment)-size]
+	}
+	return comment, nil
+}
@@ -0,0 +1,114 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{"evidence", "evidence", false},
+		{"  case 42 ", "case 42", false},
+		{"", "", true},
+		{"   ", "", true},
+		{"line\nbreak", "", true},
+		{strings.Repeat("a", 65), "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeTag(tt.tag)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeTag(%q) = %q, %v, want %q, error %v", tt.tag, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFileAnnotations(t *testing.T) {
+	db, _ := setupAllocatorTest(t)
+	if _, err := db.Exec("INSERT INTO folders (name) VALUES ('folder')"); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	var fileID, copyID int64
+	err := withTx(t, db, func(tx *sql.Tx) (err error) {
+		fileID, err = InsertFileMetadata(tx, "file-uuid", "a.jpg", 900, "image/jpeg", 1, 256, 1000, FormatChunked, "abc123")
+		if err != nil {
+			return err
+		}
+		// tags are matched ignoring case, so the second one is the same tag
+		for _, tag := range []string{"Evidence", "evidence", "witness"} {
+			if err := AnnotatedFiles.AddTag(tx, fileID, tag); err != nil {
+				return err
+			}
+		}
+		if err := AnnotatedFiles.SetCustomMetadata(tx, fileID, "Case number", "2026-17"); err != nil {
+			return err
+		}
+		if err := AnnotatedFiles.SetCustomMetadata(tx, fileID, "case number", "2026-18"); err != nil {
+			return err
+		}
+		if err := AnnotatedFiles.SetCustomMetadata(tx, fileID, "notes", "first line\nsecond line"); err != nil {
+			return err
+		}
+		copyID, err = InsertFileMetadata(tx, "copy-uuid", "a.jpg", 900, "image/jpeg", 1, 1256, 1000, FormatChunked, "abc123")
+		if err != nil {
+			return err
+		}
+		return CopyFileAnnotations(tx, fileID, copyID)
+	})
+	if err != nil {
+		t.Fatalf("Failed to annotate file: %v", err)
+	}
+
+	want := "Tags: Evidence, witness\ncase number: 2026-18\nnotes: first line\nsecond line"
+	for _, id := range []int64{fileID, copyID} {
+		comment, err := FileAnnotationComment(db, id)
+		if err != nil || comment != want {
+			t.Errorf("FileAnnotationComment(%d) = %q, %v, want %q", id, comment, err, want)
+		}
+	}
+
+	tagCount := func() int {
+		t.Helper()
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM tags").Scan(&count); err != nil {
+			t.Fatalf("Failed to count tags: %v", err)
+		}
+		return count
+	}
+
+	// tags stay while any file carries them
+	err = withTx(t, db, func(tx *sql.Tx) error {
+		if err := AnnotatedFiles.DeleteAnnotations(tx, fileID); err != nil {
+			return err
+		}
+		return DeleteUnusedTags(tx)
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete annotations: %v", err)
+	}
+	if comment, _ := FileAnnotationComment(db, fileID); comment != "" {
+		t.Errorf("Annotations of file survived deletion: %q", comment)
+	}
+	if count := tagCount(); count != 2 {
+		t.Errorf("%d tags left, want 2", count)
+	}
+
+	err = withTx(t, db, func(tx *sql.Tx) error {
+		if err := AnnotatedFiles.DeleteAnnotations(tx, copyID); err != nil {
+			return err
+		}
+		return DeleteUnusedTags(tx)
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete annotations: %v", err)
+	}
+	if count := tagCount(); count != 0 {
+		t.Errorf("%d unused tags left, want 0", count)
+	}
+}
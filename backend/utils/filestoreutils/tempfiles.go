@@ -0,0 +1,234 @@
+package filestoreutils
+
+import (
+	util "Tella-Desktop/backend/utils/genericutil"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// TempFile is a decrypted copy of a file, tracked in temp_files until it is wiped
+type TempFile struct {
+	ID     int64
+	FileID int64
+	Path   string
+}
+
+// previewFileName returns a name for the decrypted copy of a file that can't escape its directory. The file keeps its
+// name, and thereby its extension, so that other applications recognise it.
+func previewFileName(name string) string {
+	base := filepath.Base(name)
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		return "file"
+	}
+	return base
+}
+
+var errTempFile = errors.New("error decrypting file to temporary directory")
+
+// DecryptToTempFile decrypts a file into a directory of its own under tempDir, readable only by the current user. The
+// path is recorded in temp_files before anything is decrypted, so that a crash can't leave an untracked copy behind.
+func DecryptToTempFile(db *sql.DB, dbKey []byte, id int64, tvault *os.File, tempDir string) (string, error) {
+	reader, fileName, err := openAndGetFilename(db, id, dbKey, tvault)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	// a directory per copy keeps the original name without clashing with other copies
+	dir := filepath.Join(tempDir, uuid.New().String())
+	if err := os.MkdirAll(dir, util.USER_ONLY_DIR_PERMS); err != nil {
+		log("failed to create temporary directory: %v", err)
+		return "", errTempFile
+	}
+	tempPath := filepath.Join(dir, previewFileName(fileName))
+
+	if err := RecordTempFile(db, id, tempPath); err != nil {
+		log("failed to record temporary file: %v", err)
+		os.Remove(dir)
+		return "", errTempFile
+	}
+
+	tempFile, err := util.NarrowCreate(tempPath)
+	if err != nil {
+		log("failed to create temporary file: %v", err)
+		return "", errTempFile
+	}
+	defer tempFile.Close()
+
+	if _, err := CopyDecrypted(tempFile, reader); err != nil {
+		log("failed to write temporary file: %v", err)
+		// the partial copy stays tracked, and is wiped with the others if wiping it now fails
+		tempFile.Close()
+		WipeTempFile(tempPath)
+		if errors.Is(err, ErrHashMismatch) {
+			return "", err
+		}
+		return "", errTempFile
+	}
+
+	return tempPath, nil
+}
+
+// GetTempFiles returns every tracked temporary file
+func GetTempFiles(db *sql.DB) ([]TempFile, error) {
+	rows, err := db.Query("SELECT id, file_id, temp_path FROM temp_files ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []TempFile
+	for rows.Next() {
+		var file TempFile
+		if err := rows.Scan(&file.ID, &file.FileID, &file.Path); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// ForgetTempFile stops tracking a temporary file, once it has been wiped
+func ForgetTempFile(db *sql.DB, id int64) error {
+	_, err := db.Exec("DELETE FROM temp_files WHERE id = ?", id)
+	return err
+}
+
+var errDeleteTempFile = errors.New("error securely deleting file")
+
+// SecurelyDeleteFile overwrites a file with random data before removing it. A file that no longer exists counts as
+// deleted.
+func SecurelyDeleteFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		log("failed to stat %s: %v", path, err)
+		return errDeleteTempFile
+	}
+
+	if info.Mode().IsRegular() && info.Size() > 0 {
+		if err := SecurelyOverwriteFileData(path, 0, info.Size()); err != nil {
+			return errDeleteTempFile
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		log("failed to remove %s: %v", path, err)
+		return errDeleteTempFile
+	}
+	return nil
+}
+
+// WipeTempFile securely deletes a file decrypted by DecryptToTempFile, along with the directory created for it
+func WipeTempFile(path string) error {
+	if err := SecurelyDeleteFile(path); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Dir(path)); err != nil && !os.IsNotExist(err) {
+		log("failed to remove directory of %s: %v", path, err)
+	}
+	return nil
+}
+
+// tempDirMarker is the file marking a directory as the app's own temp directory, see PrepareTempDir
+const tempDirMarker = ".tella-temp"
+
+// PrepareTempDir creates the temp directory decrypted copies go to. The directory is marked as the app's own when the
+// app creates it, or finds it empty, so that WipeTempDir never shreds a directory that may hold someone else's files.
+func PrepareTempDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(dir, util.USER_ONLY_DIR_PERMS); err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return nil
+	}
+	marker, err := util.NarrowCreate(filepath.Join(dir, tempDirMarker))
+	if err != nil {
+		return err
+	}
+	return marker.Close()
+}
+
+// WipeTempDir securely deletes everything in the temp directory, provided PrepareTempDir marked it as the app's own. An
+// unmarked directory is left alone; the copies tracked in temp_files are still wiped by their paths once the vault is
+// unlocked.
+func WipeTempDir(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, tempDirMarker)); err != nil {
+		if os.IsNotExist(err) {
+			log("not wiping %s, it isn't marked as the app's temp directory", dir)
+			return nil
+		}
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var failed bool
+	for _, entry := range entries {
+		if entry.Name() == tempDirMarker {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			err = WipeDirContents(path)
+			if err == nil {
+				err = os.Remove(path)
+			}
+		} else {
+			err = SecurelyDeleteFile(path)
+		}
+		if err != nil {
+			log("failed to wipe %s: %v", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errDeleteTempFile
+	}
+	return nil
+}
+
+// WipeDirContents securely deletes everything inside dir, keeping dir itself
+func WipeDirContents(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		log("failed to read %s: %v", dir, err)
+		return errDeleteTempFile
+	}
+
+	var failed bool
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if err := WipeDirContents(path); err != nil {
+				failed = true
+				continue
+			}
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log("failed to remove %s: %v", path, err)
+				failed = true
+			}
+			continue
+		}
+		if err := SecurelyDeleteFile(path); err != nil {
+			failed = true
+		}
+	}
+	if failed {
+		return errDeleteTempFile
+	}
+	return nil
+}
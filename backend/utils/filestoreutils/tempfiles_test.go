@@ -0,0 +1,123 @@
+package filestoreutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreviewFileNameStaysInDirectory(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"../../.bashrc", ".bashrc"},
+		{"/etc/passwd", "passwd"},
+		{"..", "file"},
+		{"", "file"},
+	}
+
+	for _, tt := range tests {
+		if got := previewFileName(tt.name); got != tt.want {
+			t.Errorf("previewFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWipeTempFileRemovesItsDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	dir := filepath.Join(tempDir, "copy")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	path := filepath.Join(dir, "photo.jpg")
+	if err := os.WriteFile(path, []byte("decrypted contents"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := WipeTempFile(path); err != nil {
+		t.Fatalf("Failed to wipe file: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Directory of wiped file still exists: %v", err)
+	}
+	if _, err := os.Stat(tempDir); err != nil {
+		t.Errorf("Temp directory was removed: %v", err)
+	}
+
+	// wiping a file that is already gone succeeds, so that it can be forgotten
+	if err := WipeTempFile(path); err != nil {
+		t.Errorf("Failed to wipe missing file: %v", err)
+	}
+}
+
+func TestWipeDirContents(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, path := range []string{"a.txt", "copy/b.txt", "copy/nested/c.txt"} {
+		path = filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("decrypted contents"), 0600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	if err := WipeDirContents(tempDir); err != nil {
+		t.Fatalf("Failed to wipe directory: %v", err)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read wiped directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Wiped directory still holds %d entries", len(entries))
+	}
+
+	if err := WipeDirContents(filepath.Join(tempDir, "missing")); err != nil {
+		t.Errorf("Failed to wipe missing directory: %v", err)
+	}
+}
+
+func TestWipeTempDirOnlyWipesMarkedDirectories(t *testing.T) {
+	// a directory that already held files isn't claimed, and is left alone
+	foreign := t.TempDir()
+	notes := filepath.Join(foreign, "notes.txt")
+	if err := os.WriteFile(notes, []byte("someone else's file"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := PrepareTempDir(foreign); err != nil {
+		t.Fatalf("PrepareTempDir() failed: %v", err)
+	}
+	if err := WipeTempDir(foreign); err != nil {
+		t.Fatalf("WipeTempDir() failed: %v", err)
+	}
+	if _, err := os.Stat(notes); err != nil {
+		t.Errorf("File in an unmarked directory was wiped: %v", err)
+	}
+
+	// a directory created by the app is wiped, and stays marked
+	own := filepath.Join(t.TempDir(), "temp")
+	if err := PrepareTempDir(own); err != nil {
+		t.Fatalf("PrepareTempDir() failed: %v", err)
+	}
+	copyPath := filepath.Join(own, "copy", "photo.jpg")
+	if err := os.MkdirAll(filepath.Dir(copyPath), 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(copyPath, []byte("decrypted contents"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := WipeTempDir(own); err != nil {
+		t.Fatalf("WipeTempDir() failed: %v", err)
+	}
+	entries, err := os.ReadDir(own)
+	if err != nil {
+		t.Fatalf("Failed to read wiped directory: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != tempDirMarker {
+		t.Errorf("Wiped directory holds %v, want only the marker", entries)
+	}
+}
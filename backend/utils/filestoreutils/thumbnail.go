@@ -0,0 +1,60 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ThumbnailID is the identifier a file's thumbnail is encrypted under: it derives the thumbnail's key and is bound to
+// every chunk of its ciphertext, so a thumbnail can't be passed off as the file itself or vice versa
+func ThumbnailID(fileUUID string) string {
+	return fileUUID + ":thumbnail"
+}
+
+// SetThumbnail records the blurhash and the TVault region of the encrypted thumbnail of a file
+func SetThumbnail(tx *sql.Tx, fileID int64, blurhash string, region VaultRegion) error {
+	_, err := tx.Exec(`
+		UPDATE files SET blurhash = ?, thumbnail_offset = ?, thumbnail_length = ?
+		WHERE id = ?
+	`, blurhash, region.Offset, region.Length, fileID)
+	return err
+}
+
+// ClearThumbnail forgets the thumbnail of a file, keeping its blurhash. The thumbnail's region is not freed.
+func ClearThumbnail(tx *sql.Tx, fileID int64) error {
+	_, err := tx.Exec("UPDATE files SET thumbnail_offset = NULL, thumbnail_length = NULL WHERE id = ?", fileID)
+	return err
+}
+
+var ErrNoThumbnail = errors.New("file has no thumbnail")
+
+// GetThumbnailMetadata returns metadata describing the encrypted thumbnail of a file, to be opened with OpenFileReader
+func GetThumbnailMetadata(db *sql.DB, fileID int64) (*FileMetadata, error) {
+	var fileUUID string
+	var offset, length sql.NullInt64
+	err := db.QueryRow(`
+		SELECT COALESCE(content_uuid, uuid), thumbnail_offset, thumbnail_length
+		FROM files
+		WHERE id = ? AND is_deleted = 0
+	`, fileID).Scan(&fileUUID, &offset, &length)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log("file not found with ID: %d", fileID)
+			return nil, errGetMetadata
+		}
+		log("failed to fetch thumbnail metadata: %v", err)
+		return nil, errGetMetadata
+	}
+	if !offset.Valid || !length.Valid {
+		return nil, ErrNoThumbnail
+	}
+
+	return &FileMetadata{
+		ID:       fileID,
+		UUID:     ThumbnailID(fileUUID),
+		MimeType: "image/jpeg",
+		Offset:   offset.Int64,
+		Length:   length.Int64,
+		Format:   FormatChunked,
+	}, nil
+}
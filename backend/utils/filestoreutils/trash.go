@@ -0,0 +1,170 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// TrashedFile is a file in the trash, with the folder it was deleted from
+type TrashedFile struct {
+	ID        int64
+	Name      string
+	MimeType  string
+	Size      int64
+	SHA256    string
+	Blurhash  string
+	FolderID  int64
+	CreatedAt string
+	TrashedAt string
+}
+
+var errTrashFile = errors.New("error moving file to the trash")
+
+// TrashFile hides a live file from the user while keeping its ciphertext, see referencedFilesCondition
+func TrashFile(tx *sql.Tx, fileID int64) error {
+	result, err := tx.Exec("UPDATE files SET is_deleted = 1, updated_at = datetime('now') WHERE id = ? AND is_deleted = 0", fileID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("file to trash not found with ID: %d", fileID)
+		return errTrashFile
+	}
+	// the path of the folder is kept, as the folder may be deleted while the file is in the trash
+	path, err := folderPath(tx, fileID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT OR REPLACE INTO trashed_files (file_id, trashed_at, folder_path) VALUES (?, datetime('now'), ?)", fileID, path)
+	return err
+}
+
+// folderPath returns the names of the folder of a file and its parents, top-level folder first, as a JSON array. The
+// number of steps is bounded, so that a corrupted parent cycle can't loop forever.
+func folderPath(tx *sql.Tx, fileID int64) (string, error) {
+	rows, err := tx.Query(`
+		WITH RECURSIVE chain(name, parent_id, depth) AS (
+			SELECT name, parent_id, 0 FROM folders WHERE id = (SELECT folder_id FROM files WHERE id = ?)
+			UNION ALL
+			SELECT f.name, f.parent_id, c.depth + 1 FROM folders f JOIN chain c ON f.id = c.parent_id
+			WHERE c.depth < 1000
+		)
+		SELECT name FROM chain ORDER BY depth DESC
+	`, fileID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return "", err
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	path, err := json.Marshal(names)
+	return string(path), err
+}
+
+// GetTrashedFolderPath returns the names of the folder a file was trashed from and its parents, top-level folder first.
+// It is nil for files trashed before folder paths were recorded.
+func GetTrashedFolderPath(db *sql.DB, fileID int64) ([]string, error) {
+	var path sql.NullString
+	if err := db.QueryRow("SELECT folder_path FROM trashed_files WHERE file_id = ?", fileID).Scan(&path); err != nil {
+		return nil, err
+	}
+	if !path.Valid {
+		return nil, nil
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(path.String), &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+var errNotInTrash = errors.New("file is not in the trash")
+
+// RestoreFile brings a file back from the trash into folderID
+func RestoreFile(tx *sql.Tx, fileID, folderID int64) error {
+	result, err := tx.Exec("DELETE FROM trashed_files WHERE file_id = ?", fileID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		log("file to restore not found in the trash with ID: %d", fileID)
+		return errNotInTrash
+	}
+	_, err = tx.Exec("UPDATE files SET is_deleted = 0, folder_id = ?, updated_at = datetime('now') WHERE id = ?", folderID, fileID)
+	return err
+}
+
+// RemoveFromTrash forgets that files are in the trash, once they have been purged. Files that aren't in the trash are
+// ignored.
+func RemoveFromTrash(tx *sql.Tx, ids []int64) error {
+	for _, fileID := range ids {
+		if _, err := tx.Exec("DELETE FROM trashed_files WHERE file_id = ?", fileID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InTrash reports whether a file is in the trash
+func InTrash(tx *sql.Tx, fileID int64) (bool, error) {
+	var trashed bool
+	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM trashed_files WHERE file_id = ?)", fileID).Scan(&trashed)
+	return trashed, err
+}
+
+// GetTrashedFiles lists the files in the trash, most recently trashed first
+func GetTrashedFiles(db *sql.DB) ([]TrashedFile, error) {
+	rows, err := db.Query(`
+		SELECT f.id, f.name, f.mime_type, f.size, COALESCE(f.sha256, ''), COALESCE(f.blurhash, ''), f.folder_id,
+			f.created_at, t.trashed_at
+		FROM trashed_files t
+		JOIN files f ON f.id = t.file_id
+		ORDER BY t.trashed_at DESC, f.id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []TrashedFile
+	for rows.Next() {
+		var file TrashedFile
+		if err := rows.Scan(&file.ID, &file.Name, &file.MimeType, &file.Size, &file.SHA256, &file.Blurhash,
+			&file.FolderID, &file.CreatedAt, &file.TrashedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// GetTrashedBefore returns the IDs of the files that were moved to the trash before cutoff
+func GetTrashedBefore(db *sql.DB, cutoff time.Time) ([]int64, error) {
+	rows, err := db.Query("SELECT file_id FROM trashed_files WHERE trashed_at < ? ORDER BY file_id",
+		cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var fileID int64
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, fileID)
+	}
+	return ids, rows.Err()
+}
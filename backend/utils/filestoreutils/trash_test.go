@@ -0,0 +1,88 @@
+package filestoreutils
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestTrashedFilesKeepRegionUntilPurged(t *testing.T) {
+	db, tvaultPath := setupAllocatorTest(t)
+	for _, name := range []string{"first", "second"} {
+		if _, err := db.Exec("INSERT INTO folders (name) VALUES (?)", name); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+	}
+	offset := allocate(t, db, tvaultPath, 1000)
+	region := VaultRegion{Offset: offset, Length: 1000}
+
+	var fileID int64
+	err := withTx(t, db, func(tx *sql.Tx) (err error) {
+		fileID, err = InsertFileMetadata(tx, "file-uuid", "a.jpg", 900, "image/jpeg", 1, offset, 1000, FormatChunked, "abc123")
+		if err != nil {
+			return err
+		}
+		return TrashFile(tx, fileID)
+	})
+	if err != nil {
+		t.Fatalf("Failed to trash file: %v", err)
+	}
+
+	referenced := func() bool {
+		t.Helper()
+		var result bool
+		withTx(t, db, func(tx *sql.Tx) (err error) {
+			result, err = RegionReferenced(tx, region)
+			return err
+		})
+		return result
+	}
+	if !referenced() {
+		t.Errorf("Region of trashed file is unreferenced")
+	}
+
+	trashed, err := GetTrashedFiles(db)
+	if err != nil || len(trashed) != 1 || trashed[0].ID != fileID || trashed[0].FolderID != 1 {
+		t.Fatalf("GetTrashedFiles() = %+v, %v, want file %d from folder 1", trashed, err, fileID)
+	}
+	if path, err := GetTrashedFolderPath(db, fileID); err != nil || len(path) != 1 || path[0] != "first" {
+		t.Errorf("GetTrashedFolderPath() = %q, %v, want [first]", path, err)
+	}
+	if expired, err := GetTrashedBefore(db, time.Now().Add(-time.Hour)); err != nil || len(expired) != 0 {
+		t.Errorf("GetTrashedBefore(an hour ago) = %v, %v, want none", expired, err)
+	}
+	if expired, err := GetTrashedBefore(db, time.Now().Add(time.Hour)); err != nil || len(expired) != 1 {
+		t.Errorf("GetTrashedBefore(in an hour) = %v, %v, want file %d", expired, err, fileID)
+	}
+
+	err = withTx(t, db, func(tx *sql.Tx) error {
+		return RestoreFile(tx, fileID, 2)
+	})
+	if err != nil {
+		t.Fatalf("Failed to restore file: %v", err)
+	}
+	restored, err := GetFileMetadataByID(db, fileID)
+	if err != nil || restored.FolderID != 2 {
+		t.Errorf("Restored file = %+v, %v, want it live in folder 2", restored, err)
+	}
+	err = withTx(t, db, func(tx *sql.Tx) error {
+		return RestoreFile(tx, fileID, 1)
+	})
+	if err == nil {
+		t.Errorf("Restored a file that is not in the trash")
+	}
+
+	// purging marks the file deleted and forgets it was in the trash, after which its region can be freed
+	err = withTx(t, db, func(tx *sql.Tx) error {
+		if err := TrashFile(tx, fileID); err != nil {
+			return err
+		}
+		return RemoveFromTrash(tx, []int64{fileID})
+	})
+	if err != nil {
+		t.Fatalf("Failed to purge file: %v", err)
+	}
+	if referenced() {
+		t.Errorf("Region of purged file is still referenced")
+	}
+}
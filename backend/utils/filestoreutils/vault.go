@@ -0,0 +1,192 @@
+package filestoreutils
+
+import (
+	util "Tella-Desktop/backend/utils/genericutil"
+	"database/sql"
+	"errors"
+	"os"
+)
+
+// VaultRegion is a range of the TVault holding ciphertext that is still referenced from the database
+type VaultRegion struct {
+	Offset int64
+	Length int64
+}
+
+// End returns the offset of the first byte after the region
+func (r VaultRegion) End() int64 {
+	return r.Offset + r.Length
+}
+
+// referencedFilesCondition selects the files rows whose ciphertext must be kept in the TVault: live files, files in the
+// trash, and files that were quarantined by the integrity check with their region intact
+const referencedFilesCondition = `(is_deleted = 0 OR id IN (SELECT file_id FROM trashed_files) OR
+	id IN (SELECT file_id FROM quarantined_files WHERE keep_region = 1))`
+
+// GetReferencedRegions returns every region of the TVault that holds referenced ciphertext, files and their thumbnails,
+// ordered by offset. Rows sharing a region are returned as a single region.
+func GetReferencedRegions(tx *sql.Tx) ([]VaultRegion, error) {
+	rows, err := tx.Query(`
+		SELECT offset, length FROM files
+		WHERE ` + referencedFilesCondition + `
+		UNION
+		SELECT thumbnail_offset, thumbnail_length FROM files
+		WHERE thumbnail_offset IS NOT NULL AND ` + referencedFilesCondition + `
+		ORDER BY 1 ASC, 2 ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var regions []VaultRegion
+	for rows.Next() {
+		var region VaultRegion
+		if err := rows.Scan(&region.Offset, &region.Length); err != nil {
+			return nil, err
+		}
+		regions = append(regions, region)
+	}
+	return regions, rows.Err()
+}
+
+// RelocateRegion points every reference to region at its new offset
+func RelocateRegion(tx *sql.Tx, region VaultRegion, newOffset int64) error {
+	_, err := tx.Exec(`
+		UPDATE files SET offset = ?
+		WHERE offset = ? AND length = ? AND `+referencedFilesCondition,
+		newOffset, region.Offset, region.Length)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE files SET thumbnail_offset = ?
+		WHERE thumbnail_offset = ? AND thumbnail_length = ? AND `+referencedFilesCondition,
+		newOffset, region.Offset, region.Length)
+	return err
+}
+
+// RegionReferenced reports whether any file still references region, for its contents or its thumbnail. Identical
+// files share their ciphertext, so a deleted file's region must only be freed once this is false.
+func RegionReferenced(tx *sql.Tx, region VaultRegion) (bool, error) {
+	var referenced bool
+	err := tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM files
+			WHERE ((offset = ? AND length = ?) OR (thumbnail_offset = ? AND thumbnail_length = ?))
+				AND `+referencedFilesCondition+`
+		)
+	`, region.Offset, region.Length, region.Offset, region.Length).Scan(&referenced)
+	return referenced, err
+}
+
+var errCopyRegion = errors.New("error copying TVault region")
+
+// CopyVaultRegion copies length bytes of the TVault from srcOffset to dstOffset and syncs the copy to disk. The
+// source and destination ranges must not overlap.
+func CopyVaultRegion(tvault *os.File, srcOffset, dstOffset, length int64) error {
+	if srcOffset < dstOffset+length && dstOffset < srcOffset+length {
+		log("refusing to copy overlapping TVault regions")
+		return errCopyRegion
+	}
+
+	// the copied bytes are ciphertext, but erase the buffer anyway
+	buf := make([]byte, min(length, overwriteBufferSize))
+	defer util.SecureZeroMemory(buf)
+	for copied := int64(0); copied < length; {
+		n := min(length-copied, int64(len(buf)))
+		if _, err := tvault.ReadAt(buf[:n], srcOffset+copied); err != nil {
+			log("failed to read TVault region: %v", err)
+			return errCopyRegion
+		}
+		if _, err := tvault.WriteAt(buf[:n], dstOffset+copied); err != nil {
+			log("failed to write TVault region: %v", err)
+			return errCopyRegion
+		}
+		copied += n
+	}
+
+	if err := tvault.Sync(); err != nil {
+		log("failed to sync TVault: %v", err)
+		return errCopyRegion
+	}
+	return nil
+}
+
+// ReferencedFile is a files row whose ciphertext is kept in the TVault
+type ReferencedFile struct {
+	FileMetadata
+	// Quarantined files failed an integrity check; their ciphertext is kept but they are hidden from the user
+	Quarantined bool
+}
+
+// GetReferencedFiles returns every file whose ciphertext is kept in the TVault, ordered by offset
+func GetReferencedFiles(db *sql.DB) ([]ReferencedFile, error) {
+	rows, err := db.Query(`
+		SELECT id, COALESCE(content_uuid, uuid), name, folder_id, offset, length, encryption_format, COALESCE(sha256, ''),
+			COALESCE(thumbnail_offset, 0), COALESCE(thumbnail_length, 0), id IN (SELECT file_id FROM quarantined_files)
+		FROM files
+		WHERE ` + referencedFilesCondition + `
+		ORDER BY offset ASC, length ASC, id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []ReferencedFile
+	for rows.Next() {
+		var file ReferencedFile
+		if err := rows.Scan(&file.ID, &file.UUID, &file.Name, &file.FolderID, &file.Offset, &file.Length, &file.Format, &file.SHA256,
+			&file.ThumbnailOffset, &file.ThumbnailLength, &file.Quarantined); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// GetFreeRegions returns the free list, ordered by offset
+func GetFreeRegions(db *sql.DB) ([]VaultRegion, error) {
+	rows, err := db.Query("SELECT offset, length FROM free_spaces ORDER BY offset ASC, length ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var regions []VaultRegion
+	for rows.Next() {
+		var region VaultRegion
+		if err := rows.Scan(&region.Offset, &region.Length); err != nil {
+			return nil, err
+		}
+		regions = append(regions, region)
+	}
+	return regions, rows.Err()
+}
+
+// RemoveFreeRegion drops a block from the free list, so that it is never handed out again
+func RemoveFreeRegion(tx *sql.Tx, region VaultRegion) error {
+	_, err := tx.Exec("DELETE FROM free_spaces WHERE offset = ? AND length = ?", region.Offset, region.Length)
+	return err
+}
+
+// QuarantineFile hides a file that failed an integrity check from the user. With keepRegion, its ciphertext is kept in
+// the TVault, so that it is neither handed out as free space nor lost to compaction. Regions that lie outside the TVault
+// or overlap other files can't be kept, as they would block compaction.
+func QuarantineFile(tx *sql.Tx, fileID int64, reason string, keepRegion bool) error {
+	_, err := tx.Exec(`
+		INSERT INTO quarantined_files (file_id, reason, keep_region, created_at)
+		VALUES (?, ?, ?, datetime('now'))
+		ON CONFLICT(file_id) DO UPDATE SET reason = excluded.reason, keep_region = excluded.keep_region
+	`, fileID, reason, keepRegion)
+	if err != nil {
+		return err
+	}
+	// a quarantined file can't be restored from the trash, and its region must not be kept for the trash's sake
+	if _, err := tx.Exec("DELETE FROM trashed_files WHERE file_id = ?", fileID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE files SET is_deleted = 1, updated_at = datetime('now') WHERE id = ?", fileID)
+	return err
+}
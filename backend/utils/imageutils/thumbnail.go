@@ -0,0 +1,193 @@
+package imageutils
+
+import (
+	util "Tella-Desktop/backend/utils/genericutil"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	// decoders for image.Decode
+	_ "image/gif"
+	_ "image/png"
+)
+
+const (
+	// ThumbnailMaxDimension is the size of the longest side of a thumbnail
+	ThumbnailMaxDimension = 256
+	// MaxSourcePixels bounds the size of images we decode, as a decoded image takes 4 or more bytes per pixel no
+	// matter how small the file is
+	MaxSourcePixels  = 50_000_000
+	thumbnailQuality = 80
+)
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	ErrImageTooLarge    = errors.New("image is too large to decode")
+)
+
+// SupportsMimeType reports whether thumbnails can be generated for images of the given MIME type. HEIC and HEIF
+// images are not supported, as the standard library has no decoder for them.
+func SupportsMimeType(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// Thumbnail is a downscaled copy of an image together with the blurhash of the image
+type Thumbnail struct {
+	JPEG     []byte
+	Blurhash string
+}
+
+// MakeThumbnail decodes the image read from r and returns a JPEG thumbnail no larger than ThumbnailMaxDimension on
+// either side, along with its blurhash. r must be seekable, as the image's dimensions are checked before it is decoded.
+// The EXIF orientation of JPEGs is ignored, so a photo the camera stored sideways gets a sideways thumbnail.
+//
+// The decoded image and the downscaled copy are erased before returning; the caller erases the returned JPEG.
+func MakeThumbnail(r io.ReadSeeker) (*Thumbnail, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxSourcePixels {
+		return nil, ErrImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	small := Downscale(img, ThumbnailMaxDimension)
+	zeroImage(img)
+	defer util.SecureZeroMemory(small.Pix)
+
+	flattenOnWhite(small)
+	var buf secureBuffer
+	if err := jpeg.Encode(&buf, small, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		util.SecureZeroMemory(buf.b)
+		return nil, err
+	}
+	return &Thumbnail{JPEG: buf.b, Blurhash: EncodeBlurhash(small, 4, 3)}, nil
+}
+
+// zeroImage erases the pixels of a decoded image. It handles the image types the registered decoders return.
+func zeroImage(img image.Image) {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		util.SecureZeroMemory(img.Y)
+		util.SecureZeroMemory(img.Cb)
+		util.SecureZeroMemory(img.Cr)
+	case *image.NYCbCrA:
+		util.SecureZeroMemory(img.Y)
+		util.SecureZeroMemory(img.Cb)
+		util.SecureZeroMemory(img.Cr)
+		util.SecureZeroMemory(img.A)
+	case *image.Paletted:
+		util.SecureZeroMemory(img.Pix)
+		for i := range img.Palette {
+			img.Palette[i] = color.RGBA{}
+		}
+	case *image.RGBA:
+		util.SecureZeroMemory(img.Pix)
+	case *image.NRGBA:
+		util.SecureZeroMemory(img.Pix)
+	case *image.RGBA64:
+		util.SecureZeroMemory(img.Pix)
+	case *image.NRGBA64:
+		util.SecureZeroMemory(img.Pix)
+	case *image.Gray:
+		util.SecureZeroMemory(img.Pix)
+	case *image.Gray16:
+		util.SecureZeroMemory(img.Pix)
+	case *image.CMYK:
+		util.SecureZeroMemory(img.Pix)
+	}
+}
+
+// secureBuffer collects the encoded thumbnail. Unlike bytes.Buffer it erases its old contents whenever it grows, so
+// no partial copy of the thumbnail is left to the garbage collector. It implements Flush and WriteByte so that
+// jpeg.Encode writes to it directly, instead of through a buffer of its own.
+type secureBuffer struct {
+	b []byte
+}
+
+func (w *secureBuffer) grow(n int) {
+	if len(w.b)+n <= cap(w.b) {
+		return
+	}
+	grown := make([]byte, len(w.b), 2*cap(w.b)+n)
+	copy(grown, w.b)
+	util.SecureZeroMemory(w.b)
+	w.b = grown
+}
+
+func (w *secureBuffer) Write(p []byte) (int, error) {
+	w.grow(len(p))
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+func (w *secureBuffer) WriteByte(c byte) error {
+	w.grow(1)
+	w.b = append(w.b, c)
+	return nil
+}
+
+func (w *secureBuffer) Flush() error {
+	return nil
+}
+
+// Downscale returns img scaled down so that neither side exceeds maxDimension, keeping its aspect ratio. Every
+// destination pixel is the average of the source pixels it covers. Images that are already small enough are copied.
+func Downscale(img image.Image, maxDimension int) *image.RGBA {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > maxDimension || srcH > maxDimension {
+		if srcW >= srcH {
+			dstW, dstH = maxDimension, max(1, srcH*maxDimension/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*maxDimension/srcH), maxDimension
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for dy := 0; dy < dstH; dy++ {
+		y0, y1 := dy*srcH/dstH, max((dy+1)*srcH/dstH, dy*srcH/dstH+1)
+		for dx := 0; dx < dstW; dx++ {
+			x0, x1 := dx*srcW/dstW, max((dx+1)*srcW/dstW, dx*srcW/dstW+1)
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
+
+// flattenOnWhite composites a (premultiplied) image onto a white background, as JPEG has no transparency
+func flattenOnWhite(img *image.RGBA) {
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		transparency := 255 - img.Pix[i+3]
+		img.Pix[i+0] += transparency
+		img.Pix[i+1] += transparency
+		img.Pix[i+2] += transparency
+		img.Pix[i+3] = 255
+	}
+}
@@ -0,0 +1,125 @@
+package imageutils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func encodePNGForTest(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 900, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 900; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	thumbnail, err := MakeThumbnail(bytes.NewReader(encodePNGForTest(t, img)))
+	if err != nil {
+		t.Fatalf("Failed to make thumbnail: %v", err)
+	}
+
+	config, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail.JPEG))
+	if err != nil {
+		t.Fatalf("Thumbnail is not a JPEG: %v", err)
+	}
+	if config.Width != ThumbnailMaxDimension || config.Height != 170 {
+		t.Errorf("Thumbnail is %dx%d, want %dx170", config.Width, config.Height, ThumbnailMaxDimension)
+	}
+
+	// 1 size flag + 1 maximum AC + 4 DC + 2 for each of the 11 AC components
+	if len(thumbnail.Blurhash) != 28 {
+		t.Errorf("Blurhash %q has length %d, want 28", thumbnail.Blurhash, len(thumbnail.Blurhash))
+	}
+}
+
+func TestMakeThumbnailFlattensTransparency(t *testing.T) {
+	// a fully transparent image ends up white, as does its blurhash
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+
+	thumbnail, err := MakeThumbnail(bytes.NewReader(encodePNGForTest(t, img)))
+	if err != nil {
+		t.Fatalf("Failed to make thumbnail: %v", err)
+	}
+
+	// characters 2 to 6 hold the average colour
+	if dc := thumbnail.Blurhash[2:6]; dc != "TSUA" {
+		t.Errorf("Blurhash %q has average colour %q, want white (\"TSUA\")", thumbnail.Blurhash, dc)
+	}
+}
+
+func TestMakeThumbnailRejectsNonImages(t *testing.T) {
+	_, err := MakeThumbnail(bytes.NewReader([]byte("not an image")))
+	if !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("MakeThumbnail() error = %v, want %v", err, ErrUnsupportedImage)
+	}
+}
+
+func TestDownscaleKeepsAspectRatio(t *testing.T) {
+	tests := []struct {
+		width, height int
+		wantW, wantH  int
+	}{
+		{1000, 500, 256, 128},
+		{500, 1000, 128, 256},
+		{100, 50, 100, 50},
+		{5000, 1, 256, 1},
+	}
+
+	for _, tt := range tests {
+		small := Downscale(image.NewRGBA(image.Rect(0, 0, tt.width, tt.height)), ThumbnailMaxDimension)
+		if small.Rect.Dx() != tt.wantW || small.Rect.Dy() != tt.wantH {
+			t.Errorf("Downscale(%dx%d) = %dx%d, want %dx%d", tt.width, tt.height, small.Rect.Dx(), small.Rect.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestZeroImage(t *testing.T) {
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)
+	paletted := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.White, color.Black})
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for _, pix := range [][]byte{ycbcr.Y, ycbcr.Cb, ycbcr.Cr, paletted.Pix, nrgba.Pix} {
+		for i := range pix {
+			pix[i] = 1
+		}
+	}
+
+	for _, img := range []image.Image{ycbcr, paletted, nrgba} {
+		zeroImage(img)
+	}
+	for name, pix := range map[string][]byte{"Y": ycbcr.Y, "Cb": ycbcr.Cb, "Cr": ycbcr.Cr, "paletted": paletted.Pix, "NRGBA": nrgba.Pix} {
+		if !bytes.Equal(pix, make([]byte, len(pix))) {
+			t.Errorf("%s pixels were not erased", name)
+		}
+	}
+	if r, g, b, _ := paletted.Palette[0].RGBA(); r != 0 || g != 0 || b != 0 {
+		t.Errorf("Palette was not erased")
+	}
+}
+
+func TestSecureBufferErasesWhenGrowing(t *testing.T) {
+	var buf secureBuffer
+	buf.Write([]byte("first"))
+	old := buf.b
+	buf.Write(bytes.Repeat([]byte("x"), 100))
+	if !bytes.Equal(old[:cap(old)], make([]byte, cap(old))) {
+		t.Errorf("Outgrown buffer holds %q, want it erased", old[:cap(old)])
+	}
+	if want := "first" + strings.Repeat("x", 100); string(buf.b) != want {
+		t.Errorf("Buffer holds %q, want %q", buf.b, want)
+	}
+}
@@ -0,0 +1,269 @@
+// Package signutils signs exported files with the vault's Ed25519 key, so that recipients can check that an export is
+// byte-for-byte what left this Tella Desktop.
+//
+// A signature is kept in a JSON sidecar next to the signed file, named after it with SignatureExtension appended. The
+// key signs a short statement naming the SHA-256 hash and size of the file rather than the file itself, so that large
+// exports needn't be held in memory; see statement for its format. Verifying needs nothing but the file, its sidecar
+// and the fingerprint of the key it is expected to be signed with, so it works offline and without the vault.
+package signutils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	util "Tella-Desktop/backend/utils/genericutil"
+)
+
+const (
+	// SignatureExtension is appended to the name of a signed file to name its signature sidecar
+	SignatureExtension = ".sig"
+	signatureVersion   = 1
+	signatureAlgorithm = "Ed25519"
+	// statementHeader separates signatures of exports from anything else the key might ever sign
+	statementHeader = "tella-desktop-export-signature-v1"
+)
+
+// Signature is the content of a signature sidecar
+type Signature struct {
+	Version   int    `json:"version"`
+	Algorithm string `json:"algorithm"`
+	// PublicKey is the base64 encoded key the file was signed with; it proves nothing by itself, the recipient must
+	// compare its fingerprint with the one the sender published
+	PublicKey   string `json:"publicKey"`
+	Fingerprint string `json:"fingerprint"`
+	// FileName is the name the file was exported under; it isn't signed, so renamed files still verify
+	FileName string `json:"fileName"`
+	Size     int64  `json:"size"`
+	// SHA256 is the hex encoded hash of the signed file
+	SHA256 string `json:"sha256"`
+	// SignedAt is an RFC 3339 timestamp in UTC
+	SignedAt string `json:"signedAt"`
+	// Value is the base64 encoded Ed25519 signature of the statement
+	Value string `json:"signature"`
+}
+
+// statement is the message the key signs for a file:
+//
+//	tella-desktop-export-signature-v1
+//	sha256:<hex encoded hash of the file>
+//	size:<size of the file in bytes>
+//	signed-at:<RFC 3339 timestamp>
+//
+// with every line ending in "\n".
+func statement(sha256Hex string, size int64, signedAt string) []byte {
+	return []byte(fmt.Sprintf("%s\nsha256:%s\nsize:%d\nsigned-at:%s\n", statementHeader, sha256Hex, size, signedAt))
+}
+
+// Fingerprint identifies a public key in a form people can compare: the SHA-256 hash of the key, as upper case hex in
+// groups of four
+func Fingerprint(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	encoded := strings.ToUpper(hex.EncodeToString(sum[:]))
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// EnsureKey generates the vault's signing key unless it already has one. The key is stored in the encrypted database
+// and never leaves it.
+func EnsureKey(db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM signing_key WHERE id = 1)").Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	defer util.SecureZeroMemory(privateKey)
+	// OR IGNORE keeps the first key, should two callers race
+	_, err = db.Exec(`
+		INSERT OR IGNORE INTO signing_key (id, seed, public_key, created_at) VALUES (1, ?, ?, datetime('now'))
+	`, privateKey.Seed(), []byte(publicKey))
+	return err
+}
+
+// ErrNoKey is returned for vaults whose signing key has not been created yet, which happens the next time they are
+// unlocked
+var ErrNoKey = errors.New("the vault has no signing key yet, lock and unlock it to create one")
+
+// LoadKey returns the vault's signing key. Callers should zero it once done.
+func LoadKey(db *sql.DB) (ed25519.PrivateKey, error) {
+	var seed []byte
+	err := db.QueryRow("SELECT seed FROM signing_key WHERE id = 1").Scan(&seed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer util.SecureZeroMemory(seed)
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key has %d bytes, want %d", len(seed), ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// LoadPublicKey returns the public half of the vault's signing key
+func LoadPublicKey(db *sql.DB) (ed25519.PublicKey, error) {
+	var publicKey []byte
+	err := db.QueryRow("SELECT public_key FROM signing_key WHERE id = 1").Scan(&publicKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key has %d bytes, want %d", len(publicKey), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(publicKey), nil
+}
+
+// EncodePublicKey returns a public key as PEM, preceded by its fingerprint. Text before the PEM block is ignored by
+// tools such as openssl, so the file can be used with them as it is.
+func EncodePublicKey(publicKey ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("Tella Desktop export signing key\nFingerprint: %s\n\n", Fingerprint(publicKey))
+	return append([]byte(header), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...), nil
+}
+
+// hashFile returns the hex encoded SHA-256 hash and the size of a file
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// SignFile signs a file, writing the signature to a sidecar next to it whose path is returned
+func SignFile(privateKey ed25519.PrivateKey, path string) (string, error) {
+	sum, size, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	signature := Signature{
+		Version:     signatureVersion,
+		Algorithm:   signatureAlgorithm,
+		PublicKey:   base64.StdEncoding.EncodeToString(publicKey),
+		Fingerprint: Fingerprint(publicKey),
+		FileName:    filepath.Base(path),
+		Size:        size,
+		SHA256:      sum,
+		SignedAt:    time.Now().UTC().Format(time.RFC3339),
+	}
+	signature.Value = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, statement(sum, size, signature.SignedAt)))
+
+	encoded, err := json.MarshalIndent(signature, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	sigPath := path + SignatureExtension
+	file, err := util.NarrowCreate(sigPath)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(append(encoded, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(sigPath)
+		return "", err
+	}
+	return sigPath, nil
+}
+
+// Verification is the result of VerifyFile
+type Verification struct {
+	// Valid is set if the file is exactly the file that was signed, by the key with the given Fingerprint
+	Valid       bool   `json:"valid"`
+	Fingerprint string `json:"fingerprint"`
+	SignedAt    string `json:"signedAt"`
+	Problem     string `json:"problem"`
+}
+
+var ErrNoSignature = errors.New("the file has no signature")
+
+// VerifyFile checks a file against the signature in its sidecar. A valid signature only shows that the file is
+// unchanged since it was signed by the key with the returned fingerprint; whether that key can be trusted is up to the
+// caller. An error is returned if the file or sidecar can't be read, an invalid Verification if they don't match.
+func VerifyFile(path string) (*Verification, error) {
+	encoded, err := os.ReadFile(path + SignatureExtension)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSignature
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Verification{}
+	invalid := func(problem string) (*Verification, error) {
+		result.Problem = problem
+		return result, nil
+	}
+
+	var signature Signature
+	if err := json.Unmarshal(encoded, &signature); err != nil {
+		return invalid("the signature file is malformed")
+	}
+	if signature.Version != signatureVersion || signature.Algorithm != signatureAlgorithm {
+		return invalid(fmt.Sprintf("unsupported signature version %d (%s)", signature.Version, signature.Algorithm))
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(signature.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return invalid("the signature file holds no valid public key")
+	}
+	result.Fingerprint = Fingerprint(publicKey)
+	result.SignedAt = signature.SignedAt
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return invalid("the signature file holds no valid signature")
+	}
+
+	sum, size, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(publicKey, statement(signature.SHA256, signature.Size, signature.SignedAt), value) {
+		return invalid("the signature does not match its signature file")
+	}
+	if sum != signature.SHA256 || size != signature.Size {
+		return invalid("the file was modified after it was signed")
+	}
+	result.Valid = true
+	return result, nil
+}
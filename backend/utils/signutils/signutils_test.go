@@ -0,0 +1,82 @@
+package signutils
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Tella-Desktop/backend/core/database"
+	"Tella-Desktop/backend/utils/constants"
+)
+
+func TestEnsureKeyKeepsKey(t *testing.T) {
+	key := make([]byte, constants.KeyLength)
+	db, err := database.Initialize(filepath.Join(t.TempDir(), "tella.db"), key)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := LoadKey(db.DB); err == nil {
+		t.Fatalf("LoadKey() succeeded before a key was generated")
+	}
+	if err := EnsureKey(db.DB); err != nil {
+		t.Fatalf("EnsureKey() failed: %v", err)
+	}
+	first, err := LoadKey(db.DB)
+	if err != nil {
+		t.Fatalf("LoadKey() failed: %v", err)
+	}
+	if err := EnsureKey(db.DB); err != nil {
+		t.Fatalf("second EnsureKey() failed: %v", err)
+	}
+	second, err := LoadKey(db.DB)
+	if err != nil || !first.Equal(second) {
+		t.Fatalf("second EnsureKey() replaced the key")
+	}
+	publicKey, err := LoadPublicKey(db.DB)
+	if err != nil || !publicKey.Equal(first.Public()) {
+		t.Errorf("LoadPublicKey() = %x, %v, want %x", publicKey, err, first.Public())
+	}
+}
+
+func TestSignAndVerifyFile(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	fingerprint := Fingerprint(privateKey.Public().(ed25519.PublicKey))
+
+	path := filepath.Join(t.TempDir(), "export.zip")
+	if err := os.WriteFile(path, []byte("exported content"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := VerifyFile(path); err != ErrNoSignature {
+		t.Errorf("VerifyFile() of an unsigned file = %v, want ErrNoSignature", err)
+	}
+	sigPath, err := SignFile(privateKey, path)
+	if err != nil || sigPath != path+SignatureExtension {
+		t.Fatalf("SignFile() = %q, %v", sigPath, err)
+	}
+
+	result, err := VerifyFile(path)
+	if err != nil || !result.Valid || result.Fingerprint != fingerprint {
+		t.Fatalf("VerifyFile() = %+v, %v, want valid with fingerprint %s", result, err, fingerprint)
+	}
+
+	// renaming the file with its sidecar keeps the signature valid
+	renamed := filepath.Join(filepath.Dir(path), "renamed.zip")
+	os.Rename(path, renamed)
+	os.Rename(sigPath, renamed+SignatureExtension)
+	if result, err := VerifyFile(renamed); err != nil || !result.Valid {
+		t.Errorf("VerifyFile() of renamed file = %+v, %v, want valid", result, err)
+	}
+
+	if err := os.WriteFile(renamed, []byte("exported c0ntent"), 0600); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if result, err := VerifyFile(renamed); err != nil || result.Valid {
+		t.Errorf("VerifyFile() of modified file = %+v, %v, want invalid", result, err)
+	}
+}
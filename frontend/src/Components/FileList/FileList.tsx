@@ -1,11 +1,13 @@
-import { useState, useEffect } from 'react';
+import { useState, useEffect, useRef } from 'react';
 import { useParams } from 'react-router-dom';
-import { GetFilesInFolder, ExportFiles, ExportZipFolders, DeleteFiles } from '../../../wailsjs/go/app/App';
+import { GetFilesInFolder, StartExportFiles, StartExportZipFolders, DeleteFiles } from '../../../wailsjs/go/app/App';
+import { filestore } from '../../../wailsjs/go/models';
 import { Dialog } from '../Dialog/Dialog';
 import { LoadingDialog } from '../Dialog/LoadingDialog';
 import { SuccessToast } from '../Toast/SuccessToast';
 
 import { sanitizeUGC } from "../../util/util"
+import { runExportJob, formatExportProgress, ExportCancelledError, ExportJob } from "../../util/exportJob"
 
 import {
   Container,
@@ -114,6 +116,8 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
   const [showSuccessToast, setShowSuccessToast] = useState<boolean>(false);
   const [successMessage, setSuccessMessage] = useState<string>('');
   const [isExporting, setIsExporting] = useState<boolean>(false);
+  const [exportMessage, setExportMessage] = useState<string>('');
+  const exportJob = useRef<ExportJob | null>(null);
   const [isDeleting, setIsDeleting] = useState<boolean>(false);
 
   const fetchFiles = async () => {
@@ -198,7 +202,11 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
     try {
       const fileIds = Array.from(selectedFiles);
       
-      const exportPaths = await ExportZipFolders([folderId], fileIds);
+      exportJob.current = runExportJob(
+        () => StartExportZipFolders([folderId], fileIds, new filestore.ExportOptions()),
+        (progress) => setExportMessage(formatExportProgress(progress)),
+      );
+      const exportPaths = await exportJob.current.done;
       
       setSuccessMessage(`ZIP file created successfully: ${exportPaths[0]}`);
       
@@ -206,10 +214,16 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
       setShowSuccessToast(true);
       
     } catch (error) {
-      console.error('ZIP export failed:', error);
-      setSuccessMessage('ZIP export failed. Please try again.');
+      if (error instanceof ExportCancelledError) {
+        setSuccessMessage('ZIP export cancelled.');
+      } else {
+        console.error('ZIP export failed:', error);
+        setSuccessMessage('ZIP export failed. Please try again.');
+      }
       setShowSuccessToast(true);
     } finally {
+      exportJob.current = null;
+      setExportMessage('');
       setIsExporting(false);
       setShowExportLoading(false);
     }
@@ -227,7 +241,11 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
     try {
       const fileIds = Array.from(selectedFiles);
       
-      const exportPaths = await ExportFiles(fileIds);
+      exportJob.current = runExportJob(
+        () => StartExportFiles(fileIds, new filestore.ExportOptions()),
+        (progress) => setExportMessage(formatExportProgress(progress)),
+      );
+      const exportPaths = await exportJob.current.done;
       
       if (fileIds.length === 1) {
         setSuccessMessage(`File exported successfully to: ${exportPaths[0]}`);
@@ -240,10 +258,16 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
       setShowSuccessToast(true);
       
     } catch (error) {
-      console.error('Export failed:', error);
-      setSuccessMessage('Export failed. Please try again.');
+      if (error instanceof ExportCancelledError) {
+        setSuccessMessage('Export cancelled.');
+      } else {
+        console.error('Export failed:', error);
+        setSuccessMessage('Export failed. Please try again.');
+      }
       setShowSuccessToast(true);
     } finally {
+      exportJob.current = null;
+      setExportMessage('');
       setIsExporting(false);
       setShowExportLoading(false);
     }
@@ -257,8 +281,8 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
 
   const handleLoadingCancel = () => {
     if (isExporting) {
-      setShowExportLoading(false);
-      setIsExporting(false);
+      // the export confirm handler hides the dialog once the job has stopped and removed what it wrote
+      exportJob.current?.cancel();
     }
     if (isDeleting) {
       setShowDeleteLoading(false);
@@ -468,9 +492,9 @@ export function FileList({ folderId: propFolderId, folderName: propFolderName }:
 
       <LoadingDialog
         isOpen={showExportLoading}
-        onCancel={handleDialogCancel}
+        onCancel={handleLoadingCancel}
         title="Your files are exporting"
-        message="Please wait while your files are exporting. Do not close Tella or the export may fail."
+        message={exportMessage || "Please wait while your files are exporting. Do not close Tella or the export may fail."}
       />
 
       <LoadingDialog
@@ -1,6 +1,7 @@
-import { useState, useEffect } from 'react';
+import { useState, useEffect, useRef } from 'react';
 import { useNavigate } from 'react-router-dom';
-import { GetStoredFolders, ExportZipFolders, DeleteFolders } from '../../../wailsjs/go/app/App';
+import { GetStoredFolders, StartExportZipFolders, DeleteFolders } from '../../../wailsjs/go/app/App';
+import { filestore } from '../../../wailsjs/go/models';
 import {
   Container,
   Header,
@@ -35,6 +36,7 @@ import { Dialog } from '../Dialog/Dialog';
 import { LoadingDialog } from '../Dialog/LoadingDialog';
 import { SuccessToast } from '../Toast/SuccessToast';
 import { sanitizeUGC } from "../../util/util"
+import { runExportJob, formatExportProgress, ExportCancelledError, ExportJob } from "../../util/exportJob"
 
 interface FolderInfo {
   id: number
@@ -69,6 +71,8 @@ export function FolderList() {
   const [showSuccessToast, setShowSuccessToast] = useState<boolean>(false);
   const [successMessage, setSuccessMessage] = useState<string>('');
   const [isExporting, setIsExporting] = useState<boolean>(false);
+  const [exportMessage, setExportMessage] = useState<string>('');
+  const exportJob = useRef<ExportJob | null>(null);
 
   const [showDeleteDialog, setShowDeleteDialog] = useState<boolean>(false);
   const [showDeleteLoading, setShowDeleteLoading] = useState<boolean>(false);
@@ -148,7 +152,11 @@ export function FolderList() {
       const folderIds = Array.from(selectedFolders);
       
       // Export entire folders as ZIP (empty selectedFileIDs array)
-      const exportPaths = await ExportZipFolders(folderIds, []);
+      exportJob.current = runExportJob(
+        () => StartExportZipFolders(folderIds, [], new filestore.ExportOptions()),
+        (progress) => setExportMessage(formatExportProgress(progress)),
+      );
+      const exportPaths = await exportJob.current.done;
       
       if (folderIds.length === 1) {
         setSuccessMessage(`Folder exported as ZIP: ${exportPaths[0]}`);
@@ -162,10 +170,16 @@ export function FolderList() {
       setShowSuccessToast(true);
       
     } catch (error) {
-      console.error('Folder ZIP export failed:', error);
-      setSuccessMessage('Folder export failed. Please try again.');
+      if (error instanceof ExportCancelledError) {
+        setSuccessMessage('Folder export cancelled.');
+      } else {
+        console.error('Folder ZIP export failed:', error);
+        setSuccessMessage('Folder export failed. Please try again.');
+      }
       setShowSuccessToast(true);
     } finally {
+      exportJob.current = null;
+      setExportMessage('');
       setIsExporting(false);
       setShowExportLoading(false);
     }
@@ -173,8 +187,8 @@ export function FolderList() {
 
   const handleExportCancel = () => {
     if (isExporting) {
-      setShowExportLoading(false);
-      setIsExporting(false);
+      // the export confirm handler hides the dialog once the job has stopped and removed what it wrote
+      exportJob.current?.cancel();
     } else {
       setShowExportDialog(false);
     }
@@ -360,9 +374,9 @@ export function FolderList() {
 
       <LoadingDialog
         isOpen={showExportLoading}
-        onCancel={handleDialogCancel}
+        onCancel={handleExportCancel}
         title="Your folders are exporting"
-        message="Please wait while your folders are being exported as ZIP files. Do not close Tella or the export may fail."
+        message={exportMessage || "Please wait while your folders are being exported as ZIP files. Do not close Tella or the export may fail."}
       />
 
       <LoadingDialog
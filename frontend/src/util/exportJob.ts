@@ -0,0 +1,96 @@
+import { CancelExport } from '../../wailsjs/go/app/App';
+import { EventsOn } from '../../wailsjs/runtime/runtime';
+
+// mirrors filestore.ExportProgress, sent with every "export-progress" event
+export interface ExportProgress {
+    jobId: string;
+    status: 'running' | 'completed' | 'cancelled' | 'failed';
+    filesDone: number;
+    filesTotal: number;
+    bytesDone: number;
+    bytesTotal: number;
+    currentFile: string;
+    paths: string[] | null;
+    error: string;
+}
+
+export class ExportCancelledError extends Error {
+    constructor() {
+        super('export cancelled');
+        this.name = 'ExportCancelledError';
+    }
+}
+
+export interface ExportJob {
+    // resolves with the exported paths, or rejects with ExportCancelledError if the job was cancelled
+    done: Promise<string[]>;
+    cancel: () => void;
+}
+
+// runExportJob starts an export job with one of the StartExport bindings and follows its "export-progress" events
+// until it ends. Events can arrive before the binding returns the job ID, so they are held until it is known.
+export function runExportJob(start: () => Promise<string>, onProgress?: (progress: ExportProgress) => void): ExportJob {
+    let jobId: string | undefined;
+    let cancelRequested = false;
+    let stopListening = () => {};
+    let pending: ExportProgress[] = [];
+
+    const done = new Promise<string[]>((resolve, reject) => {
+        const handle = (progress: ExportProgress) => {
+            onProgress?.(progress);
+            switch (progress.status) {
+                case 'completed':
+                    stopListening();
+                    resolve(progress.paths ?? []);
+                    break;
+                case 'cancelled':
+                    stopListening();
+                    reject(new ExportCancelledError());
+                    break;
+                case 'failed':
+                    stopListening();
+                    reject(new Error(progress.error));
+                    break;
+            }
+        };
+
+        stopListening = EventsOn('export-progress', (progress: ExportProgress) => {
+            if (jobId === undefined) {
+                pending.push(progress);
+            } else if (progress.jobId === jobId) {
+                handle(progress);
+            }
+        });
+
+        start().then((id) => {
+            jobId = id;
+            const early = pending.filter((progress) => progress.jobId === id);
+            pending = [];
+            early.forEach(handle);
+            if (cancelRequested) {
+                CancelExport(id).catch(() => {});
+            }
+        }, (error) => {
+            stopListening();
+            reject(error);
+        });
+    });
+
+    const cancel = () => {
+        if (jobId === undefined) {
+            cancelRequested = true;
+            return;
+        }
+        // the job may already have ended, in which case there is nothing to cancel
+        CancelExport(jobId).catch(() => {});
+    };
+
+    return { done, cancel };
+}
+
+export function formatExportProgress(progress: ExportProgress): string {
+    if (progress.filesTotal === 0) {
+        return 'Preparing export...';
+    }
+    return `Exported ${progress.filesDone} of ${progress.filesTotal} files`;
+}
@@ -1,32 +1,91 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {filestore} from '../models';
+import {reports} from '../models';
 import {context} from '../models';
+import {audit} from '../models';
+import {signing} from '../models';
 
 export function AcceptTransfer(arg1:string):Promise<void>;
 
+export function AddFilesToReport(arg1:number,arg2:Array<number>):Promise<void>;
+
+export function CancelExport(arg1:string):Promise<void>;
+
+export function CheckVault(arg1:boolean):Promise<filestore.VaultCheckReport>;
+
+export function ChooseExportDirectory():Promise<string>;
+
+export function CompactVault():Promise<filestore.CompactionResult>;
+
 export function ConfirmRegistration():Promise<void>;
 
+export function CopyFiles(arg1:Array<number>,arg2:number):Promise<Array<number>>;
+
+export function CreateFolder(arg1:string,arg2:number):Promise<number>;
+
 export function CreatePassword(arg1:string):Promise<void>;
 
+export function CreateReport(arg1:string):Promise<number>;
+
 export function DeleteFiles(arg1:Array<number>):Promise<void>;
 
 export function DeleteFolders(arg1:Array<number>):Promise<void>;
 
-export function ExportFiles(arg1:Array<number>):Promise<Array<string>>;
+export function DeleteReport(arg1:number):Promise<void>;
+
+export function EmptyTrash():Promise<void>;
+
+export function ExportAuditLog():Promise<string>;
+
+export function ExportReportZip(arg1:number):Promise<string>;
 
-export function ExportZipFolders(arg1:Array<number>,arg2:Array<number>):Promise<Array<string>>;
+export function ExportSigningKey():Promise<string>;
 
 export function GetDefaultPort():Promise<number>;
 
+export function GetDuplicatePolicy():Promise<string>;
+
+export function GetExportDirectory():Promise<string>;
+
+export function GetFileAnnotations(arg1:number):Promise<filestore.Annotations>;
+
+export function GetFileURL(arg1:number):Promise<string>;
+
 export function GetFilesInFolder(arg1:number):Promise<filestore.FilesInFolderResponse>;
 
+export function GetFolderAnnotations(arg1:number):Promise<filestore.Annotations>;
+
+export function GetFolderTree():Promise<Array<filestore.FolderNode>>;
+
+export function GetFreeSpaceReport():Promise<filestore.FreeSpaceReport>;
+
 export function GetLocalIPs():Promise<Array<string>>;
 
+export function GetRefuseCloudSyncExports():Promise<boolean>;
+
+export function GetReportFiles(arg1:number):Promise<reports.ReportFilesResponse>;
+
+export function GetReports():Promise<Array<reports.ReportInfo>>;
+
 export function GetServerPIN():Promise<string>;
 
+export function GetSigningKeyFingerprint():Promise<string>;
+
 export function GetStoredFolders():Promise<Array<filestore.FolderInfo>>;
 
+export function GetTags():Promise<Array<filestore.TagInfo>>;
+
+export function GetThumbnail(arg1:number):Promise<string>;
+
+export function GetTrash():Promise<Array<filestore.TrashedFileInfo>>;
+
+export function GetVaultStats():Promise<filestore.VaultStats>;
+
+export function ImportContainer(arg1:string,arg2:string,arg3:number):Promise<filestore.ImportResult>;
+
+export function ImportPaths(arg1:Array<string>,arg2:number,arg3:boolean):Promise<filestore.ImportResult>;
+
 export function IsDevelopment():Promise<boolean>;
 
 export function IsFirstTimeSetup():Promise<boolean>;
@@ -37,16 +96,72 @@ export function LockApp():Promise<void>;
 
 export function ManualConfirmationReceiverForReceiver():Promise<void>;
 
+export function MoveFiles(arg1:Array<number>,arg2:number):Promise<void>;
+
+export function MoveFolder(arg1:number,arg2:number):Promise<void>;
+
+export function OpenFileForPreview(arg1:number):Promise<string>;
+
+export function PurgeTrash(arg1:Array<number>):Promise<void>;
+
 export function RejectRegistration():Promise<void>;
 
 export function RejectTransfer(arg1:string):Promise<void>;
 
+export function RemoveFilesFromReport(arg1:number,arg2:Array<number>):Promise<void>;
+
+export function RenameFile(arg1:number,arg2:string):Promise<void>;
+
+export function RenameFolder(arg1:number,arg2:string):Promise<void>;
+
+export function RenameReport(arg1:number,arg2:string):Promise<void>;
+
+export function ResetExportDirectory():Promise<void>;
+
+export function RestoreFiles(arg1:Array<number>,arg2:number):Promise<void>;
+
+export function SearchFiles(arg1:filestore.FileSearchQuery):Promise<filestore.FileSearchResult>;
+
+export function SelectContainerToImport():Promise<string>;
+
+export function SelectFilesToImport():Promise<Array<string>>;
+
+export function SelectFolderToImport():Promise<string>;
+
+export function SetDuplicatePolicy(arg1:string):Promise<void>;
+
+export function SetFileCustomMetadata(arg1:number,arg2:string,arg3:string):Promise<void>;
+
+export function SetFolderCustomMetadata(arg1:number,arg2:string,arg3:string):Promise<void>;
+
+export function SetRefuseCloudSyncExports(arg1:boolean):Promise<void>;
+
 export function Shutdown(arg1:context.Context):Promise<void>;
 
+export function StartExportFiles(arg1:Array<number>,arg2:filestore.ExportOptions):Promise<string>;
+
+export function StartExportZipFolders(arg1:Array<number>,arg2:Array<number>,arg3:filestore.ExportOptions):Promise<string>;
+
 export function StartServer(arg1:number):Promise<void>;
 
 export function StopServer():Promise<void>;
 
 export function StopTransfer(arg1:string):Promise<void>;
 
+export function TagFiles(arg1:Array<number>,arg2:Array<string>):Promise<void>;
+
+export function TagFolders(arg1:Array<number>,arg2:Array<string>):Promise<void>;
+
+export function TrashFiles(arg1:Array<number>):Promise<void>;
+
+export function TrashFolders(arg1:Array<number>):Promise<void>;
+
+export function UntagFiles(arg1:Array<number>,arg2:Array<string>):Promise<void>;
+
+export function UntagFolders(arg1:Array<number>,arg2:Array<string>):Promise<void>;
+
+export function VerifyAuditLog():Promise<audit.Verification>;
+
 export function VerifyPassword(arg1:string):Promise<void>;
+
+export function VerifySignedExport(arg1:string):Promise<signing.ExportVerification>;
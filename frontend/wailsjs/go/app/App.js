@@ -6,14 +6,46 @@ export function AcceptTransfer(arg1) {
   return window['go']['app']['App']['AcceptTransfer'](arg1);
 }
 
+export function AddFilesToReport(arg1, arg2) {
+  return window['go']['app']['App']['AddFilesToReport'](arg1, arg2);
+}
+
+export function CancelExport(arg1) {
+  return window['go']['app']['App']['CancelExport'](arg1);
+}
+
+export function CheckVault(arg1) {
+  return window['go']['app']['App']['CheckVault'](arg1);
+}
+
+export function ChooseExportDirectory() {
+  return window['go']['app']['App']['ChooseExportDirectory']();
+}
+
+export function CompactVault() {
+  return window['go']['app']['App']['CompactVault']();
+}
+
 export function ConfirmRegistration() {
   return window['go']['app']['App']['ConfirmRegistration']();
 }
 
+export function CopyFiles(arg1, arg2) {
+  return window['go']['app']['App']['CopyFiles'](arg1, arg2);
+}
+
+export function CreateFolder(arg1, arg2) {
+  return window['go']['app']['App']['CreateFolder'](arg1, arg2);
+}
+
 export function CreatePassword(arg1) {
   return window['go']['app']['App']['CreatePassword'](arg1);
 }
 
+export function CreateReport(arg1) {
+  return window['go']['app']['App']['CreateReport'](arg1);
+}
+
 export function DeleteFiles(arg1) {
   return window['go']['app']['App']['DeleteFiles'](arg1);
 }
@@ -22,34 +54,114 @@ export function DeleteFolders(arg1) {
   return window['go']['app']['App']['DeleteFolders'](arg1);
 }
 
-export function ExportFiles(arg1) {
-  return window['go']['app']['App']['ExportFiles'](arg1);
+export function DeleteReport(arg1) {
+  return window['go']['app']['App']['DeleteReport'](arg1);
+}
+
+export function EmptyTrash() {
+  return window['go']['app']['App']['EmptyTrash']();
+}
+
+export function ExportAuditLog() {
+  return window['go']['app']['App']['ExportAuditLog']();
+}
+
+export function ExportReportZip(arg1) {
+  return window['go']['app']['App']['ExportReportZip'](arg1);
 }
 
-export function ExportZipFolders(arg1, arg2) {
-  return window['go']['app']['App']['ExportZipFolders'](arg1, arg2);
+export function ExportSigningKey() {
+  return window['go']['app']['App']['ExportSigningKey']();
 }
 
 export function GetDefaultPort() {
   return window['go']['app']['App']['GetDefaultPort']();
 }
 
+export function GetDuplicatePolicy() {
+  return window['go']['app']['App']['GetDuplicatePolicy']();
+}
+
+export function GetExportDirectory() {
+  return window['go']['app']['App']['GetExportDirectory']();
+}
+
+export function GetFileAnnotations(arg1) {
+  return window['go']['app']['App']['GetFileAnnotations'](arg1);
+}
+
+export function GetFileURL(arg1) {
+  return window['go']['app']['App']['GetFileURL'](arg1);
+}
+
 export function GetFilesInFolder(arg1) {
   return window['go']['app']['App']['GetFilesInFolder'](arg1);
 }
 
+export function GetFolderAnnotations(arg1) {
+  return window['go']['app']['App']['GetFolderAnnotations'](arg1);
+}
+
+export function GetFolderTree() {
+  return window['go']['app']['App']['GetFolderTree']();
+}
+
+export function GetFreeSpaceReport() {
+  return window['go']['app']['App']['GetFreeSpaceReport']();
+}
+
 export function GetLocalIPs() {
   return window['go']['app']['App']['GetLocalIPs']();
 }
 
+export function GetRefuseCloudSyncExports() {
+  return window['go']['app']['App']['GetRefuseCloudSyncExports']();
+}
+
+export function GetReportFiles(arg1) {
+  return window['go']['app']['App']['GetReportFiles'](arg1);
+}
+
+export function GetReports() {
+  return window['go']['app']['App']['GetReports']();
+}
+
 export function GetServerPIN() {
   return window['go']['app']['App']['GetServerPIN']();
 }
 
+export function GetSigningKeyFingerprint() {
+  return window['go']['app']['App']['GetSigningKeyFingerprint']();
+}
+
 export function GetStoredFolders() {
   return window['go']['app']['App']['GetStoredFolders']();
 }
 
+export function GetTags() {
+  return window['go']['app']['App']['GetTags']();
+}
+
+export function GetThumbnail(arg1) {
+  return window['go']['app']['App']['GetThumbnail'](arg1);
+}
+
+export function GetTrash() {
+  return window['go']['app']['App']['GetTrash']();
+}
+
+export function GetVaultStats() {
+  return window['go']['app']['App']['GetVaultStats']();
+}
+
+export function ImportContainer(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ImportContainer'](arg1, arg2, arg3);
+}
+
+export function ImportPaths(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ImportPaths'](arg1, arg2, arg3);
+}
+
 export function IsDevelopment() {
   return window['go']['app']['App']['IsDevelopment']();
 }
@@ -70,6 +182,22 @@ export function ManualConfirmationReceiverForReceiver() {
   return window['go']['app']['App']['ManualConfirmationReceiverForReceiver']();
 }
 
+export function MoveFiles(arg1, arg2) {
+  return window['go']['app']['App']['MoveFiles'](arg1, arg2);
+}
+
+export function MoveFolder(arg1, arg2) {
+  return window['go']['app']['App']['MoveFolder'](arg1, arg2);
+}
+
+export function OpenFileForPreview(arg1) {
+  return window['go']['app']['App']['OpenFileForPreview'](arg1);
+}
+
+export function PurgeTrash(arg1) {
+  return window['go']['app']['App']['PurgeTrash'](arg1);
+}
+
 export function RejectRegistration() {
   return window['go']['app']['App']['RejectRegistration']();
 }
@@ -78,10 +206,74 @@ export function RejectTransfer(arg1) {
   return window['go']['app']['App']['RejectTransfer'](arg1);
 }
 
+export function RemoveFilesFromReport(arg1, arg2) {
+  return window['go']['app']['App']['RemoveFilesFromReport'](arg1, arg2);
+}
+
+export function RenameFile(arg1, arg2) {
+  return window['go']['app']['App']['RenameFile'](arg1, arg2);
+}
+
+export function RenameFolder(arg1, arg2) {
+  return window['go']['app']['App']['RenameFolder'](arg1, arg2);
+}
+
+export function RenameReport(arg1, arg2) {
+  return window['go']['app']['App']['RenameReport'](arg1, arg2);
+}
+
+export function ResetExportDirectory() {
+  return window['go']['app']['App']['ResetExportDirectory']();
+}
+
+export function RestoreFiles(arg1, arg2) {
+  return window['go']['app']['App']['RestoreFiles'](arg1, arg2);
+}
+
+export function SearchFiles(arg1) {
+  return window['go']['app']['App']['SearchFiles'](arg1);
+}
+
+export function SelectContainerToImport() {
+  return window['go']['app']['App']['SelectContainerToImport']();
+}
+
+export function SelectFilesToImport() {
+  return window['go']['app']['App']['SelectFilesToImport']();
+}
+
+export function SelectFolderToImport() {
+  return window['go']['app']['App']['SelectFolderToImport']();
+}
+
+export function SetDuplicatePolicy(arg1) {
+  return window['go']['app']['App']['SetDuplicatePolicy'](arg1);
+}
+
+export function SetFileCustomMetadata(arg1, arg2, arg3) {
+  return window['go']['app']['App']['SetFileCustomMetadata'](arg1, arg2, arg3);
+}
+
+export function SetFolderCustomMetadata(arg1, arg2, arg3) {
+  return window['go']['app']['App']['SetFolderCustomMetadata'](arg1, arg2, arg3);
+}
+
+export function SetRefuseCloudSyncExports(arg1) {
+  return window['go']['app']['App']['SetRefuseCloudSyncExports'](arg1);
+}
+
 export function Shutdown(arg1) {
   return window['go']['app']['App']['Shutdown'](arg1);
 }
 
+export function StartExportFiles(arg1, arg2) {
+  return window['go']['app']['App']['StartExportFiles'](arg1, arg2);
+}
+
+export function StartExportZipFolders(arg1, arg2, arg3) {
+  return window['go']['app']['App']['StartExportZipFolders'](arg1, arg2, arg3);
+}
+
 export function StartServer(arg1) {
   return window['go']['app']['App']['StartServer'](arg1);
 }
@@ -94,6 +286,38 @@ export function StopTransfer(arg1) {
   return window['go']['app']['App']['StopTransfer'](arg1);
 }
 
+export function TagFiles(arg1, arg2) {
+  return window['go']['app']['App']['TagFiles'](arg1, arg2);
+}
+
+export function TagFolders(arg1, arg2) {
+  return window['go']['app']['App']['TagFolders'](arg1, arg2);
+}
+
+export function TrashFiles(arg1) {
+  return window['go']['app']['App']['TrashFiles'](arg1);
+}
+
+export function TrashFolders(arg1) {
+  return window['go']['app']['App']['TrashFolders'](arg1);
+}
+
+export function UntagFiles(arg1, arg2) {
+  return window['go']['app']['App']['UntagFiles'](arg1, arg2);
+}
+
+export function UntagFolders(arg1, arg2) {
+  return window['go']['app']['App']['UntagFolders'](arg1, arg2);
+}
+
+export function VerifyAuditLog() {
+  return window['go']['app']['App']['VerifyAuditLog']();
+}
+
 export function VerifyPassword(arg1) {
   return window['go']['app']['App']['VerifyPassword'](arg1);
 }
+
+export function VerifySignedExport(arg1) {
+  return window['go']['app']['App']['VerifySignedExport'](arg1);
+}
@@ -1,11 +1,87 @@
+export namespace audit {
+	
+	export class Verification {
+	    entries: number;
+	    valid: boolean;
+	    headHash: string;
+	    brokenAt: number;
+	    problem: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Verification(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entries = source["entries"];
+	        this.valid = source["valid"];
+	        this.headHash = source["headHash"];
+	        this.brokenAt = source["brokenAt"];
+	        this.problem = source["problem"];
+	    }
+	}
+
+}
+
 export namespace filestore {
 	
+	export class Annotations {
+	    tags: string[];
+	    customMetadata: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new Annotations(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tags = source["tags"];
+	        this.customMetadata = source["customMetadata"];
+	    }
+	}
+	export class CompactionResult {
+	    sizeBefore: number;
+	    sizeAfter: number;
+	    regionsMoved: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CompactionResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sizeBefore = source["sizeBefore"];
+	        this.sizeAfter = source["sizeAfter"];
+	        this.regionsMoved = source["regionsMoved"];
+	    }
+	}
+	export class ExportOptions {
+	    includeManifest: boolean;
+	    destination: string;
+	    format: string;
+	    passphrase: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.includeManifest = source["includeManifest"];
+	        this.destination = source["destination"];
+	        this.format = source["format"];
+	        this.passphrase = source["passphrase"];
+	    }
+	}
 	export class FileInfo {
 	    id: number;
 	    name: string;
 	    mimeType: string;
 	    timestamp: string;
 	    size: number;
+	    sha256: string;
+	    folderId: number;
+	    blurhash: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new FileInfo(source);
@@ -18,7 +94,78 @@ export namespace filestore {
 	        this.mimeType = source["mimeType"];
 	        this.timestamp = source["timestamp"];
 	        this.size = source["size"];
+	        this.sha256 = source["sha256"];
+	        this.folderId = source["folderId"];
+	        this.blurhash = source["blurhash"];
+	    }
+	}
+	export class FileSearchQuery {
+	    name: string;
+	    mimeType: string;
+	    minSize: number;
+	    maxSize: number;
+	    receivedAfter: string;
+	    receivedBefore: string;
+	    folderId: number;
+	    includeSubfolders: boolean;
+	    tags: string[];
+	    sortBy: string;
+	    sortDesc: boolean;
+	    limit: number;
+	    cursor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileSearchQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.mimeType = source["mimeType"];
+	        this.minSize = source["minSize"];
+	        this.maxSize = source["maxSize"];
+	        this.receivedAfter = source["receivedAfter"];
+	        this.receivedBefore = source["receivedBefore"];
+	        this.folderId = source["folderId"];
+	        this.includeSubfolders = source["includeSubfolders"];
+	        this.tags = source["tags"];
+	        this.sortBy = source["sortBy"];
+	        this.sortDesc = source["sortDesc"];
+	        this.limit = source["limit"];
+	        this.cursor = source["cursor"];
+	    }
+	}
+	export class FileSearchResult {
+	    files: FileInfo[];
+	    nextCursor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileSearchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = this.convertValues(source["files"], FileInfo);
+	        this.nextCursor = source["nextCursor"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class FilesInFolderResponse {
 	    folderName: string;
@@ -70,6 +217,372 @@ export namespace filestore {
 	        this.fileCount = source["fileCount"];
 	    }
 	}
+	export class FolderNode {
+	    id: number;
+	    name: string;
+	    parentId: number;
+	    timestamp: string;
+	    fileCount: number;
+	    children: FolderNode[];
+	
+	    static createFrom(source: any = {}) {
+	        return new FolderNode(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.parentId = source["parentId"];
+	        this.timestamp = source["timestamp"];
+	        this.fileCount = source["fileCount"];
+	        this.children = this.convertValues(source["children"], FolderNode);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FolderUsage {
+	    folderId: number;
+	    name: string;
+	    parentId: number;
+	    fileCount: number;
+	    bytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FolderUsage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.folderId = source["folderId"];
+	        this.name = source["name"];
+	        this.parentId = source["parentId"];
+	        this.fileCount = source["fileCount"];
+	        this.bytes = source["bytes"];
+	    }
+	}
+	export class FreeSpaceReport {
+	    vaultSize: number;
+	    freeBytes: number;
+	    blockCount: number;
+	    largestBlock: number;
+	    fragmentation: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FreeSpaceReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vaultSize = source["vaultSize"];
+	        this.freeBytes = source["freeBytes"];
+	        this.blockCount = source["blockCount"];
+	        this.largestBlock = source["largestBlock"];
+	        this.fragmentation = source["fragmentation"];
+	    }
+	}
+	export class ImportFailure {
+	    path: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ImportFailure(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.error = source["error"];
+	    }
+	}
+	export class ImportResult {
+	    fileIds: number[];
+	    foldersCreated: number;
+	    duplicates: number;
+	    shredded: number;
+	    failed: ImportFailure[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ImportResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fileIds = source["fileIds"];
+	        this.foldersCreated = source["foldersCreated"];
+	        this.duplicates = source["duplicates"];
+	        this.shredded = source["shredded"];
+	        this.failed = this.convertValues(source["failed"], ImportFailure);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TagInfo {
+	    name: string;
+	    fileCount: number;
+	    folderCount: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TagInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.fileCount = source["fileCount"];
+	        this.folderCount = source["folderCount"];
+	    }
+	}
+	export class TrashedFileInfo {
+	    file: FileInfo;
+	    trashedAt: string;
+	    purgeAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TrashedFileInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.file = this.convertValues(source["file"], FileInfo);
+	        this.trashedAt = source["trashedAt"];
+	        this.purgeAt = source["purgeAt"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class VaultCheckReport {
+	    vaultSize: number;
+	    filesChecked: number;
+	    issues: VaultIssue[];
+	    quarantined: number[];
+	    freeSpaceRemoved: number;
+	    thumbnailsCleared: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new VaultCheckReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vaultSize = source["vaultSize"];
+	        this.filesChecked = source["filesChecked"];
+	        this.issues = this.convertValues(source["issues"], VaultIssue);
+	        this.quarantined = source["quarantined"];
+	        this.freeSpaceRemoved = source["freeSpaceRemoved"];
+	        this.thumbnailsCleared = source["thumbnailsCleared"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class VaultIssue {
+	    kind: string;
+	    fileId: number;
+	    offset: number;
+	    length: number;
+	    detail: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new VaultIssue(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.fileId = source["fileId"];
+	        this.offset = source["offset"];
+	        this.length = source["length"];
+	        this.detail = source["detail"];
+	    }
+	}
+	export class VaultStats {
+	    vaultSize: number;
+	    liveBytes: number;
+	    freeBytes: number;
+	    freeBlocks: number;
+	    largestFreeBlock: number;
+	    folders: FolderUsage[];
+	    diskFreeBytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new VaultStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.vaultSize = source["vaultSize"];
+	        this.liveBytes = source["liveBytes"];
+	        this.freeBytes = source["freeBytes"];
+	        this.freeBlocks = source["freeBlocks"];
+	        this.largestFreeBlock = source["largestFreeBlock"];
+	        this.folders = this.convertValues(source["folders"], FolderUsage);
+	        this.diskFreeBytes = source["diskFreeBytes"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace reports {
+	
+	export class ReportFilesResponse {
+	    reportName: string;
+	    files: filestore.FileInfo[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ReportFilesResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.reportName = source["reportName"];
+	        this.files = this.convertValues(source["files"], filestore.FileInfo);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ReportInfo {
+	    id: number;
+	    name: string;
+	    timestamp: string;
+	    updatedAt: string;
+	    fileCount: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReportInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.timestamp = source["timestamp"];
+	        this.updatedAt = source["updatedAt"];
+	        this.fileCount = source["fileCount"];
+	    }
+	}
+
+}
+
+export namespace signing {
+	
+	export class ExportVerification {
+	    valid: boolean;
+	    fingerprint: string;
+	    signedByThisVault: boolean;
+	    signedAt: string;
+	    problem: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportVerification(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.valid = source["valid"];
+	        this.fingerprint = source["fingerprint"];
+	        this.signedByThisVault = source["signedByThisVault"];
+	        this.signedAt = source["signedAt"];
+	        this.problem = source["problem"];
+	    }
+	}
 
 }
 
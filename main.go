@@ -19,7 +19,7 @@ var icon []byte
 
 func main() {
 	// Create an instance of the app structure
-	app := app.NewApp()
+	application := app.NewApp()
 
 	// Create application with options
 	err := wails.Run(&options.App{
@@ -27,12 +27,14 @@ func main() {
 		Width:  1024,
 		Height: 768,
 		AssetServer: &assetserver.Options{
-			Assets: assets,
+			Assets:  assets,
+			Handler: app.NewFileHandler(application),
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
-		OnStartup:        app.Startup,
+		OnStartup:        application.Startup,
+		OnShutdown:       application.Shutdown,
 		Bind: []interface{}{
-			app,
+			application,
 		},
 		Linux: &linux.Options{
 			Icon: icon,